@@ -3,15 +3,21 @@ package controller
 import (
 	"FlyCloud/application"
 	"FlyCloud/models"
+	"FlyCloud/pkg/account"
+	"FlyCloud/pkg/audit"
 	"FlyCloud/pkg/jwt"
-	"FlyCloud/pkg/md5"
+	"FlyCloud/pkg/lockout"
+	"FlyCloud/pkg/password"
 	"FlyCloud/pkg/response"
 	"FlyCloud/pkg/system"
+	"FlyCloud/pkg/tenant"
 	"FlyCloud/serves/cache"
+	acs "FlyCloud/serves/casbin"
 	"FlyCloud/serves/database"
 	"net/http"
 
 	"github.com/allegro/bigcache"
+	"github.com/casbin/casbin"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 )
@@ -19,6 +25,14 @@ import (
 // 管理员管理控制器
 type AdminController interface {
 	application.BaseController
+	Unlock(ctx *gin.Context)
+	Enable(ctx *gin.Context)
+	Disable(ctx *gin.Context)
+	Approve(ctx *gin.Context)
+	Reject(ctx *gin.Context)
+	GetGrants(ctx *gin.Context)
+	SetRoles(ctx *gin.Context)
+	SetPermissions(ctx *gin.Context)
 }
 
 // 管理员管理控制器实现
@@ -27,6 +41,35 @@ type adminController struct {
 	Cache *bigcache.BigCache
 }
 
+// 新增和编辑管理员的参数，状态、两步验证和锁定只能通过各自的接口修改
+type adminParam struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Sex         string `json:"sex"`
+	Nickname    string `json:"nickname"`
+	Telephone   string `json:"telephone"`
+	Email       string `json:"email"`
+	Department  string `json:"department"`
+	ImgSrc      string `json:"img_src"`
+	Description string `json:"description"`
+	RolesName   string `json:"roles_name"`
+}
+
+// 转换为管理员模型，密码需要单独处理
+func (p adminParam) admin() models.Admin {
+	return models.Admin{
+		Username:    p.Username,
+		Sex:         p.Sex,
+		Nickname:    p.Nickname,
+		Telephone:   p.Telephone,
+		Email:       p.Email,
+		Department:  p.Department,
+		ImgSrc:      p.ImgSrc,
+		Description: p.Description,
+		RolesName:   p.RolesName,
+	}
+}
+
 // @Title Select
 // @Description 查询管理员
 // @param model	body	models.Admin	true	"查询条件"
@@ -40,7 +83,7 @@ func (a adminController) Select(ctx *gin.Context) {
 		return
 	}
 	// 过滤条件 = 查询条件
-	db := a.Db.Model(models.Admin{}).Where("id > 0")
+	db := tenant.DB(ctx, a.Db).Model(models.Admin{}).Where("id > 0")
 	// 查询条件
 	if model.Username != "" {
 		db = db.Where("username like ?", "%"+model.Username+"%")
@@ -79,20 +122,42 @@ func (a adminController) Select(ctx *gin.Context) {
 
 // @Title Insert
 // @Description 新增管理员
-// @param model	body	models.Admin	true	"新增数据"
+// @param model	body	adminParam	true	"新增数据"
 // @Success 200 {object} models.Admin "新增结果"
 // @router /admin/admin/add [post]
 func (a adminController) Insert(ctx *gin.Context) {
 	// 获取参数
-	var model models.Admin
-	if err := ctx.ShouldBindJSON(&model); err != nil {
+	var p adminParam
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 验证分配的角色
+	if !a.checkRole(ctx, p.RolesName) {
+		return
+	}
+	// 验证密码是否符合密码策略
+	if err := password.Validate(p.Password); err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// 生成密码哈希
+	hash, err := password.Hash(p.Password)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	model := p.admin()
+	model.Password = hash
 	// 更改状态
 	model.Status = 1
 	// 新增
-	if err := a.Db.Create(&model).Error; err != nil {
+	if err := tenant.DB(ctx, a.Db).Create(&model).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 记录历史密码
+	if err := models.AddPasswordHistory(a.Db, model.ID, hash); err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -104,26 +169,46 @@ func (a adminController) Insert(ctx *gin.Context) {
 
 // @Title Update
 // @Description 更新管理员
-// @param model	body	models.Admin	true	"更新数据"
+// @param model	body	adminParam	true	"更新数据"
 // @Success 200 {object} models.Admin "更新结果"
 // @router /admin/admin/edit/:id [put]
 func (a adminController) Update(ctx *gin.Context) {
 	// 获取参数
 	var id = ctx.Param("id")
-	var model models.Admin
-	if err := ctx.ShouldBindJSON(&model); err != nil {
+	var p adminParam
+	if err := ctx.ShouldBindJSON(&p); err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// 如果密码不为空，则更新密码
-	if model.Password != "" {
-		model.Password = md5.Encry(model.Password)
+	// 角色为空时不修改
+	if p.RolesName != "" && !a.checkRole(ctx, p.RolesName) {
+		return
+	}
+	model := p.admin()
+	// 如果密码不为空，则按密码策略更新密码
+	if p.Password != "" {
+		var admin models.Admin
+		if err := tenant.DB(ctx, a.Db).First(&admin, "id = ?", id).Error; err != nil {
+			response.Error(ctx, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := models.UpdateAdminPassword(tenant.DB(ctx, a.Db), &admin, p.Password); err != nil {
+			response.Error(ctx, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// 修改密码后吊销该用户的所有会话
+		if err := jwt.RevokeAllSessions(admin.ID); err != nil {
+			response.Error(ctx, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 	// 更新
-	if err := a.Db.Model(&model).Where("id = ?", id).Updates(model).Error; err != nil {
+	if err := tenant.DB(ctx, a.Db).Model(&model).Where("id = ?", id).Updates(model).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// 角色可能已变更，清除缓存的账号状态和权限
+	account.Invalidate(system.StrToUint(id))
 	// 返回数据
 	response.Success(ctx, gin.H{
 		"data": model,
@@ -145,11 +230,37 @@ func (a adminController) Delete(ctx *gin.Context) {
 		response.Error(ctx, "不能删除自己", http.StatusBadRequest)
 		return
 	}
-	// 删除
-	if err := a.Db.Delete(&models.Admin{}, "id = ?", id).Error; err != nil {
+	// 删除，只能删除本租户的管理员
+	result := tenant.DB(ctx, a.Db).Delete(&models.Admin{}, "id = ?", id)
+	if result.Error != nil {
+		response.Error(ctx, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, "管理员不存在", http.StatusBadRequest)
+		return
+	}
+	// 删除该用户的API密钥
+	if err := tenant.DB(ctx, a.Db).Delete(&models.ApiKey{}, "admin_id = ?", id).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 解除该用户绑定的外部身份
+	if err := tenant.DB(ctx, a.Db).Delete(&models.AdminIdentity{}, "admin_id = ?", id).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 删除该用户的附加角色和直接授予的权限
+	_ = acs.Update(func(e *casbin.Enforcer) error {
+		e.DeleteUser(acs.UserSubject(system.StrToUint(id)))
+		return nil
+	})
+	// 吊销该用户的所有会话
+	if err := jwt.RevokeAllSessions(system.StrToUint(id)); err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	account.Invalidate(system.StrToUint(id))
 	// 返回数据
 	response.Success(ctx, gin.H{}, "删除成功")
 }
@@ -164,7 +275,7 @@ func (a adminController) Find(ctx *gin.Context) {
 	var id = ctx.Param("id")
 	// 查询
 	var model models.Admin
-	if err := a.Db.First(&model, "id = ?", id).Error; err != nil {
+	if err := tenant.DB(ctx, a.Db).First(&model, "id = ?", id).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -174,8 +285,337 @@ func (a adminController) Find(ctx *gin.Context) {
 	}, "查询成功")
 }
 
+// @Title Unlock
+// @Description 解除因登录失败次数过多而锁定的管理员账号
+// @param id	path	int	true	"管理员id"
+// @Success 200 "解锁结果"
+// @router /admin/admin/unlock/:id [put]
+func (a adminController) Unlock(ctx *gin.Context) {
+	// 获取参数
+	var id = ctx.Param("id")
+	var model models.Admin
+	if err := tenant.DB(ctx, a.Db).First(&model, "id = ?", id).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 清除锁定状态
+	if err := tenant.DB(ctx, a.Db).Model(&models.Admin{}).Where("id = ?", model.ID).Update("locked_until", 0).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 清除失败计数，用户名和手机号均可用于登录
+	lockout.Unlock(model.Username, model.Telephone)
+	// 返回数据
+	response.Success(ctx, gin.H{}, "解锁成功")
+}
+
+// @Title Enable
+// @Description 启用管理员账号
+// @param id	path	int	true	"管理员id"
+// @Success 200 "启用结果"
+// @router /admin/admin/enable/:id [put]
+func (a adminController) Enable(ctx *gin.Context) {
+	a.setStatus(ctx, models.AdminStatusEnabled)
+}
+
+// @Title Disable
+// @Description 禁用管理员账号，该账号的所有会话立即失效
+// @param id	path	int	true	"管理员id"
+// @Success 200 "禁用结果"
+// @router /admin/admin/disable/:id [put]
+func (a adminController) Disable(ctx *gin.Context) {
+	a.setStatus(ctx, models.AdminStatusDisabled)
+}
+
+// @Title Approve
+// @Description 审核通过注册的管理员并分配角色，仅超级管理员可操作
+// @param id	path	int	true	"管理员id"
+// @param roles_name	json	string	false	"分配的角色，为空时使用注册默认角色"
+// @Success 200 "审核结果"
+// @router /admin/admin/approve/:id [put]
+func (a adminController) Approve(ctx *gin.Context) {
+	// 从ctx中获取管理员信息
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	if !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
+		response.Error(ctx, "只有超级管理员可以审核账号", http.StatusForbidden)
+		return
+	}
+	// 获取参数
+	var id = system.StrToUint(ctx.Param("id"))
+	type param struct {
+		RolesName string `json:"roles_name"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var model models.Admin
+	if err := tenant.DB(ctx, a.Db).First(&model, "id = ?", id).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if model.Status != models.AdminStatusPending {
+		response.Error(ctx, "该账号不是待审核状态", http.StatusBadRequest)
+		return
+	}
+	// 验证角色是否存在
+	if p.RolesName == "" {
+		role, err := models.GetRegisterDefaultRole(tenant.DB(ctx, a.Db))
+		if err != nil {
+			response.Error(ctx, "请选择角色："+err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.RolesName = role
+	} else if !models.IsExistRole(tenant.DB(ctx, a.Db), p.RolesName) {
+		response.Error(ctx, "角色不存在", http.StatusBadRequest)
+		return
+	}
+	// 条件更新，防止重复审核
+	result := tenant.DB(ctx, a.Db).Model(&models.Admin{}).Where("id = ? and status = ?", id, models.AdminStatusPending).Updates(map[string]interface{}{
+		"status":     models.AdminStatusEnabled,
+		"roles_name": p.RolesName,
+	})
+	if result.Error != nil {
+		response.Error(ctx, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, "该账号不是待审核状态", http.StatusBadRequest)
+		return
+	}
+	// 清除缓存的账号状态和权限
+	account.Invalidate(id)
+	// 返回数据
+	response.Success(ctx, gin.H{
+		"id":         id,
+		"status":     models.AdminStatusEnabled,
+		"roles_name": p.RolesName,
+	}, "审核通过")
+}
+
+// @Title Reject
+// @Description 拒绝注册的管理员，账号将被禁用，仅超级管理员可操作
+// @param id	path	int	true	"管理员id"
+// @Success 200 "审核结果"
+// @router /admin/admin/reject/:id [put]
+func (a adminController) Reject(ctx *gin.Context) {
+	// 从ctx中获取管理员信息
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	if !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
+		response.Error(ctx, "只有超级管理员可以审核账号", http.StatusForbidden)
+		return
+	}
+	// 获取参数
+	var id = system.StrToUint(ctx.Param("id"))
+	result := tenant.DB(ctx, a.Db).Model(&models.Admin{}).Where("id = ? and status = ?", id, models.AdminStatusPending).Update("status", models.AdminStatusDisabled)
+	if result.Error != nil {
+		response.Error(ctx, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, "该账号不是待审核状态", http.StatusBadRequest)
+		return
+	}
+	// 清除缓存的账号状态和权限
+	account.Invalidate(id)
+	// 返回数据
+	response.Success(ctx, gin.H{
+		"id":     id,
+		"status": models.AdminStatusDisabled,
+	}, "已拒绝")
+}
+
+// @Title GetGrants
+// @Description 获取管理员的角色和直接授予的权限
+// @param id	path	int	true	"管理员id"
+// @Success 200 {role,roles,implicit_roles,ids} role string,roles []string,implicit_roles []string,ids []int "查询结果"
+// @router /admin/admin/grants/:id [get]
+func (a adminController) GetGrants(ctx *gin.Context) {
+	var model models.Admin
+	if err := tenant.DB(ctx, a.Db).First(&model, "id = ?", ctx.Param("id")).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subject := acs.UserSubject(model.ID)
+	dom := tenant.Domain(ctx)
+	var permissions [][]string
+	var implicit []string
+	acs.View(func(e *casbin.Enforcer) {
+		permissions = e.GetPermissionsForUserInDomain(subject, dom)
+		implicit = e.GetImplicitRolesForUser(subject, dom)
+	})
+	// 直接授予的权限对应的规则
+	ids := []int{}
+	for _, p := range permissions {
+		var rule models.Rules
+		if err := a.Db.First(&rule, "path = ? and method = ?", p[2], p[3]).Error; err == nil {
+			ids = append(ids, rule.ID)
+		}
+	}
+	response.Success(ctx, gin.H{
+		"role":           model.RolesName,
+		"roles":          acs.UserRoles(model.ID, dom),
+		"implicit_roles": implicit,
+		"ids":            ids,
+	}, "查询成功")
+}
+
+// @Title SetRoles
+// @Description 设置管理员的附加角色，主角色仍通过编辑管理员修改
+// @param id	path	int			true	"管理员id"
+// @param roles	json	[]string	true	"附加角色别名"
+// @Success 200 {roles} roles []string "设置结果"
+// @router /admin/admin/roles/:id [put]
+func (a adminController) SetRoles(ctx *gin.Context) {
+	var model models.Admin
+	if err := tenant.DB(ctx, a.Db).First(&model, "id = ?", ctx.Param("id")).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var p struct {
+		Roles []string `json:"roles"`
+	}
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 验证角色是否存在，只有超级管理员可以授予超级管理员
+	roles := []string{}
+	for _, role := range p.Roles {
+		if system.InArray(roles, role) {
+			continue
+		}
+		if !a.checkRole(ctx, role) {
+			return
+		}
+		roles = append(roles, role)
+	}
+	// 替换原有的附加角色
+	subject := acs.UserSubject(model.ID)
+	dom := tenant.Domain(ctx)
+	var before []string
+	_ = acs.Update(func(e *casbin.Enforcer) error {
+		before = groupingList(e.GetFilteredGroupingPolicy(0, subject, "", dom))
+		e.RemoveFilteredGroupingPolicy(0, subject, "", dom)
+		for _, role := range roles {
+			e.AddRoleForUserInDomain(subject, role, dom)
+		}
+		return nil
+	})
+	_ = audit.Record(ctx, a.Db, models.AuditActionUpdate, "casbin_rule", subject, policyChange("roles", before, roles))
+	account.Invalidate(model.ID)
+	response.Success(ctx, gin.H{"roles": roles}, "设置成功")
+}
+
+// @Title SetPermissions
+// @Description 直接授予管理员权限，与角色的权限叠加
+// @param id	path	int		true	"管理员id"
+// @param ids	json	[]int	true	"权限规则ids"
+// @Success 200 {ids} ids []int "设置结果"
+// @router /admin/admin/permissions/:id [put]
+func (a adminController) SetPermissions(ctx *gin.Context) {
+	var model models.Admin
+	if err := tenant.DB(ctx, a.Db).First(&model, "id = ?", ctx.Param("id")).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var p struct {
+		Ids []int `json:"ids"`
+	}
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var rules []models.Rules
+	if len(p.Ids) > 0 {
+		if err := a.Db.Where("id in (?)", p.Ids).Find(&rules).Error; err != nil {
+			response.Error(ctx, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	// 替换原有的直接授权，分组节点没有请求方法，不生成权限
+	subject := acs.UserSubject(model.ID)
+	dom := tenant.Domain(ctx)
+	ids := []int{}
+	var before, after []string
+	_ = acs.Update(func(e *casbin.Enforcer) error {
+		before = policyList(e.GetFilteredPolicy(0, subject, dom))
+		e.RemoveFilteredPolicy(0, subject, dom)
+		for _, rule := range rules {
+			if rule.Method != "" {
+				e.AddPolicy(subject, dom, rule.Path, rule.Method)
+				ids = append(ids, rule.ID)
+			}
+		}
+		after = policyList(e.GetFilteredPolicy(0, subject, dom))
+		return nil
+	})
+	_ = audit.Record(ctx, a.Db, models.AuditActionUpdate, "casbin_rule", subject, policyChange("permissions", before, after))
+	account.Invalidate(model.ID)
+	response.Success(ctx, gin.H{"ids": ids}, "设置成功")
+}
+
+// 修改管理员账号状态
+func (a adminController) setStatus(ctx *gin.Context, status int) {
+	// 获取参数
+	var id = system.StrToUint(ctx.Param("id"))
+	// 从ctx中获取管理员信息
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 不能禁用自己
+	if status != models.AdminStatusEnabled && claim.UserId == id {
+		response.Error(ctx, "不能禁用自己", http.StatusBadRequest)
+		return
+	}
+	var model models.Admin
+	if err := tenant.DB(ctx, a.Db).First(&model, "id = ?", id).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 待审核的账号需要通过审核接口分配角色
+	if model.Status == models.AdminStatusPending {
+		response.Error(ctx, "该账号正在等待审核", http.StatusBadRequest)
+		return
+	}
+	// 更新状态
+	if err := tenant.DB(ctx, a.Db).Model(&models.Admin{}).Where("id = ?", id).Update("status", status).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 禁用账号后吊销该用户的所有会话
+	if status != models.AdminStatusEnabled {
+		if err := jwt.RevokeAllSessions(id); err != nil {
+			response.Error(ctx, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	// 清除缓存的账号状态和权限
+	account.Invalidate(id)
+	// 返回数据
+	response.Success(ctx, gin.H{
+		"id":     id,
+		"status": status,
+	}, "更新成功")
+}
+
+// 验证分配的角色是否存在，只有超级管理员可以授予超级管理员，验证失败时返回错误信息
+func (a adminController) checkRole(ctx *gin.Context, role string) bool {
+	if !models.IsExistRole(tenant.DB(ctx, a.Db), role) {
+		response.Error(ctx, "角色不存在："+role, http.StatusBadRequest)
+		return false
+	}
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	if role == "super" && !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
+		response.Error(ctx, "没有权限授予超级管理员", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // 构造函数
 func NewAdminController() *adminController {
 	db := database.GetDB()
+	// 初始化历史密码表
+	models.InitPasswordHistoryTable(db)
 	return &adminController{Db: db, Cache: cache.GetCacheObj()}
 }
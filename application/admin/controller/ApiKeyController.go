@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/apikey"
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/response"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"net/http"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title ApiKeyController
+// @Description 个人API密钥控制器
+
+// 定义API密钥控制器
+type ApiKeyController interface {
+	Insert(ctx *gin.Context)
+	Select(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+// 定义API密钥控制器
+type apiKeyController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 实例化API密钥控制器
+func NewApiKeyController() *apiKeyController {
+	db := database.GetDB()
+	// 初始化API密钥表
+	models.InitApiKeyTable(db)
+	return &apiKeyController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Insert
+// @Description 创建API密钥，明文密钥只在此时返回一次
+// @Param	name		json	string		true	"密钥名称"
+// @Param	scopes		json	[]string	true	"权限范围，格式为“请求方法 路径”，如“GET /admin/customer/*”，省略请求方法表示任意方法"
+// @Param	expires_in	json	int			false	"有效期，单位天，0表示不过期"
+// @Success 200 {key,data} key string,data models.ApiKey "创建成功"
+// @Failure 0 "创建失败"
+// @router /admin/common/apikey/add [post]
+func (c *apiKeyController) Insert(ctx *gin.Context) {
+	if !c.sessionOnly(ctx) {
+		return
+	}
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 获取参数
+	type param struct {
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn int      `json:"expires_in"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 校验权限范围
+	scopes, err := apikey.NormalizeScopes(p.Scopes)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 生成密钥
+	plain, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		response.Error(ctx, "生成API密钥失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key := models.ApiKey{
+		AdminId: claim.UserId,
+		Name:    p.Name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  scopes,
+	}
+	if p.ExpiresIn > 0 {
+		expiresAt := time.Now().AddDate(0, 0, p.ExpiresIn)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := c.Db.Create(&key).Error; err != nil {
+		response.Error(ctx, "生成API密钥失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{"key": plain, "data": key}, "创建成功，请妥善保存密钥，关闭后将无法再次查看")
+}
+
+// @Title Select
+// @Description 查询当前管理员的API密钥列表
+// @Success 200 {data} data []models.ApiKey "获取成功"
+// @Failure 0 "获取失败"
+// @router /admin/common/apikey/list [get]
+func (c *apiKeyController) Select(ctx *gin.Context) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	var data []models.ApiKey
+	if err := c.Db.Where("admin_id = ?", claim.UserId).Order("id desc").Find(&data).Error; err != nil {
+		response.Error(ctx, "获取数据失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response.Success(ctx, gin.H{"data": data}, "获取数据成功")
+}
+
+// @Title Delete
+// @Description 删除API密钥，删除后立即失效
+// @Param id path int true "密钥id"
+// @Success 200 "删除成功"
+// @Failure 0 "删除失败"
+// @router /admin/common/apikey/delete/:id [delete]
+func (c *apiKeyController) Delete(ctx *gin.Context) {
+	if !c.sessionOnly(ctx) {
+		return
+	}
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 只能删除自己的密钥
+	var key models.ApiKey
+	if err := c.Db.Where("id = ? and admin_id = ?", ctx.Param("id"), claim.UserId).First(&key).Error; err != nil {
+		response.Error(ctx, "API密钥不存在", http.StatusNotFound)
+		return
+	}
+	if err := c.Db.Delete(&key).Error; err != nil {
+		response.Error(ctx, "删除失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	apikey.Invalidate(key.KeyHash)
+	response.Success(ctx, gin.H{}, "删除成功")
+}
+
+// 密钥只能通过登录会话管理，不允许使用API密钥创建或删除密钥
+func (c *apiKeyController) sessionOnly(ctx *gin.Context) bool {
+	if _, exists := ctx.Get("apikey"); exists {
+		response.Error(ctx, "不能使用API密钥管理API密钥", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/tenant"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"net/http"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title AuditController
+// @Description 审计日志控制器
+
+// 定义审计日志控制器
+type AuditController interface {
+	Select(ctx *gin.Context)
+}
+
+// 定义审计日志控制器
+type auditController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 实例化审计日志控制器
+func NewAuditController() *auditController {
+	db := database.GetDB()
+	// 初始化审计日志表
+	models.InitAuditLogTable(db)
+	return &auditController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Select
+// @Description 查询本租户的审计日志，按时间倒序。可按操作人、IP、路由、操作、数据类型、数据id和时间范围查询
+// @Param model json models.AuditLog true "查询条件"
+// @Success 200 {data,count} data []models.AuditLog,count int "获取成功"
+// @Failure 0 "获取失败"
+// @router /admin/audit/list [post]
+func (c *auditController) Select(ctx *gin.Context) {
+	// 获取查询条件
+	var model models.AuditLog
+	if err := ctx.ShouldBindJSON(&model); err != nil {
+		response.Error(ctx, "获取查询条件失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 默认分页
+	if model.PageNum <= 0 {
+		model.PageNum = 1
+	}
+	if model.PageSize <= 0 {
+		model.PageSize = 20
+	}
+	db := model.Filter(tenant.DB(ctx, c.Db).Model(&models.AuditLog{}))
+	// 获取总数
+	var count int
+	var data []models.AuditLog
+	// 查询数据，并分页
+	if err := db.Count(&count).Order("id desc").Limit(model.PageSize).Offset((model.PageNum - 1) * model.PageSize).Find(&data).Error; err != nil {
+		response.Error(ctx, "获取数据失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 返回数据
+	response.Success(ctx, gin.H{"data": data, "count": count}, "获取数据成功")
+}
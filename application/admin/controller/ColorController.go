@@ -4,6 +4,7 @@ import (
 	"FlyCloud/application"
 	"FlyCloud/models"
 	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/tenant"
 	"FlyCloud/serves/cache"
 	"FlyCloud/serves/database"
 	"github.com/allegro/bigcache"
@@ -38,7 +39,7 @@ func (c *ColorControllerImpl) GetAll(ctx *gin.Context) {
 	// 获取所有颜色
 	var colors []models.Color
 	var total int
-	if err := c.Db.Model(&models.Color{}).Count(&total).Find(&colors).Error; err != nil {
+	if err := tenant.DB(ctx, c.Db).Model(&models.Color{}).Count(&total).Find(&colors).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -58,7 +59,7 @@ func (c *ColorControllerImpl) Select(ctx *gin.Context) {
 		return
 	}
 	// 声明查询条件
-	query := c.Db.Model(&models.Color{})
+	query := tenant.DB(ctx, c.Db).Model(&models.Color{})
 	if color.Name != "" {
 		query = query.Where("name like ?", "%"+color.Name+"%")
 	}
@@ -89,7 +90,7 @@ func (c *ColorControllerImpl) Insert(ctx *gin.Context) {
 		Name:  color.Name,
 		Value: color.Value,
 	}
-	if err := c.Db.Create(&newColor).Error; err != nil {
+	if err := tenant.DB(ctx, c.Db).Create(&newColor).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -113,7 +114,7 @@ func (c *ColorControllerImpl) Update(ctx *gin.Context) {
 		Name:  color.Name,
 		Value: color.Value,
 	}
-	if err := c.Db.Model(&models.Color{}).Where("id = ?", ctx.Param("id")).Updates(newColor).Error; err != nil {
+	if err := tenant.DB(ctx, c.Db).Model(&models.Color{}).Where("id = ?", ctx.Param("id")).Updates(newColor).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -132,7 +133,7 @@ func (c *ColorControllerImpl) Delete(ctx *gin.Context) {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := c.Db.Delete(&color).Error; err != nil {
+	if err := tenant.DB(ctx, c.Db).Delete(&color).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
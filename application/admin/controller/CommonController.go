@@ -2,15 +2,22 @@ package controller
 
 import (
 	"FlyCloud/models"
-	"FlyCloud/pkg/Db"
+	"FlyCloud/pkg/account"
+	"FlyCloud/pkg/apikey"
 	"FlyCloud/pkg/captcha"
 	"FlyCloud/pkg/jwt"
-	"FlyCloud/pkg/md5"
+	"FlyCloud/pkg/lockout"
+	"FlyCloud/pkg/password"
 	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/system"
+	"FlyCloud/pkg/tenant"
 	"FlyCloud/serves/cache"
+	acs "FlyCloud/serves/casbin"
 	"FlyCloud/serves/database"
 	"FlyCloud/serves/logging"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/allegro/bigcache"
 	"github.com/gin-gonic/gin"
@@ -24,9 +31,12 @@ import (
 // 定义公共操作控制器
 type CommonController interface {
 	Login(ctx *gin.Context)
+	Refresh(ctx *gin.Context)
+	Logout(ctx *gin.Context)
 	Register(ctx *gin.Context)
 	GetCaptcha(ctx *gin.Context)
 	GetUserInfo(ctx *gin.Context)
+	Permissions(ctx *gin.Context)
 }
 
 // 定义公共操作控制器
@@ -41,7 +51,7 @@ type commonController struct {
 // @Param	password		json 	string	true		"密码"
 // @Param	captcha			json 	string	true		"验证码"
 // @Param	appid			json 	string	true		"appid"
-// @Success 200 {token,userInfo} token string,userInfo gin.H "登录成功"
+// @Success 200 {token,refresh_token,expires_in,userInfo} token string,refresh_token string,expires_in int,userInfo gin.H "登录成功"
 // @Failure 0 "登录失败"
 // @router /common/login [post]
 func (c commonController) Login(ctx *gin.Context) {
@@ -71,8 +81,16 @@ func (c commonController) Login(ctx *gin.Context) {
 		response.Error(ctx, "验证码不能为空", http.StatusBadRequest)
 		return
 	}
+	ip := ctx.ClientIP()
+	// 账号或IP失败次数过多时，拒绝登录
+	if err := lockout.Check(p.Username, ip); err != nil {
+		addLoginLog(ctx, c.Db, 0, p.Username, models.LoginResultLocked, err.Error())
+		limitError(ctx, err)
+		return
+	}
 	// 验证验证码
 	if captcha.VerifyCaptcha(p.Appid, p.Captcha) != true {
+		addLoginLog(ctx, c.Db, 0, p.Username, models.LoginResultCaptcha, "验证码错误")
 		response.Error(ctx, "验证码错误!", http.StatusBadRequest)
 		return
 	}
@@ -80,25 +98,130 @@ func (c commonController) Login(ctx *gin.Context) {
 	var admin models.Admin
 	// 验证用户名或密码是否正确
 	if err := c.Db.Table("admin").Where("username = ? or telephone = ?", p.Username, p.Username).First(&admin).Error; err != nil {
+		lockout.Fail(p.Username, ip)
+		addLoginLog(ctx, c.Db, 0, p.Username, models.LoginResultFailed, "用户不存在")
 		response.Error(ctx, "用户名或密码错误!", http.StatusBadRequest)
 		return
 	}
+	// 账号已被锁定
+	if admin.LockedUntil > time.Now().Unix() {
+		err := &lockout.LimitError{Locked: true, RetryAfter: time.Until(time.Unix(admin.LockedUntil, 0))}
+		addLoginLog(ctx, c.Db, admin.ID, p.Username, models.LoginResultLocked, err.Error())
+		limitError(ctx, err)
+		return
+	}
 	// 验证密码是否正确
-	if md5.Compare(p.Password, admin.Password) != true {
+	if password.Verify(p.Password, admin.Password) != true {
+		// 失败次数达到阈值时锁定账号
+		if lockedUntil := lockout.Fail(p.Username, ip); !lockedUntil.IsZero() {
+			c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Update("locked_until", lockedUntil.Unix())
+		}
+		addLoginLog(ctx, c.Db, admin.ID, p.Username, models.LoginResultFailed, "密码错误")
 		response.Error(ctx, "用户名或密码错误!", http.StatusBadRequest)
 		return
 	}
-	// 声明jwt
-	j := jwt.NewJwt()
-	token, err := j.CreateToken(&admin)
+	// 登录成功，清除失败计数
+	lockout.Succeed(p.Username)
+	// 验证账号是否已被禁用
+	if !checkStatus(ctx, c.Db, &admin, p.Username) {
+		return
+	}
+	// 旧格式或参数过期的哈希，登录成功后升级为新的哈希
+	if password.NeedsRehash(admin.Password) {
+		if hash, err := password.Hash(p.Password); err == nil {
+			if err := c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Update("password", hash).Error; err != nil {
+				logging.Ctx(ctx).Error("升级密码哈希失败：", err)
+			}
+		}
+	}
+	// 签发令牌
+	finishLogin(ctx, c.Db, &admin, p.Username)
+}
+
+// 验证账号状态，等待审核或已禁用的账号不能登录
+func checkStatus(ctx *gin.Context, db *gorm.DB, admin *models.Admin, username string) bool {
+	if admin.Status == models.AdminStatusPending {
+		addLoginLog(ctx, db, admin.ID, username, models.LoginResultDisabled, "账号等待审核")
+		response.Error(ctx, "账号正在等待管理员审核!", http.StatusForbidden)
+		return false
+	}
+	if admin.Status != models.AdminStatusEnabled {
+		addLoginLog(ctx, db, admin.ID, username, models.LoginResultDisabled, "账号已被禁用")
+		response.Error(ctx, "账号已被禁用!", http.StatusForbidden)
+		return false
+	}
+	if !account.TenantActive(admin.TenantId) {
+		addLoginLog(ctx, db, admin.ID, username, models.LoginResultDisabled, "所属租户已停用")
+		response.Error(ctx, "所属租户已停用!", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// 身份验证通过后完成登录，开启了两步验证时签发临时令牌，否则签发访问令牌
+func finishLogin(ctx *gin.Context, db *gorm.DB, admin *models.Admin, username string) {
+	// 开启了两步验证，或所属角色强制要求两步验证时，先签发临时令牌
+	if admin.TotpEnabled == 1 || isMfaForced(db, admin) {
+		setup := admin.TotpEnabled != 1
+		mfaToken, err := jwt.NewJwt().CreateMfaToken(admin, setup)
+		if err != nil {
+			response.Error(ctx, "生成token失败!", http.StatusInternalServerError)
+			logging.Ctx(ctx).Error("err:", err)
+			return
+		}
+		addLoginLog(ctx, db, admin.ID, username, models.LoginResultMfa, "等待两步验证")
+		response.Success(ctx, gin.H{
+			"mfa_required": true,
+			"mfa_setup":    setup,
+			"mfa_token":    mfaToken,
+		}, "请完成两步验证")
+		return
+	}
+	// 签发令牌
+	data, err := loginData(admin)
 	if err != nil {
 		response.Error(ctx, "生成token失败!", http.StatusInternalServerError)
-		logging.Error("err:", err)
+		logging.Ctx(ctx).Error("err:", err)
 		return
 	}
+	addLoginLog(ctx, db, admin.ID, username, models.LoginResultSuccess, "登录成功")
 	// 返回数据
-	response.Success(ctx, gin.H{
-		"token": token,
+	response.Success(ctx, data, "登录成功")
+}
+
+// 记录登录日志
+func addLoginLog(ctx *gin.Context, db *gorm.DB, adminId uint, username, result, message string) {
+	models.AddLoginLog(db, &models.LoginLog{
+		AdminId:   adminId,
+		Username:  username,
+		Ip:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		Result:    result,
+		Message:   message,
+	})
+}
+
+// 登录受限时返回需要等待的秒数
+func limitError(ctx *gin.Context, err *lockout.LimitError) {
+	ctx.Header("Retry-After", strconv.Itoa(err.Seconds()))
+	response.Response(ctx, http.StatusOK, http.StatusTooManyRequests, gin.H{
+		"locked":      err.Locked,
+		"retry_after": err.Seconds(),
+	}, err.Error())
+}
+
+// 登录成功后签发令牌，并构建返回数据
+func loginData(admin *models.Admin) (gin.H, error) {
+	// 声明jwt
+	j := jwt.NewJwt()
+	tokens, err := j.IssueTokens(admin)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"userInfo": gin.H{
 			"id":        admin.ID,
 			"username":  admin.Username,
@@ -107,7 +230,74 @@ func (c commonController) Login(ctx *gin.Context) {
 			"img_src":   admin.ImgSrc,
 			"role":      admin.RolesName,
 		},
-	}, "登录成功")
+	}, nil
+}
+
+// 判断管理员的角色是否被所属租户强制要求开启两步验证
+func isMfaForced(db *gorm.DB, admin *models.Admin) bool {
+	return system.InArray(models.GetSettingsList(database.WithTenant(db, admin.TenantId), "mfa_force_roles"), admin.RolesName)
+}
+
+// @Title Refresh
+// @Description 使用刷新令牌换取新的令牌，旧的刷新令牌随即失效
+// @Param	refresh_token	json	string	true	"刷新令牌"
+// @Success 200 {token,refresh_token,expires_in} token string,refresh_token string,expires_in int "刷新成功"
+// @Failure 401 "刷新令牌无效"
+// @router /common/refresh [post]
+func (c commonController) Refresh(ctx *gin.Context) {
+	// 获取参数
+	type param struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Validate(p.RefreshToken, validation.Required); err != nil {
+		response.Error(ctx, "刷新令牌不能为空", http.StatusBadRequest)
+		return
+	}
+	// 换取新的令牌
+	tokens, err := jwt.NewJwt().Refresh(p.RefreshToken)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	// 返回数据
+	response.Success(ctx, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	}, "刷新成功")
+}
+
+// @Title Logout
+// @Description 退出登录，吊销当前访问令牌及其刷新令牌
+// @Param	refresh_token	json	string	false	"刷新令牌"
+// @Success 200 "退出成功"
+// @router /common/logout [post]
+func (c commonController) Logout(ctx *gin.Context) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 获取参数
+	type param struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	var p param
+	_ = ctx.ShouldBindJSON(&p)
+	// 吊销访问令牌
+	if err := jwt.RevokeToken(claim); err != nil {
+		response.Error(ctx, "退出失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 吊销刷新令牌
+	if p.RefreshToken != "" {
+		if err := jwt.RevokeRefreshToken(p.RefreshToken); err != nil {
+			logging.Ctx(ctx).Warn("吊销刷新令牌失败：", err)
+		}
+	}
+	response.Success(ctx, gin.H{}, "退出成功")
 }
 
 // @Title GetUserInfo
@@ -131,27 +321,99 @@ func (c commonController) GetUserInfo(ctx *gin.Context) {
 	data["username"] = admin.Username
 	data["nickname"] = admin.Nickname
 	data["img_src"] = admin.ImgSrc
+	data["email"] = admin.Email
 	data["role_name"] = admin.RolesName
+	data["totp_enabled"] = admin.TotpEnabled
 	// 返回数据
 	response.Success(ctx, gin.H{
 		"data": data,
 	}, "获取成功！")
 }
 
+// @Title Permissions
+// @Description 获取当前用户可访问的权限树，以及允许的请求路径和方法，供前端控制菜单和按钮
+// @Success 200 {tree,permissions} tree []*Tree,permissions []gin.H "获取成功"
+// @Failure 0 "获取失败"
+// @router /admin/common/permissions [get]
+func (c commonController) Permissions(ctx *gin.Context) {
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 失效的规则没有对应的路由，不返回
+	var rules []*Tree
+	if err := c.Db.Model(&models.Rules{}).Where("stale = ?", 0).Order("id").Find(&rules).Error; err != nil {
+		response.Error(ctx, "获取权限失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dom := tenant.Domain(ctx)
+	super := acs.IsSuper(claim.UserId, claim.UserRole, dom)
+	// 使用API密钥访问时，同时受密钥权限范围限制
+	key, isApiKey := ctx.Get("apikey")
+	byId := map[int]*Tree{}
+	for _, rule := range rules {
+		byId[rule.ID] = rule
+	}
+	included := map[int]bool{}
+	permissions := []gin.H{}
+	for _, rule := range rules {
+		// 分组节点在有可访问的下级时返回
+		if rule.Method == "" {
+			continue
+		}
+		allowed := super
+		if !allowed {
+			allowed, _ = acs.CachedEnforce(claim.UserId, claim.UserRole, dom, rule.Path, rule.Method)
+		}
+		if allowed && isApiKey {
+			allowed = apikey.Allowed(key.(*models.ApiKey), rule.Method, rule.Path)
+		}
+		if !allowed {
+			continue
+		}
+		permissions = append(permissions, gin.H{"path": rule.Path, "method": rule.Method})
+		for id := rule.ID; id != 0 && !included[id]; {
+			included[id] = true
+			parent, ok := byId[id]
+			if !ok {
+				break
+			}
+			id = parent.Pid
+		}
+	}
+	accessible := []*Tree{}
+	for _, rule := range rules {
+		if included[rule.ID] {
+			accessible = append(accessible, rule)
+		}
+	}
+	tree := treeData(accessible, 0)
+	if tree == nil {
+		tree = []*Tree{}
+	}
+	response.Success(ctx, gin.H{
+		"tree":        tree,
+		"permissions": permissions,
+	}, "获取成功")
+}
+
 // @Title Register
 // @Description 注册
 // @Param	model		json	models.Admin	true	"appid"
 // @Param	appid		json	string	true	"appid"
 // @Param	captcha		json	string	true	"验证码"
-// @Success 200 {token} "注册成功"
+// @Param	invite_code	json	string	false	"邀请码，注册方式为 invite 时必填"
+// @Param	tenant		json	string	false	"租户编码，默认注册到默认租户"
+// @Success 200 {token,refresh_token,expires_in} "注册成功，注册方式为 approval 时返回 pending 且不签发令牌"
 // @Failure 0 "注册失败"
 // @router /common/register [post]
 func (c commonController) Register(ctx *gin.Context) {
 	// 获取参数
 	type param struct {
 		models.Admin
-		Appid   string `json:"appid"`
-		Captcha string `json:"captcha"`
+		// 模型中的密码不参与序列化，单独接收
+		Password   string `json:"password"`
+		Appid      string `json:"appid"`
+		Captcha    string `json:"captcha"`
+		InviteCode string `json:"invite_code"`
+		Tenant     string `json:"tenant"`
 	}
 	// 绑定参数
 	var p param
@@ -159,6 +421,29 @@ func (c commonController) Register(ctx *gin.Context) {
 		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
 		return
 	}
+	// 注册到指定的租户，未指定时注册到默认租户，注册方式以该租户的设置为准
+	tenantId := models.DefaultTenantId
+	if p.Tenant != "" {
+		t, err := models.GetTenantByCode(c.Db, p.Tenant)
+		if err != nil || t.Status != models.TenantStatusActive {
+			response.Error(ctx, "租户不存在或已停用", http.StatusBadRequest)
+			return
+		}
+		tenantId = t.ID
+	}
+	db := database.WithTenant(c.Db, tenantId)
+	// 验证是否开放注册
+	mode := models.GetRegisterMode(db)
+	if mode == models.RegisterModeClosed {
+		response.Error(ctx, "系统未开放注册", http.StatusForbidden)
+		return
+	}
+	if mode == models.RegisterModeInvite {
+		if err := validation.Validate(p.InviteCode, validation.Required); err != nil {
+			response.Error(ctx, "邀请码不能为空", http.StatusBadRequest)
+			return
+		}
+	}
 	// 验证用户名是否为空
 	if err := validation.Validate(p.Username, validation.Required); err != nil {
 		response.Error(ctx, "用户名不能为空！", http.StatusBadRequest)
@@ -174,6 +459,11 @@ func (c commonController) Register(ctx *gin.Context) {
 		response.Error(ctx, "两次输入的密码不一致", http.StatusBadRequest)
 		return
 	}
+	// 验证密码是否符合密码策略
+	if err := password.Validate(p.Password); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
 	// 验证用户昵称是否为空
 	if err := validation.Validate(p.Nickname, validation.Required); err != nil {
 		response.Error(ctx, "用户昵称不能为空！", http.StatusBadRequest)
@@ -189,7 +479,44 @@ func (c commonController) Register(ctx *gin.Context) {
 		response.Error(ctx, "验证码错误!", http.StatusBadRequest)
 		return
 	}
-	// 验证用户名是否已注册
+	// 根据注册方式确定账号的角色和状态
+	data := models.Admin{
+		Username:    p.Username,
+		Nickname:    p.Nickname,
+		Sex:         p.Sex,
+		Description: p.Description,
+		Department:  p.Department,
+		ImgSrc:      p.ImgSrc,
+		Telephone:   p.Telephone,
+		Email:       p.Email,
+		Status:      models.AdminStatusEnabled,
+	}
+	var invitation *models.Invitation
+	var err error
+	switch mode {
+	case models.RegisterModeInvite:
+		// 验证邀请码，邀请码未指定角色时使用默认角色
+		if invitation, err = models.FindInvitation(db, p.InviteCode); err != nil {
+			response.Error(ctx, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data.RolesName = invitation.RolesName
+		if data.RolesName == "" {
+			if data.RolesName, err = models.GetRegisterDefaultRole(db); err != nil {
+				response.Error(ctx, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	case models.RegisterModeOpen:
+		if data.RolesName, err = models.GetRegisterDefaultRole(db); err != nil {
+			response.Error(ctx, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case models.RegisterModeApproval:
+		// 等待超级管理员审核并分配角色
+		data.Status = models.AdminStatusPending
+	}
+	// 验证用户名是否已注册，用户名和手机号在所有租户中唯一
 	if models.IsExistAdminByUsername(c.Db, p.Username) {
 		response.Error(ctx, "该用户名已被注册！", http.StatusBadRequest)
 		return
@@ -199,35 +526,47 @@ func (c commonController) Register(ctx *gin.Context) {
 		response.Error(ctx, "该手机号已被注册！", http.StatusBadRequest)
 		return
 	}
-	data := models.Admin{
-		Username:    p.Username,
-		Password:    md5.Encry(p.ConfirmPassword),
-		Nickname:    p.Nickname,
-		Sex:         p.Sex,
-		Description: p.Description,
-		Department:  p.Department,
-		ImgSrc:      p.ImgSrc,
-		Telephone:   p.Telephone,
-		Status:      1,
-		RolesName:   "admin",
+	// 生成密码哈希
+	hash, err := password.Hash(p.Password)
+	if err != nil {
+		response.Error(ctx, "生成密码失败！", http.StatusInternalServerError)
+		return
 	}
-	add, err := Db.InsertGetId(c.Db, "admin", &data)
+	data.Password = hash
+	// 使用邀请码、创建用户并记录历史密码
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if invitation != nil {
+			if err := models.UseInvitation(tx, invitation); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&data).Error; err != nil {
+			return err
+		}
+		return models.AddPasswordHistory(tx, data.ID, hash)
+	})
 	if err != nil {
 		response.Error(ctx, "创建用户失败："+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// 需要审核的账号暂不签发令牌
+	if data.Status == models.AdminStatusPending {
+		response.Success(ctx, gin.H{"pending": true}, "注册成功，请等待管理员审核")
+		return
+	}
 	// 获取token
 	// 声明jwt
 	j := jwt.NewJwt()
-	data.ID = add
-	token, err := j.CreateToken(&data)
+	tokens, err := j.IssueTokens(&data)
 	if err != nil {
 		response.Error(ctx, "生成token失败!", http.StatusInternalServerError)
 		return
 	}
 	// 返回数据
 	response.Success(ctx, gin.H{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	}, "注册成功!")
 }
 
@@ -255,6 +594,14 @@ func NewCommonController() *commonController {
 	db := database.GetDB()
 	// 初始化Admin表
 	models.InitAdminTable(db)
+	// 初始化历史密码表
+	models.InitPasswordHistoryTable(db)
+	// 初始化令牌表
+	models.InitTokenTables(db)
+	// 初始化登录日志表
+	models.InitLoginLogTable(db)
+	// 初始化邀请码表
+	models.InitInvitationTable(db)
 
 	return &commonController{
 		Db:    db,
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"FlyCloud/pkg/response"
+	"FlyCloud/serves/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Title ConfigController
+// @Description 配置管理控制器，仅平台管理员可用
+
+// 定义配置管理控制器
+type ConfigController interface {
+	Select(ctx *gin.Context)
+}
+
+// 定义配置管理控制器
+type configController struct{}
+
+// 实例化配置管理控制器
+func NewConfigController() *configController {
+	return &configController{}
+}
+
+// @Title Select
+// @Description 获取当前生效的配置，密码、密钥等敏感配置已隐藏
+// @Success 200 {config} config object "当前生效的配置"
+// @Success 200 {reloaded_at} reloaded_at string "最后一次加载配置的时间"
+// @router /admin/config [get]
+func (c *configController) Select(ctx *gin.Context) {
+	if !platformOnly(ctx, "查看配置") {
+		return
+	}
+	response.Success(ctx, gin.H{
+		"file":        config.File(),
+		"config":      config.GetConfig().Redacted(),
+		"reloaded_at": config.ReloadedAt(),
+	}, "获取成功")
+}
@@ -3,7 +3,9 @@ package controller
 import (
 	"FlyCloud/application"
 	"FlyCloud/models"
+	"FlyCloud/pkg/jwt"
 	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/tenant"
 	"FlyCloud/serves/cache"
 	"FlyCloud/serves/database"
 	"github.com/allegro/bigcache"
@@ -34,7 +36,7 @@ func (c *CustomerControllerImpl) GetAll(ctx *gin.Context) {
 		customers []models.Customer
 		total     int
 	)
-	if err := c.Db.Model(&models.Customer{}).Count(&total).Error; err != nil {
+	if err := tenant.DB(ctx, c.Db).Model(&models.Customer{}).Count(&total).Error; err != nil {
 		response.Error(ctx, "服务器错误："+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -62,6 +64,7 @@ func (c *CustomerControllerImpl) Insert(ctx *gin.Context) {
 	}
 	// 将客户信息放入struct中
 	Customer.Status = 1
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
 	// 插入数据库
 	newCustomer := models.Customer{
 		Name:    Customer.Name,
@@ -72,8 +75,10 @@ func (c *CustomerControllerImpl) Insert(ctx *gin.Context) {
 		Company: Customer.Company,
 		Notes:   Customer.Notes,
 	}
+	// 记录创建人
+	newCustomer.CreatedBy = claim.UserId
 	// 插入数据库
-	if err := c.Db.Create(&newCustomer).Error; err != nil {
+	if err := tenant.DB(ctx, c.Db).Create(&newCustomer).Error; err != nil {
 		response.Error(ctx, "新增客户失败："+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -106,9 +111,16 @@ func (c *CustomerControllerImpl) Update(ctx *gin.Context) {
 	}
 	// 将客户信息放入struct中
 	Customer.Status = 1
+	// 创建人不随编辑变更
+	Customer.CreatedBy = 0
 	// 更新数据库
-	if err := c.Db.Model(&Customer).Where("id = ?", CustomerId).Updates(Customer).Error; err != nil {
-		response.Error(ctx, "更新客户失败："+err.Error(), http.StatusBadRequest)
+	result := tenant.DB(ctx, c.Db).Model(&models.Customer{}).Where("id = ?", CustomerId).Updates(Customer)
+	if result.Error != nil {
+		response.Error(ctx, "更新客户失败："+result.Error.Error(), http.StatusBadRequest)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, "客户不存在或无权操作", http.StatusBadRequest)
 		return
 	}
 	// 返回数据
@@ -127,8 +139,13 @@ func (c *CustomerControllerImpl) Delete(ctx *gin.Context) {
 	// 从参数中获取客户id
 	CustomerId := ctx.Param("id")
 	// 删除数据库
-	if err := c.Db.Where("id = ?", CustomerId).Delete(&models.Customer{}).Error; err != nil {
-		response.Error(ctx, "删除客户失败："+err.Error(), http.StatusBadRequest)
+	result := tenant.DB(ctx, c.Db).Where("id = ?", CustomerId).Delete(&models.Customer{})
+	if result.Error != nil {
+		response.Error(ctx, "删除客户失败："+result.Error.Error(), http.StatusBadRequest)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, "客户不存在或无权操作", http.StatusBadRequest)
 		return
 	}
 	// 返回数据
@@ -148,7 +165,7 @@ func (c *CustomerControllerImpl) Find(ctx *gin.Context) {
 	CustomerId := ctx.Param("id")
 	// 查询数据库
 	var Customer models.Customer
-	if err := c.Db.Where("id = ?", CustomerId).First(&Customer).Error; err != nil {
+	if err := tenant.DB(ctx, c.Db).Where("id = ?", CustomerId).First(&Customer).Error; err != nil {
 		response.Error(ctx, "查询客户失败："+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -176,8 +193,8 @@ func (c *CustomerControllerImpl) Select(ctx *gin.Context) {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// 声明查询对象
-	var query = c.Db.Model(&models.Customer{})
+	// 声明查询对象，只查询数据范围内的客户
+	var query = tenant.DB(ctx, c.Db).Model(&models.Customer{})
 	// 查询条件
 	if model.Name != "" { // 姓名,模糊查询
 		query = query.Where("name like ?", "%"+model.Name+"%")
@@ -201,7 +218,7 @@ func (c *CustomerControllerImpl) Select(ctx *gin.Context) {
 	var data []models.Customer
 	var total int
 	// 查询数据
-	if err := c.Db.Model(&models.Customer{}).Where(model).Count(&total).Offset((model.PageNum - 1) * model.PageSize).Limit(model.PageSize).Find(&data).Error; err != nil {
+	if err := query.Count(&total).Offset((model.PageNum - 1) * model.PageSize).Limit(model.PageSize).Find(&data).Error; err != nil {
 		response.Error(ctx, "查询失败:"+err.Error(), http.StatusInternalServerError)
 		return
 	}
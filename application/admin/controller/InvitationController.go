@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/tenant"
+	"FlyCloud/serves/cache"
+	acs "FlyCloud/serves/casbin"
+	"FlyCloud/serves/database"
+	"net/http"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title InvitationController
+// @Description 注册邀请码控制器
+
+// 定义邀请码控制器
+type InvitationController interface {
+	Insert(ctx *gin.Context)
+	Select(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+// 定义邀请码控制器
+type invitationController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 实例化邀请码控制器
+func NewInvitationController() *invitationController {
+	db := database.GetDB()
+	// 初始化邀请码表
+	models.InitInvitationTable(db)
+	return &invitationController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Insert
+// @Description 生成邀请码，明文邀请码只在此时返回一次
+// @Param	roles_name	json	string	false	"注册后分配的角色，为空时使用注册默认角色"
+// @Param	max_uses	json	int		false	"可使用次数，默认1次"
+// @Param	expires_in	json	int		false	"有效期，单位小时，0表示不过期"
+// @Param	remark		json	string	false	"备注"
+// @Success 200 {code,data} code string,data models.Invitation "生成成功"
+// @Failure 0 "生成失败"
+// @router /admin/invitation/add [post]
+func (c *invitationController) Insert(ctx *gin.Context) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 获取参数
+	type param struct {
+		RolesName string `json:"roles_name"`
+		MaxUses   int    `json:"max_uses"`
+		ExpiresIn int    `json:"expires_in"`
+		Remark    string `json:"remark"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 验证角色是否存在，只有超级管理员可以邀请超级管理员
+	if p.RolesName != "" {
+		if !models.IsExistRole(tenant.DB(ctx, c.Db), p.RolesName) {
+			response.Error(ctx, "角色不存在", http.StatusBadRequest)
+			return
+		}
+		if p.RolesName == "super" && !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
+			response.Error(ctx, "没有权限邀请超级管理员", http.StatusForbidden)
+			return
+		}
+	}
+	invitation := models.Invitation{
+		RolesName: p.RolesName,
+		MaxUses:   p.MaxUses,
+		CreatedBy: claim.UserId,
+		Remark:    p.Remark,
+	}
+	if p.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(p.ExpiresIn) * time.Hour)
+		invitation.ExpiresAt = &expiresAt
+	}
+	code, err := models.CreateInvitation(tenant.DB(ctx, c.Db), &invitation)
+	if err != nil {
+		response.Error(ctx, "生成邀请码失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{"code": code, "data": invitation}, "生成成功，请妥善保存邀请码")
+}
+
+// @Title Select
+// @Description 查询邀请码列表
+// @Param model json models.Invitation true "查询条件"
+// @Success 200 {data,count} data []models.Invitation,count int "获取成功"
+// @Failure 0 "获取失败"
+// @router /admin/invitation/list [post]
+func (c *invitationController) Select(ctx *gin.Context) {
+	// 获取查询条件
+	var model models.Invitation
+	if err := ctx.ShouldBindJSON(&model); err != nil {
+		response.Error(ctx, "获取查询条件失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 默认分页
+	if model.PageNum <= 0 {
+		model.PageNum = 1
+	}
+	if model.PageSize <= 0 {
+		model.PageSize = 20
+	}
+	db := tenant.DB(ctx, c.Db).Model(&models.Invitation{})
+	if model.RolesName != "" {
+		db = db.Where("roles_name = ?", model.RolesName)
+	}
+	if model.Prefix != "" {
+		db = db.Where("prefix = ?", model.Prefix)
+	}
+	var count int
+	var data []models.Invitation
+	// 查询数据，并分页
+	if err := db.Count(&count).Order("id desc").Limit(model.PageSize).Offset((model.PageNum - 1) * model.PageSize).Find(&data).Error; err != nil {
+		response.Error(ctx, "获取数据失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response.Success(ctx, gin.H{"data": data, "count": count}, "获取数据成功")
+}
+
+// @Title Delete
+// @Description 删除邀请码，删除后无法再用于注册
+// @Param id path int true "邀请码id"
+// @Success 200 "删除成功"
+// @Failure 0 "删除失败"
+// @router /admin/invitation/delete/:id [delete]
+func (c *invitationController) Delete(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := tenant.DB(ctx, c.Db).Where("id = ?", id).Delete(&models.Invitation{}).Error; err != nil {
+		response.Error(ctx, "删除失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{}, "删除成功")
+}
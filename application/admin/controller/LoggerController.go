@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/response"
+	"FlyCloud/serves/logging"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Title LoggerController
+// @Description 日志管理控制器，仅平台管理员可用
+
+// 定义日志管理控制器
+type LoggerController interface {
+	GetLevel(ctx *gin.Context)
+	SetLevel(ctx *gin.Context)
+}
+
+// 定义日志管理控制器
+type loggerController struct{}
+
+// 实例化日志管理控制器
+func NewLoggerController() *loggerController {
+	return &loggerController{}
+}
+
+// @Title GetLevel
+// @Description 获取当前实例的日志级别
+// @Success 200 {level} level string "日志级别"
+// @router /admin/logger/level [get]
+func (c *loggerController) GetLevel(ctx *gin.Context) {
+	if !platformOnly(ctx, "管理日志") {
+		return
+	}
+	response.Success(ctx, gin.H{"level": logging.Level()}, "获取成功")
+}
+
+// @Title SetLevel
+// @Description 修改当前实例的日志级别，立即生效，重启后恢复为配置文件中的级别
+// @Param	level	json	string	true	"日志级别，可选 debug、info、warn、error"
+// @Success 200 {level} level string "修改后的日志级别"
+// @router /admin/logger/level [put]
+func (c *loggerController) SetLevel(ctx *gin.Context) {
+	if !platformOnly(ctx, "管理日志") {
+		return
+	}
+	var p struct {
+		Level string `json:"level"`
+	}
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	old := logging.Level()
+	if err := logging.SetLevel(p.Level); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	logging.Ctx(ctx).Warnw("日志级别已修改", "old", old, "level", logging.Level(), "user_id", claim.UserId)
+	response.Success(ctx, gin.H{"level": logging.Level()}, "修改成功")
+}
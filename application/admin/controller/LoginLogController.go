@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/tenant"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"net/http"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title LoginLogController
+// @Description 登录日志控制器
+
+// 定义登录日志控制器
+type LoginLogController interface {
+	Select(ctx *gin.Context)
+}
+
+// 定义登录日志控制器
+type loginLogController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 实例化登录日志控制器
+func NewLoginLogController() *loginLogController {
+	db := database.GetDB()
+	// 初始化登录日志表
+	models.InitLoginLogTable(db)
+	return &loginLogController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Select
+// @Description 查询登录日志，按时间倒序
+// @Param model json models.LoginLog true "查询条件"
+// @Success 200 {data,count} data []models.LoginLog,count int "获取成功"
+// @Failure 0 "获取失败"
+// @router /admin/loginLog/list [post]
+func (c *loginLogController) Select(ctx *gin.Context) {
+	// 获取查询条件
+	var model models.LoginLog
+	if err := ctx.ShouldBindJSON(&model); err != nil {
+		response.Error(ctx, "获取查询条件失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 默认分页
+	if model.PageNum <= 0 {
+		model.PageNum = 1
+	}
+	if model.PageSize <= 0 {
+		model.PageSize = 20
+	}
+	// 调用登录日志模型的 Filter 方法
+	db := model.Filter(c.Db.Model(&models.LoginLog{}))
+	// 登录日志不区分租户，平台管理员以外只能查看本租户管理员的日志
+	if !tenant.IsPlatformAdmin(ctx) {
+		db = db.Where("admin_id in (select id from admin where tenant_id = ?)", tenant.Id(ctx))
+	}
+	// 获取总数
+	var count int
+	var data []models.LoginLog
+	// 查询数据，并分页
+	if err := db.Count(&count).Order("id desc").Limit(model.PageSize).Offset((model.PageNum - 1) * model.PageSize).Find(&data).Error; err != nil {
+		response.Error(ctx, "获取数据失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 返回数据
+	response.Success(ctx, gin.H{"data": data, "count": count}, "获取数据成功")
+}
@@ -0,0 +1,374 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/lockout"
+	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/totp"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/jinzhu/gorm"
+	"github.com/skip2/go-qrcode"
+)
+
+// @Title MfaController
+// @Description 两步验证控制器
+
+// 恢复码数量
+const recoveryCodeCount = 10
+
+// 定义两步验证控制器
+type MfaController interface {
+	Setup(ctx *gin.Context)
+	QrCode(ctx *gin.Context)
+	Confirm(ctx *gin.Context)
+	Verify(ctx *gin.Context)
+	Disable(ctx *gin.Context)
+	RecoveryCodes(ctx *gin.Context)
+}
+
+// 定义两步验证控制器
+type mfaController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 实例化两步验证控制器
+func NewMfaController() *mfaController {
+	db := database.GetDB()
+	// 初始化恢复码表
+	models.InitRecoveryCodeTable(db)
+	return &mfaController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Setup
+// @Description 生成两步验证密钥，返回otpauth地址和二维码，需调用confirm确认后才会生效
+// @Success 200 {secret,uri,qrcode} secret string,uri string,qrcode string "生成成功"
+// @Failure 0 "生成失败"
+// @router /common/mfa/setup [post]
+func (c *mfaController) Setup(ctx *gin.Context) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	var admin models.Admin
+	if err := c.Db.First(&admin, "id = ?", claim.UserId).Error; err != nil {
+		response.Error(ctx, "获取用户信息失败!", http.StatusBadRequest)
+		return
+	}
+	if admin.TotpEnabled == 1 {
+		response.Error(ctx, "已开启两步验证", http.StatusBadRequest)
+		return
+	}
+	// 生成密钥，确认前不生效
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		response.Error(ctx, "生成密钥失败!", http.StatusInternalServerError)
+		return
+	}
+	if err := c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		response.Error(ctx, "保存密钥失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 生成二维码
+	uri := totp.URI(c.issuer(admin.TenantId), admin.Username, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		response.Error(ctx, "生成二维码失败!", http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{
+		"secret": secret,
+		"uri":    uri,
+		"qrcode": "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, "生成成功")
+}
+
+// @Title QrCode
+// @Description 获取待确认密钥的二维码图片
+// @Success 200 {image/png} "二维码"
+// @Failure 0 "获取失败"
+// @router /common/mfa/qrcode [get]
+func (c *mfaController) QrCode(ctx *gin.Context) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	var admin models.Admin
+	if err := c.Db.First(&admin, "id = ?", claim.UserId).Error; err != nil {
+		response.Error(ctx, "获取用户信息失败!", http.StatusBadRequest)
+		return
+	}
+	if admin.TotpSecret == "" || admin.TotpEnabled == 1 {
+		response.Error(ctx, "请先生成两步验证密钥", http.StatusBadRequest)
+		return
+	}
+	png, err := qrcode.Encode(totp.URI(c.issuer(admin.TenantId), admin.Username, admin.TotpSecret), qrcode.Medium, 256)
+	if err != nil {
+		response.Error(ctx, "生成二维码失败!", http.StatusInternalServerError)
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	// 跨域中间件已设置了JSON类型，这里需要覆盖
+	ctx.Header("Content-Type", "image/png")
+	ctx.Data(http.StatusOK, "image/png", png)
+}
+
+// @Title Confirm
+// @Description 输入验证器App中的验证码确认开启两步验证，返回恢复码。使用临时令牌绑定时同时完成登录
+// @Param	code	json	string	true	"验证码"
+// @Success 200 {recovery_codes} recovery_codes []string "开启成功"
+// @Failure 0 "开启失败"
+// @router /common/mfa/confirm [post]
+func (c *mfaController) Confirm(ctx *gin.Context) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 获取参数
+	type param struct {
+		Code string `json:"code"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var admin models.Admin
+	if err := c.Db.First(&admin, "id = ?", claim.UserId).Error; err != nil {
+		response.Error(ctx, "获取用户信息失败!", http.StatusBadRequest)
+		return
+	}
+	if admin.TotpEnabled == 1 {
+		response.Error(ctx, "已开启两步验证", http.StatusBadRequest)
+		return
+	}
+	if admin.TotpSecret == "" {
+		response.Error(ctx, "请先生成两步验证密钥", http.StatusBadRequest)
+		return
+	}
+	step, ok := totp.Validate(admin.TotpSecret, p.Code, admin.TotpLastStep)
+	if !ok {
+		response.Error(ctx, "验证码错误!", http.StatusBadRequest)
+		return
+	}
+	// 开启两步验证
+	if err := c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Updates(map[string]interface{}{
+		"totp_enabled":   1,
+		"totp_last_step": step,
+	}).Error; err != nil {
+		response.Error(ctx, "开启两步验证失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 生成恢复码
+	codes, err := models.GenerateRecoveryCodes(c.Db, admin.ID, recoveryCodeCount)
+	if err != nil {
+		response.Error(ctx, "生成恢复码失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := gin.H{}
+	// 通过临时令牌绑定时，绑定完成即登录成功
+	if claim.TokenType == jwt.TokenTypeMfa {
+		if err := jwt.RevokeToken(claim); err != nil {
+			logging.Ctx(ctx).Error("吊销临时令牌失败：", err)
+		}
+		if data, err = loginData(&admin); err != nil {
+			response.Error(ctx, "生成token失败!", http.StatusInternalServerError)
+			return
+		}
+	}
+	data["recovery_codes"] = codes
+	response.Success(ctx, data, "开启两步验证成功，请妥善保存恢复码")
+}
+
+// @Title Verify
+// @Description 登录第二步：使用临时令牌和验证码或恢复码换取正式令牌
+// @Param	mfa_token		json	string	true	"临时令牌"
+// @Param	code			json	string	false	"验证码"
+// @Param	recovery_code	json	string	false	"恢复码"
+// @Success 200 {token,refresh_token,expires_in,userInfo} "登录成功"
+// @Failure 0 "验证失败"
+// @router /common/mfa/verify [post]
+func (c *mfaController) Verify(ctx *gin.Context) {
+	// 获取参数
+	type param struct {
+		MfaToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Validate(p.MfaToken, validation.Required); err != nil {
+		response.Error(ctx, "临时令牌不能为空", http.StatusBadRequest)
+		return
+	}
+	// 验证临时令牌
+	_, claim, err := jwt.NewJwt().ParseToken(p.MfaToken)
+	if err != nil || claim.TokenType != jwt.TokenTypeMfa || claim.MfaSetup || jwt.IsRevoked(claim) {
+		response.Error(ctx, "临时令牌无效或已过期", http.StatusUnauthorized)
+		return
+	}
+	var admin models.Admin
+	if err := c.Db.First(&admin, "id = ?", claim.UserId).Error; err != nil || admin.TotpEnabled != 1 {
+		response.Error(ctx, "临时令牌无效或已过期", http.StatusUnauthorized)
+		return
+	}
+	if admin.Status != models.AdminStatusEnabled {
+		response.Error(ctx, "账号已被禁用!", http.StatusForbidden)
+		return
+	}
+	// 验证码同样计入登录失败次数，防止在临时令牌有效期内暴力猜测
+	ip := ctx.ClientIP()
+	limit := lockout.Check(admin.Username, ip)
+	if limit == nil && admin.LockedUntil > time.Now().Unix() {
+		limit = &lockout.LimitError{Locked: true, RetryAfter: time.Until(time.Unix(admin.LockedUntil, 0))}
+	}
+	if limit != nil {
+		addLoginLog(ctx, c.Db, admin.ID, admin.Username, models.LoginResultLocked, limit.Error())
+		limitError(ctx, limit)
+		return
+	}
+	// 校验验证码或恢复码
+	if p.Code != "" {
+		step, ok := totp.Validate(admin.TotpSecret, p.Code, admin.TotpLastStep)
+		if !ok {
+			c.fail(ctx, &admin, "验证码错误!")
+			return
+		}
+		// 记录已使用的时间步，条件更新防止同一验证码被并发重放
+		result := c.Db.Model(&models.Admin{}).Where("id = ? and totp_last_step < ?", admin.ID, step).Update("totp_last_step", step)
+		if result.Error != nil || result.RowsAffected == 0 {
+			c.fail(ctx, &admin, "验证码已使用!")
+			return
+		}
+	} else if p.RecoveryCode != "" {
+		if !models.UseRecoveryCode(c.Db, admin.ID, p.RecoveryCode) {
+			c.fail(ctx, &admin, "恢复码错误!")
+			return
+		}
+	} else {
+		response.Error(ctx, "验证码不能为空", http.StatusBadRequest)
+		return
+	}
+	// 临时令牌只能使用一次
+	if err := jwt.RevokeToken(claim); err != nil {
+		logging.Ctx(ctx).Error("吊销临时令牌失败：", err)
+	}
+	lockout.Succeed(admin.Username)
+	data, err := loginData(&admin)
+	if err != nil {
+		response.Error(ctx, "生成token失败!", http.StatusInternalServerError)
+		return
+	}
+	addLoginLog(ctx, c.Db, admin.ID, admin.Username, models.LoginResultSuccess, "两步验证通过")
+	response.Success(ctx, data, "登录成功")
+}
+
+// 两步验证失败，记录失败次数和登录日志
+func (c *mfaController) fail(ctx *gin.Context, admin *models.Admin, message string) {
+	if lockedUntil := lockout.Fail(admin.Username, ctx.ClientIP()); !lockedUntil.IsZero() {
+		c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Update("locked_until", lockedUntil.Unix())
+	}
+	addLoginLog(ctx, c.Db, admin.ID, admin.Username, models.LoginResultFailed, message)
+	response.Error(ctx, message, http.StatusBadRequest)
+}
+
+// @Title Disable
+// @Description 关闭两步验证，强制开启两步验证的角色不能关闭
+// @Param	code	json	string	true	"验证码"
+// @Success 200 "关闭成功"
+// @Failure 0 "关闭失败"
+// @router /common/mfa/disable [post]
+func (c *mfaController) Disable(ctx *gin.Context) {
+	admin, ok := c.checkCode(ctx)
+	if !ok {
+		return
+	}
+	if isMfaForced(c.Db, admin) {
+		response.Error(ctx, "当前角色必须开启两步验证", http.StatusBadRequest)
+		return
+	}
+	if err := c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Updates(map[string]interface{}{
+		"totp_enabled":   0,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		response.Error(ctx, "关闭两步验证失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := models.DeleteRecoveryCodes(c.Db, admin.ID); err != nil {
+		response.Error(ctx, "删除恢复码失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{}, "关闭两步验证成功")
+}
+
+// @Title RecoveryCodes
+// @Description 重新生成恢复码，旧的恢复码全部作废
+// @Param	code	json	string	true	"验证码"
+// @Success 200 {recovery_codes} recovery_codes []string "生成成功"
+// @Failure 0 "生成失败"
+// @router /common/mfa/recovery [post]
+func (c *mfaController) RecoveryCodes(ctx *gin.Context) {
+	admin, ok := c.checkCode(ctx)
+	if !ok {
+		return
+	}
+	codes, err := models.GenerateRecoveryCodes(c.Db, admin.ID, recoveryCodeCount)
+	if err != nil {
+		response.Error(ctx, "生成恢复码失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{"recovery_codes": codes}, "生成成功，请妥善保存恢复码")
+}
+
+// 校验当前用户已开启两步验证且提交的验证码正确
+func (c *mfaController) checkCode(ctx *gin.Context) (*models.Admin, bool) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 获取参数
+	type param struct {
+		Code string `json:"code"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	var admin models.Admin
+	if err := c.Db.First(&admin, "id = ?", claim.UserId).Error; err != nil {
+		response.Error(ctx, "获取用户信息失败!", http.StatusBadRequest)
+		return nil, false
+	}
+	if admin.TotpEnabled != 1 {
+		response.Error(ctx, "未开启两步验证", http.StatusBadRequest)
+		return nil, false
+	}
+	step, ok := totp.Validate(admin.TotpSecret, p.Code, admin.TotpLastStep)
+	if !ok {
+		response.Error(ctx, "验证码错误!", http.StatusBadRequest)
+		return nil, false
+	}
+	c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Update("totp_last_step", step)
+	return &admin, true
+}
+
+// 验证器App中显示的发行方名称，使用管理员所属租户的站点名称
+func (c *mfaController) issuer(tenantId uint) string {
+	if settings, err := models.GetSettingsByKey(database.WithTenant(c.Db, tenantId), "site_name"); err == nil && settings.Val != "" {
+		return settings.Val
+	}
+	return "FlyCloud"
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/oidc"
+	"FlyCloud/pkg/response"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"errors"
+	"net/http"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title OidcController
+// @Description OpenID Connect单点登录控制器
+
+// 定义单点登录控制器
+type OidcController interface {
+	Authorize(ctx *gin.Context)
+	Callback(ctx *gin.Context)
+}
+
+// 定义单点登录控制器
+type oidcController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 实例化单点登录控制器
+func NewOidcController() *oidcController {
+	db := database.GetDB()
+	// 初始化外部身份表
+	models.InitAdminIdentityTable(db)
+	return &oidcController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Authorize
+// @Description 获取身份提供方的授权地址，前端跳转到该地址完成登录
+// @Success 200 {url,state} url string,state string "获取成功"
+// @Failure 0 "获取失败"
+// @router /admin/common/oidc/authorize [get]
+func (c *oidcController) Authorize(ctx *gin.Context) {
+	url, state, err := oidc.AuthURL()
+	if err != nil {
+		if errors.Is(err, oidc.ErrDisabled) {
+			response.Error(ctx, err.Error(), http.StatusNotFound)
+			return
+		}
+		logging.Ctx(ctx).Error("生成单点登录地址失败：", err)
+		response.Error(ctx, "单点登录暂时不可用", http.StatusBadGateway)
+		return
+	}
+	response.Success(ctx, gin.H{"url": url, "state": state}, "获取成功")
+}
+
+// @Title Callback
+// @Description 身份提供方回调后，前端提交授权码完成登录，登录成功后的返回与账号密码登录一致
+// @Param	code	json	string	true	"授权码"
+// @Param	state	json	string	true	"授权地址中的state"
+// @Success 200 {token,refresh_token,expires_in,userInfo} token string,refresh_token string,expires_in int,userInfo gin.H "登录成功"
+// @Failure 0 "登录失败"
+// @router /admin/common/oidc/callback [post]
+func (c *oidcController) Callback(ctx *gin.Context) {
+	// 获取参数
+	type param struct {
+		Code  string `json:"code" binding:"required"`
+		State string `json:"state" binding:"required"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 使用授权码换取身份信息
+	claims, err := oidc.Exchange(p.Code, p.State)
+	if err != nil {
+		addLoginLog(ctx, c.Db, 0, "", models.LoginResultFailed, "单点登录失败："+err.Error())
+		response.Error(ctx, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	// 查找或创建对应的管理员
+	admin, err := oidc.ResolveAdmin(c.Db, claims)
+	if err != nil {
+		addLoginLog(ctx, c.Db, 0, claims.Email, models.LoginResultFailed, "单点登录失败："+err.Error())
+		response.Error(ctx, err.Error(), http.StatusForbidden)
+		return
+	}
+	// 验证账号是否已被禁用
+	if !checkStatus(ctx, c.Db, admin, admin.Username) {
+		return
+	}
+	// 签发令牌
+	finishLogin(ctx, c.Db, admin, admin.Username)
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/captcha"
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/lockout"
+	"FlyCloud/pkg/notify"
+	"FlyCloud/pkg/password"
+	"FlyCloud/pkg/response"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/config"
+	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title PasswordController
+// @Description 修改密码及找回密码控制器
+
+// 定义密码控制器
+type PasswordController interface {
+	Change(ctx *gin.Context)
+	Forgot(ctx *gin.Context)
+	Reset(ctx *gin.Context)
+}
+
+// 定义密码控制器
+type passwordController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 实例化密码控制器
+func NewPasswordController() *passwordController {
+	db := database.GetDB()
+	// 初始化找回密码令牌表
+	models.InitPasswordResetTable(db)
+	return &passwordController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Change
+// @Description 修改自己的密码，需要验证旧密码。修改成功后其它会话全部失效，并返回新的令牌
+// @Param	old_password		json	string	true	"旧密码"
+// @Param	password			json	string	true	"新密码"
+// @Param	confirm_password	json	string	true	"确认密码"
+// @Success 200 {token,refresh_token,expires_in,userInfo} "修改成功"
+// @Failure 0 "修改失败"
+// @router /common/password/change [post]
+func (c *passwordController) Change(ctx *gin.Context) {
+	// 从ctx中获取claim
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	// 获取参数
+	type param struct {
+		OldPassword     string `json:"old_password"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirm_password"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Validate(p.OldPassword, validation.Required); err != nil {
+		response.Error(ctx, "旧密码不能为空", http.StatusBadRequest)
+		return
+	}
+	if p.Password != p.ConfirmPassword {
+		response.Error(ctx, "两次输入的密码不一致", http.StatusBadRequest)
+		return
+	}
+	var admin models.Admin
+	if err := c.Db.First(&admin, "id = ?", claim.UserId).Error; err != nil {
+		response.Error(ctx, "获取用户信息失败!", http.StatusBadRequest)
+		return
+	}
+	// 旧密码错误同样计入失败次数，防止借助已登录的会话暴力猜测密码
+	if err := lockout.Check(admin.Username, ctx.ClientIP()); err != nil {
+		limitError(ctx, err)
+		return
+	}
+	if !password.Verify(p.OldPassword, admin.Password) {
+		lockout.Fail(admin.Username, ctx.ClientIP())
+		response.Error(ctx, "旧密码错误!", http.StatusBadRequest)
+		return
+	}
+	// 校验密码策略及历史密码，并更新密码
+	if err := models.UpdateAdminPassword(c.Db, &admin, p.Password); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 吊销所有会话，包括当前会话
+	if err := jwt.RevokeAllSessions(admin.ID); err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 签发新的令牌，保持当前登录状态
+	data, err := loginData(&admin)
+	if err != nil {
+		response.Error(ctx, "生成token失败!", http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, data, "修改密码成功")
+}
+
+// @Title Forgot
+// @Description 找回密码，向账号绑定的邮箱或手机号发送重置链接。无论账号是否存在均返回成功，防止探测账号
+// @Param	username	json	string	true	"用户名、手机号或邮箱"
+// @Param	captcha		json	string	true	"验证码"
+// @Param	appid		json	string	true	"appid"
+// @Success 200 "发送成功"
+// @Failure 0 "发送失败"
+// @router /common/password/forgot [post]
+func (c *passwordController) Forgot(ctx *gin.Context) {
+	// 获取参数
+	type param struct {
+		Username string `json:"username"`
+		Captcha  string `json:"captcha"`
+		Appid    string `json:"appid"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Validate(p.Username, validation.Required); err != nil {
+		response.Error(ctx, "用户名不能为空", http.StatusBadRequest)
+		return
+	}
+	// 验证验证码
+	if err := validation.Validate(p.Captcha, validation.Required); err != nil {
+		response.Error(ctx, "验证码不能为空", http.StatusBadRequest)
+		return
+	}
+	if captcha.VerifyCaptcha(p.Appid, p.Captcha) != true {
+		response.Error(ctx, "验证码错误!", http.StatusBadRequest)
+		return
+	}
+	const message = "如果账号存在，重置链接已发送到绑定的邮箱或手机"
+	var admin models.Admin
+	if err := c.Db.Where("username = ? or telephone = ? or (email = ? and email <> '')", p.Username, p.Username, p.Username).First(&admin).Error; err != nil {
+		response.Success(ctx, gin.H{}, message)
+		return
+	}
+	to := notify.Address(admin.Email, admin.Telephone)
+	if to == "" || admin.Status != models.AdminStatusEnabled {
+		response.Success(ctx, gin.H{}, message)
+		return
+	}
+	cfg := resetConfig()
+	token, err := models.CreatePasswordReset(c.Db, admin.ID, time.Duration(cfg.ResetExpiresAt)*time.Minute)
+	if err != nil {
+		response.Error(ctx, "生成重置链接失败!", http.StatusInternalServerError)
+		return
+	}
+	body := "您正在找回 " + admin.Username + " 的密码，请在" + strconv.Itoa(cfg.ResetExpiresAt) + "分钟内打开以下链接设置新密码：\n" +
+		cfg.ResetUrl + token + "\n如果这不是您本人的操作，请忽略此消息。"
+	// 发送失败时只记录日志，避免泄露账号是否存在
+	if err := notify.Send(to, "找回密码", body); err != nil {
+		logging.Ctx(ctx).Error("发送找回密码通知失败：", err)
+	}
+	response.Success(ctx, gin.H{}, message)
+}
+
+// @Title Reset
+// @Description 使用找回密码令牌设置新密码，令牌仅能使用一次，成功后所有会话失效并解除账号锁定
+// @Param	token				json	string	true	"重置令牌"
+// @Param	password			json	string	true	"新密码"
+// @Param	confirm_password	json	string	true	"确认密码"
+// @Success 200 "重置成功"
+// @Failure 0 "重置失败"
+// @router /common/password/reset [post]
+func (c *passwordController) Reset(ctx *gin.Context) {
+	// 获取参数
+	type param struct {
+		Token           string `json:"token"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirm_password"`
+	}
+	var p param
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Validate(p.Token, validation.Required); err != nil {
+		response.Error(ctx, models.ErrResetTokenInvalid.Error(), http.StatusBadRequest)
+		return
+	}
+	if p.Password != p.ConfirmPassword {
+		response.Error(ctx, "两次输入的密码不一致", http.StatusBadRequest)
+		return
+	}
+	// 先校验密码策略，避免因密码不合规而浪费一次性令牌
+	if err := password.Validate(p.Password); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var admin models.Admin
+	err := c.Db.Transaction(func(tx *gorm.DB) error {
+		adminId, err := models.UsePasswordReset(tx, p.Token)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&admin, "id = ?", adminId).Error; err != nil {
+			return models.ErrResetTokenInvalid
+		}
+		return models.UpdateAdminPassword(tx, &admin, p.Password)
+	})
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 吊销所有会话，并解除锁定
+	if err := jwt.RevokeAllSessions(admin.ID); err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.Db.Model(&models.Admin{}).Where("id = ?", admin.ID).Update("locked_until", 0)
+	lockout.Unlock(admin.Username, admin.Telephone)
+	response.Success(ctx, gin.H{}, "重置密码成功，请使用新密码登录")
+}
+
+// 获取找回密码配置，未配置时使用默认值
+func resetConfig() config.PasswordConfig {
+	cfg := config.PasswordConfig{}
+	if config.GetConfig().PasswordConfig != nil {
+		cfg = *config.GetConfig().PasswordConfig
+	}
+	if cfg.ResetExpiresAt <= 0 {
+		cfg.ResetExpiresAt = 30
+	}
+	return cfg
+}
@@ -4,12 +4,19 @@ import (
 	"FlyCloud/application"
 	"FlyCloud/models"
 	"FlyCloud/pkg/Db"
+	"FlyCloud/pkg/audit"
+	"FlyCloud/pkg/jwt"
 	"FlyCloud/pkg/response"
 	"FlyCloud/pkg/system"
+	"FlyCloud/pkg/tenant"
 	"FlyCloud/serves/cache"
 	acs "FlyCloud/serves/casbin"
 	"FlyCloud/serves/database"
+	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/allegro/bigcache"
 	"github.com/casbin/casbin"
@@ -22,12 +29,18 @@ import (
 type RoleController interface {
 	application.BaseController
 	GetAllRoles(ctx *gin.Context)
+	GetInherits(ctx *gin.Context)
+	SetInherits(ctx *gin.Context)
+	GetDataScope(ctx *gin.Context)
+	SetDataScope(ctx *gin.Context)
+	Explain(ctx *gin.Context)
+	Export(ctx *gin.Context)
+	Import(ctx *gin.Context)
 }
 
 // RoleControllerImpl ...
 type RoleControllerImpl struct {
 	Db    *gorm.DB
-	Acs   *casbin.Enforcer
 	Cache *bigcache.BigCache
 }
 
@@ -40,7 +53,7 @@ func (c *RoleControllerImpl) GetAllRoles(ctx *gin.Context) {
 	var total int
 	var err error
 
-	if err = c.Db.Model(models.Roles{}).Count(&total).Find(&roles).Error; err != nil {
+	if err = tenant.DB(ctx, c.Db).Model(models.Roles{}).Count(&total).Find(&roles).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -65,21 +78,21 @@ func (r RoleControllerImpl) Find(ctx *gin.Context) {
 	var alias = ctx.Param("alias")
 	// 查询
 	var model models.Roles
-	if err := r.Db.First(&model, "alias = ?", alias).Error; err != nil {
+	if err := tenant.DB(ctx, r.Db).First(&model, "alias = ?", alias).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
 	// 从CasbinRule中获取角色别名的权限
 	var casbinRule []gormadapter.CasbinRule
-	if err := r.Db.Where("p_type = ?", "p").Find(&casbinRule, "v0 = ?", alias).Error; err != nil {
+	if err := r.Db.Where("p_type = ? and v1 = ?", "p", tenant.Domain(ctx)).Find(&casbinRule, "v0 = ?", alias).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// 以casbinRule中的v1和v2为条件，查询Rules表中的规则,并获取ids
+	// 以casbinRule中的v2和v3为条件，查询Rules表中的规则,并获取ids
 	var ids []int
 	for _, v := range casbinRule {
 		var rule models.Rules
-		if err := r.Db.First(&rule, "path = ? and method = ?", v.V1, v.V2).Error; err != nil {
+		if err := r.Db.First(&rule, "path = ? and method = ?", v.V2, v.V3).Error; err != nil {
 			response.Error(ctx, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -106,7 +119,7 @@ func (r RoleControllerImpl) Select(ctx *gin.Context) {
 		return
 	}
 	// 多模糊条件查询
-	db := r.Db.Model(models.Roles{})
+	db := tenant.DB(ctx, r.Db).Model(models.Roles{})
 	if model.Alias != "" {
 		db = db.Where("alias LIKE ?", "%"+model.Alias+"%")
 	}
@@ -156,9 +169,15 @@ func (r RoleControllerImpl) Insert(ctx *gin.Context) {
 	if model.Alias == "" {
 		model.Alias = system.RandString(10)
 	}
+	// 角色别名不能与用户主体名称冲突
+	if acs.IsUserSubject(model.Alias) {
+		response.Error(ctx, "角色Alias格式错误", http.StatusBadRequest)
+		return
+	}
 	// 查询是否存在相同的角色alias
 	exist, _ := Db.IsExist(r.Db, "roles", map[string]interface{}{
-		"alias": model.Alias,
+		"tenant_id": tenant.Id(ctx),
+		"alias":     model.Alias,
 	})
 	if exist {
 		response.Error(ctx, "角色Alias已存在", http.StatusBadRequest)
@@ -174,7 +193,7 @@ func (r RoleControllerImpl) Insert(ctx *gin.Context) {
 
 	// 新增角色
 	var id uint
-	if err := r.Db.Model(models.Roles{}).Create(&newRole).Error; err != nil {
+	if err := tenant.DB(ctx, r.Db).Model(models.Roles{}).Create(&newRole).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -188,12 +207,19 @@ func (r RoleControllerImpl) Insert(ctx *gin.Context) {
 			return
 		}
 
-		for _, v := range Permissions {
-			if v.Pid != 0 {
-				r.Acs.AddPolicy(model.Alias, v.Path, v.Method)
+		dom := tenant.Domain(ctx)
+		var after []string
+		_ = acs.Update(func(e *casbin.Enforcer) error {
+			for _, v := range Permissions {
+				// 分组节点没有请求方法，不生成权限
+				if v.Method != "" {
+					e.AddPolicy(model.Alias, dom, v.Path, v.Method)
+				}
 			}
-		}
-
+			after = policyList(e.GetFilteredPolicy(0, model.Alias, dom))
+			return nil
+		})
+		_ = audit.Record(ctx, r.Db, models.AuditActionCreate, "casbin_rule", model.Alias, policyChange("permissions", nil, after))
 	}
 	// 返回结果
 	response.Success(ctx, gin.H{
@@ -227,11 +253,27 @@ func (r RoleControllerImpl) Update(ctx *gin.Context) {
 		return
 	}
 
+	// 按id获取当前租户的角色，权限按数据库中的别名替换，不使用请求中的别名
+	var role models.Roles
+	if err := tenant.DB(ctx, r.Db).First(&role, "id = ?", id).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			response.Error(ctx, "角色不存在", http.StatusNotFound)
+			return
+		}
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 别名为空时按前缀删除权限会匹配所有角色
+	if role.Alias == "" {
+		response.Error(ctx, "角色别名不能为空", http.StatusBadRequest)
+		return
+	}
 	// 如果编辑的角色是超级管理员，则不允许修改
-	if model.Alias == "super" {
+	if role.Alias == "super" {
 		response.Error(ctx, "不允许修改超级管理员", http.StatusBadRequest)
 		return
 	}
+	model.Alias = role.Alias
 
 	// 更新数据
 	update := models.Roles{
@@ -240,7 +282,7 @@ func (r RoleControllerImpl) Update(ctx *gin.Context) {
 	}
 
 	// 更新
-	if err := r.Db.Model(&models.Roles{}).Where("id = ?", id).Update(&update).Error; err != nil {
+	if err := tenant.DB(ctx, r.Db).Model(&role).Update(&update).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -250,34 +292,30 @@ func (r RoleControllerImpl) Update(ctx *gin.Context) {
 		response.Error(ctx, "请选择权限", http.StatusBadRequest)
 		return
 	}
-	// 删除原有权限
-	var bak_rules []gormadapter.CasbinRule
-	if err := r.Db.Model(&gormadapter.CasbinRule{}).Where("v0 = ?", model.Alias).Find(&bak_rules).Error; err != nil {
-		response.Error(ctx, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	for _, v := range bak_rules {
-		cacn := model.Alias + v.V1 + v.V2
-		result := r.Acs.RemovePolicy(model.Alias, v.V1, v.V2)
-		if result {
-			_ = r.Cache.Delete(cacn)
-		}
-	}
 	// 根据Ids从权限菜单中获取权限Path Method,并新增角色权限
-
 	var rules []models.Rules
 	if err := r.Db.Table("menu_rules").Where("id in (?)", model.Ids).Find(&rules).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// 添加新的权限
-	for _, v := range rules {
-		if v.Pid != 0 {
-			r.Acs.AddPolicy(model.Alias, v.Path, v.Method)
-			ids = append(ids, v.ID)
+	// 替换原有权限，完成后所有缓存的权限判断结果失效
+	dom := tenant.Domain(ctx)
+	var before, after []string
+	_ = acs.Update(func(e *casbin.Enforcer) error {
+		before = policyList(e.GetFilteredPolicy(0, role.Alias, dom))
+		e.RemoveFilteredPolicy(0, role.Alias, dom)
+		for _, v := range rules {
+			// 分组节点没有请求方法，不生成权限
+			if v.Method != "" {
+				e.AddPolicy(role.Alias, dom, v.Path, v.Method)
+				ids = append(ids, v.ID)
+			}
 		}
-	}
+		after = policyList(e.GetFilteredPolicy(0, role.Alias, dom))
+		return nil
+	})
+	_ = audit.Record(ctx, r.Db, models.AuditActionUpdate, "casbin_rule", role.Alias, policyChange("permissions", before, after))
 
 	// 返回结果
 	response.Success(ctx, gin.H{
@@ -295,6 +333,11 @@ func (r RoleControllerImpl) Delete(ctx *gin.Context) {
 	// 获取参数
 	var alias = ctx.Param("alias")
 
+	// 别名为空时按前缀删除权限会匹配所有角色
+	if alias == "" {
+		response.Error(ctx, "角色别名不能为空", http.StatusBadRequest)
+		return
+	}
 	// super admin 不能删除
 	if alias == "super" {
 		response.Error(ctx, "超级管理员不能删除", http.StatusBadRequest)
@@ -302,19 +345,31 @@ func (r RoleControllerImpl) Delete(ctx *gin.Context) {
 	}
 
 	// 删除
-	if err := r.Db.Delete(&models.Roles{}, "alias = ?", alias).Error; err != nil {
+	if err := tenant.DB(ctx, r.Db).Delete(&models.Roles{}, "alias = ?", alias).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// 删除角色权限
-	if err := Db.DeleteAll(r.Db, "casbin_rule", map[string]interface{}{
-		"p_type": "p",
-		"v0":     alias,
-	}); err != nil {
-		response.Error(ctx, err.Error(), http.StatusInternalServerError)
-		return
+	// 删除角色权限、角色的继承关系，以及用户拥有的该附加角色
+	dom := tenant.Domain(ctx)
+	var permissions, parents, members []string
+	_ = acs.Update(func(e *casbin.Enforcer) error {
+		permissions = policyList(e.GetFilteredPolicy(0, alias, dom))
+		parents = groupingList(e.GetFilteredGroupingPolicy(0, alias, "", dom))
+		members = memberList(e.GetFilteredGroupingPolicy(1, alias, dom))
+		e.RemoveFilteredPolicy(0, alias, dom)
+		e.RemoveFilteredGroupingPolicy(0, alias, "", dom)
+		e.RemoveFilteredGroupingPolicy(1, alias, dom)
+		return nil
+	})
+	// 记录删除的权限策略，没有策略的角色不记录
+	changes := map[string]audit.Change{}
+	for field, removed := range map[string][]string{"permissions": permissions, "inherits": parents, "members": members} {
+		for k, v := range policyChange(field, removed, nil) {
+			changes[k] = v
+		}
 	}
+	_ = audit.Record(ctx, r.Db, models.AuditActionDelete, "casbin_rule", alias, changes)
 
 	// 返回结果
 	response.Success(ctx, gin.H{
@@ -322,8 +377,371 @@ func (r RoleControllerImpl) Delete(ctx *gin.Context) {
 	}, "删除成功")
 }
 
+// @Title GetInherits
+// @Description 获取角色继承的上级角色
+// @Param	alias	path	string	true	"角色别名"
+// @Success 200 {parents,children,implicit} parents []string,children []string,implicit []string "查询结果"
+// @router /admin/roles/inherits/:alias [get]
+func (r RoleControllerImpl) GetInherits(ctx *gin.Context) {
+	alias := ctx.Param("alias")
+	if !models.IsExistRole(tenant.DB(ctx, r.Db), alias) {
+		response.Error(ctx, "角色不存在", http.StatusBadRequest)
+		return
+	}
+	dom := tenant.Domain(ctx)
+	var parents, implicit []string
+	// 继承该角色的下级角色，不含用户
+	children := []string{}
+	acs.View(func(e *casbin.Enforcer) {
+		parents = e.GetRolesForUserInDomain(alias, dom)
+		users := e.GetUsersForRoleInDomain(alias, dom)
+		for _, v := range users {
+			if !acs.IsUserSubject(v) {
+				children = append(children, v)
+			}
+		}
+		implicit = e.GetImplicitRolesForUser(alias, dom)
+	})
+	response.Success(ctx, gin.H{
+		"parents":  parents,
+		"children": children,
+		"implicit": implicit,
+	}, "查询成功")
+}
+
+// @Title SetInherits
+// @Description 设置角色继承的上级角色，角色拥有上级角色的全部权限
+// @Param	alias	path	string		true	"角色别名"
+// @Param	parents	json	[]string	true	"上级角色别名"
+// @Success 200 {parents} parents []string "设置结果"
+// @router /admin/roles/inherits/:alias [put]
+func (r RoleControllerImpl) SetInherits(ctx *gin.Context) {
+	alias := ctx.Param("alias")
+	if alias == "super" {
+		response.Error(ctx, "不允许修改超级管理员", http.StatusBadRequest)
+		return
+	}
+	if !models.IsExistRole(tenant.DB(ctx, r.Db), alias) {
+		response.Error(ctx, "角色不存在", http.StatusBadRequest)
+		return
+	}
+	var p struct {
+		Parents []string `json:"parents"`
+	}
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dom := tenant.Domain(ctx)
+	parents := []string{}
+	for _, parent := range p.Parents {
+		if system.InArray(parents, parent) {
+			continue
+		}
+		// 超级管理员不通过权限判断，不能被继承
+		if parent == "super" {
+			response.Error(ctx, "不能继承超级管理员", http.StatusBadRequest)
+			return
+		}
+		if !models.IsExistRole(tenant.DB(ctx, r.Db), parent) {
+			response.Error(ctx, "角色不存在："+parent, http.StatusBadRequest)
+			return
+		}
+		// 防止循环继承
+		if parent == alias || system.InArray(acs.ImplicitRoles(parent, dom), alias) {
+			response.Error(ctx, "不能继承自身或下级角色："+parent, http.StatusBadRequest)
+			return
+		}
+		parents = append(parents, parent)
+	}
+	// 替换原有的继承关系
+	var before []string
+	_ = acs.Update(func(e *casbin.Enforcer) error {
+		before = groupingList(e.GetFilteredGroupingPolicy(0, alias, "", dom))
+		e.RemoveFilteredGroupingPolicy(0, alias, "", dom)
+		for _, parent := range parents {
+			e.AddGroupingPolicy(alias, parent, dom)
+		}
+		return nil
+	})
+	_ = audit.Record(ctx, r.Db, models.AuditActionUpdate, "casbin_rule", alias, policyChange("inherits", before, parents))
+	response.Success(ctx, gin.H{"parents": parents}, "设置成功")
+}
+
+// @Title GetDataScope
+// @Description 获取角色的数据范围
+// @Param	alias	path	string	true	"角色别名"
+// @Success 200 {data_scope,customers} data_scope string,customers []uint "查询结果"
+// @router /admin/roles/datascope/:alias [get]
+func (r RoleControllerImpl) GetDataScope(ctx *gin.Context) {
+	var role models.Roles
+	if err := tenant.DB(ctx, r.Db).Where("alias = ?", ctx.Param("alias")).First(&role).Error; err != nil {
+		response.Error(ctx, "角色不存在", http.StatusBadRequest)
+		return
+	}
+	customers := role.CustomerIds()
+	if customers == nil {
+		customers = []uint{}
+	}
+	response.Success(ctx, gin.H{
+		"data_scope": role.DataScope,
+		"customers":  customers,
+	}, "查询成功")
+}
+
+// @Title SetDataScope
+// @Description 设置角色的数据范围：all 全部数据，dept 本部门数据，own 本人数据，custom 指定客户的数据
+// @Param	alias		path	string	true	"角色别名"
+// @Param	data_scope	json	string	true	"数据范围"
+// @Param	customers	json	[]uint	false	"指定的客户id，数据范围为custom时必填"
+// @Success 200 {data_scope,customers} data_scope string,customers []uint "设置结果"
+// @router /admin/roles/datascope/:alias [put]
+func (r RoleControllerImpl) SetDataScope(ctx *gin.Context) {
+	alias := ctx.Param("alias")
+	if alias == "super" {
+		response.Error(ctx, "不允许修改超级管理员", http.StatusBadRequest)
+		return
+	}
+	if !models.IsExistRole(tenant.DB(ctx, r.Db), alias) {
+		response.Error(ctx, "角色不存在", http.StatusBadRequest)
+		return
+	}
+	var p struct {
+		DataScope string `json:"data_scope"`
+		Customers []uint `json:"customers"`
+	}
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !models.IsDataScope(p.DataScope) {
+		response.Error(ctx, "数据范围不正确", http.StatusBadRequest)
+		return
+	}
+	// 只有自定义范围需要指定客户
+	customers := []uint{}
+	if p.DataScope == models.DataScopeCustom {
+		if len(p.Customers) == 0 {
+			response.Error(ctx, "请选择客户", http.StatusBadRequest)
+			return
+		}
+		if err := tenant.DB(ctx, r.Db).Model(&models.Customer{}).Where("id in (?)", p.Customers).Pluck("id", &customers).Error; err != nil {
+			response.Error(ctx, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(customers) != len(p.Customers) {
+			response.Error(ctx, "客户不存在", http.StatusBadRequest)
+			return
+		}
+	}
+	ids := make([]string, 0, len(customers))
+	for _, id := range customers {
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+	if err := tenant.DB(ctx, r.Db).Model(&models.Roles{}).Where("alias = ?", alias).Updates(map[string]interface{}{
+		"data_scope":     p.DataScope,
+		"data_customers": strings.Join(ids, ","),
+	}).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{
+		"data_scope": p.DataScope,
+		"customers":  customers,
+	}, "设置成功")
+}
+
+// @Title Explain
+// @Description 说明角色访问请求时被允许或拒绝的原因，仅超级管理员可用
+// @Param	role	query	string	true	"角色别名"
+// @Param	path	query	string	true	"请求路径"
+// @Param	method	query	string	true	"请求方法"
+// @Success 200 {allowed,reason,roles,matched,method_mismatch} "判断过程"
+// @router /admin/roles/explain [get]
+func (r RoleControllerImpl) Explain(ctx *gin.Context) {
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	if !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
+		response.Error(ctx, "只有超级管理员可以查看", http.StatusForbidden)
+		return
+	}
+	role := ctx.Query("role")
+	path := ctx.Query("path")
+	method := strings.ToUpper(ctx.Query("method"))
+	if !strings.HasPrefix(path, "/") || method == "" {
+		response.Error(ctx, "请求路径和方法不能为空", http.StatusBadRequest)
+		return
+	}
+	if !models.IsExistRole(tenant.DB(ctx, r.Db), role) {
+		response.Error(ctx, "角色不存在", http.StatusBadRequest)
+		return
+	}
+	// 超级管理员在鉴权中间件中直接放行
+	if role == "super" {
+		response.Success(ctx, gin.H{
+			"allowed": true,
+			"reason":  "超级管理员不经过权限判断",
+		}, "查询成功")
+		return
+	}
+	result := acs.Explain(role, tenant.Domain(ctx), path, method)
+	var reason string
+	switch {
+	case result.Allowed:
+		grant := result.Matched[0]
+		reason = "通过 " + strings.Join(grant.Via, " → ") + " 的策略 " + grant.Method + " " + grant.Path + " 允许访问"
+	case len(result.MethodMismatch) > 0:
+		reason = "匹配该路径的策略不允许 " + method + " 请求"
+	default:
+		reason = "角色及其继承的角色没有匹配该路径的策略"
+	}
+	response.Success(ctx, gin.H{
+		"allowed":         result.Allowed,
+		"reason":          reason,
+		"roles":           result.Roles,
+		"matched":         result.Matched,
+		"method_mismatch": result.MethodMismatch,
+	}, "查询成功")
+}
+
+// @Title Export
+// @Description 导出角色、权限规则和角色的策略，仅超级管理员可用
+// @Param	format	query	string	false	"文档格式，yaml 或 json，默认 yaml"
+// @Success 200 {file} "角色权限文档"
+// @router /admin/roles/export [get]
+func (r RoleControllerImpl) Export(ctx *gin.Context) {
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	if !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
+		response.Error(ctx, "只有超级管理员可以导出", http.StatusForbidden)
+		return
+	}
+	format := ctx.DefaultQuery("format", "yaml")
+	doc, err := acs.Export(r.Db, tenant.Id(ctx))
+	if err != nil {
+		response.Error(ctx, "导出失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, err := acs.EncodeDocument(doc, format)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contentType := "application/x-yaml"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	ctx.Header("Content-Disposition", "attachment; filename=roles."+format)
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// @Title Import
+// @Description 导入角色权限文档，先与当前配置比较，试运行时只返回变更，不写入。仅超级管理员可用
+// @Param	file	formData	file	false	"角色权限文档，也可以直接作为请求体"
+// @Param	format	query	string	false	"文档格式，yaml 或 json，默认根据内容判断"
+// @Param	dry_run	query	bool	false	"是否试运行"
+// @Param	prune	query	bool	false	"是否删除文档中没有的规则和角色"
+// @Success 200 {dry_run,changes} dry_run bool,changes []acs.Change "导入结果"
+// @router /admin/roles/import [post]
+func (r RoleControllerImpl) Import(ctx *gin.Context) {
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	if !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
+		response.Error(ctx, "只有超级管理员可以导入", http.StatusForbidden)
+		return
+	}
+	var data []byte
+	var err error
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		file, err := ctx.FormFile("file")
+		if err != nil {
+			response.Error(ctx, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			response.Error(ctx, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		if data, err = ioutil.ReadAll(f); err != nil {
+			response.Error(ctx, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if data, err = ctx.GetRawData(); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	doc, err := acs.DecodeDocument(data, ctx.Query("format"))
+	if err != nil {
+		response.Error(ctx, "解析文档失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := acs.ImportOptions{
+		DryRun: ctx.Query("dry_run") == "true" || ctx.Query("dry_run") == "1",
+		Prune:  ctx.Query("prune") == "true" || ctx.Query("prune") == "1",
+		// 规则为所有租户共用，只有平台管理员可以导入
+		SkipRules: !tenant.IsPlatformAdmin(ctx),
+	}
+	changes, err := acs.Import(audit.DB(ctx, r.Db), tenant.Id(ctx), doc, opts)
+	if err != nil {
+		response.Error(ctx, "导入失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !opts.DryRun && len(changes) > 0 {
+		_ = audit.Record(ctx, r.Db, models.AuditActionUpdate, "casbin_rule", "import", map[string]audit.Change{
+			"import": {New: changes},
+		})
+	}
+	response.Success(ctx, gin.H{
+		"dry_run": opts.DryRun,
+		"changes": changes,
+	}, "导入成功")
+}
+
 func NewRoleController() *RoleControllerImpl {
 	db := database.GetDB()
 	models.InitRolesTable(db)
-	return &RoleControllerImpl{Db: db, Cache: cache.GetCacheObj(), Acs: acs.GetEnforcer()}
+	return &RoleControllerImpl{Db: db, Cache: cache.GetCacheObj()}
+}
+
+// 将权限策略转换为"请求方法 路径"的列表
+func policyList(policies [][]string) []string {
+	list := []string{}
+	for _, p := range policies {
+		list = append(list, p[3]+" "+p[2])
+	}
+	sort.Strings(list)
+	return list
+}
+
+// 获取分组策略中的上级角色
+func groupingList(policies [][]string) []string {
+	list := []string{}
+	for _, p := range policies {
+		list = append(list, p[1])
+	}
+	sort.Strings(list)
+	return list
+}
+
+// 获取分组策略中继承该角色的下级角色和用户
+func memberList(policies [][]string) []string {
+	list := []string{}
+	for _, p := range policies {
+		list = append(list, p[0])
+	}
+	sort.Strings(list)
+	return list
+}
+
+// 生成权限策略变更的审计记录，没有变化时返回空
+func policyChange(field string, before, after []string) map[string]audit.Change {
+	if before == nil {
+		before = []string{}
+	}
+	// 复制后排序，不影响调用方的顺序
+	after = append([]string{}, after...)
+	sort.Strings(after)
+	if strings.Join(before, "\n") == strings.Join(after, "\n") {
+		return nil
+	}
+	return map[string]audit.Change{field: {Old: before, New: after}}
 }
@@ -3,16 +3,25 @@ package controller
 import (
 	"FlyCloud/application"
 	"FlyCloud/models"
+	"FlyCloud/pkg/audit"
 	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/system"
 	"FlyCloud/serves/cache"
+	acs "FlyCloud/serves/casbin"
 	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"errors"
 	"github.com/allegro/bigcache"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"net/http"
 	"reflect"
+	"strings"
 )
 
+// 规则允许的请求方法，为空表示分组节点
+var ruleMethods = []string{"", "GET", "POST", "PUT", "PATCH", "DELETE"}
+
 type Tree struct {
 	models.Rules
 	Children []*Tree `json:"children"`
@@ -62,6 +71,190 @@ func (this *RulesControllerImpl) Select(ctx *gin.Context) {
 	}, "获取成功")
 }
 
+// @Title Find
+// @Description 根据ID获取规则
+// @Param	id	path	int	true	"规则id"
+// @Success 200 {data} data models.Rules "获取成功"
+// @Failure DATA_NOT_EXIST "数据不存在"
+// @router /admin/rules/info/:id [get]
+func (this *RulesControllerImpl) Find(ctx *gin.Context) {
+	var rule models.Rules
+	if err := this.Db.First(&rule, "id = ?", ctx.Param("id")).Error; err != nil {
+		response.Error(ctx, "规则不存在", http.StatusNotFound)
+		return
+	}
+	response.Success(ctx, gin.H{"data": rule}, "获取成功")
+}
+
+// @Title Insert
+// @Description 新增分组节点，权限节点根据注册的路由自动生成
+// @Param	model	body	models.Rules	true	"规则信息"
+// @Success 200 {data} data models.Rules "新增成功"
+// @Failure 0 "新增失败"
+// @router /admin/rules/add [post]
+func (this *RulesControllerImpl) Insert(ctx *gin.Context) {
+	// 权限规则为所有租户共用，只允许平台管理员修改
+	if !platformOnly(ctx, "修改权限规则") {
+		return
+	}
+	var rule models.Rules
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule.ID = 0
+	if err := this.validate(&rule); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !rule.Manual() {
+		response.Error(ctx, "只能新增分组节点，权限节点根据路由自动生成", http.StatusBadRequest)
+		return
+	}
+	if err := audit.DB(ctx, this.Db).Create(&rule).Error; err != nil {
+		response.Error(ctx, "新增规则失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{"data": rule}, "新增成功")
+}
+
+// @Title Update
+// @Description 更新规则的名称和上级规则，路径和请求方法以注册的路由为准，不能修改
+// @Param	id		path	int				true	"规则id"
+// @Param	model	body	models.Rules	true	"规则信息"
+// @Success 200 {data} data models.Rules "更新成功"
+// @Failure 0 "更新失败"
+// @router /admin/rules/edit/:id [put]
+func (this *RulesControllerImpl) Update(ctx *gin.Context) {
+	if !platformOnly(ctx, "修改权限规则") {
+		return
+	}
+	var old models.Rules
+	if err := this.Db.First(&old, "id = ?", ctx.Param("id")).Error; err != nil {
+		response.Error(ctx, "规则不存在", http.StatusNotFound)
+		return
+	}
+	var rule models.Rules
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule.ID = old.ID
+	if err := this.validate(&rule); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 路径和请求方法由路由同步维护，修改后下次启动会被还原
+	if rule.Path != old.Path || rule.Method != old.Method {
+		response.Error(ctx, "规则的路径和请求方法根据路由生成，不能修改", http.StatusBadRequest)
+		return
+	}
+	if err := audit.DB(ctx, this.Db).Model(&old).Updates(map[string]interface{}{
+		"name": rule.Name,
+		"pid":  rule.Pid,
+	}).Error; err != nil {
+		response.Error(ctx, "更新规则失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{"data": rule}, "更新成功")
+}
+
+// @Title Delete
+// @Description 删除规则，同时删除角色已有的该权限
+// @Param	id	path	int	true	"规则id"
+// @Success 200 "删除成功"
+// @Failure 0 "删除失败"
+// @router /admin/rules/delete/:id [delete]
+func (this *RulesControllerImpl) Delete(ctx *gin.Context) {
+	if !platformOnly(ctx, "修改权限规则") {
+		return
+	}
+	var rule models.Rules
+	if err := this.Db.First(&rule, "id = ?", ctx.Param("id")).Error; err != nil {
+		response.Error(ctx, "规则不存在", http.StatusNotFound)
+		return
+	}
+	// 路由仍存在的规则会在下次启动时重新生成，只能删除失效的规则和分组节点
+	if !rule.Manual() && rule.Stale == 0 {
+		response.Error(ctx, "规则对应的路由仍存在，不能删除", http.StatusBadRequest)
+		return
+	}
+	// 存在子规则时不允许删除
+	var children int
+	this.Db.Model(&models.Rules{}).Where("pid = ?", rule.ID).Count(&children)
+	if children > 0 {
+		response.Error(ctx, "请先删除子规则", http.StatusBadRequest)
+		return
+	}
+	var roles []string
+	err := audit.DB(ctx, this.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Rules{}, "id = ?", rule.ID).Error; err != nil {
+			return err
+		}
+		// 仍有其他规则使用相同的路径和方法时，保留原有权限
+		var count int
+		if err := tx.Model(&models.Rules{}).Where("path = ? and method = ?", rule.Path, rule.Method).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		var err error
+		roles, err = acs.RemovePermission(tx, rule.Path, rule.Method)
+		return err
+	})
+	if err != nil {
+		response.Error(ctx, "删除规则失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 重新加载策略
+	if err := acs.Reload(); err != nil {
+		logging.Ctx(ctx).Error("重新加载权限策略失败：", err)
+	}
+	response.Success(ctx, gin.H{"roles": roles}, "删除成功")
+}
+
+// 校验规则，请求方法统一为大写，上级规则必须存在且不能是自身或下级规则
+func (this *RulesControllerImpl) validate(rule *models.Rules) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Path = strings.TrimSpace(rule.Path)
+	rule.Method = strings.ToUpper(strings.TrimSpace(rule.Method))
+	if rule.Name == "" {
+		return errors.New("规则名称不能为空")
+	}
+	if !system.InArray(ruleMethods, rule.Method) {
+		return errors.New("不支持的请求方法：" + rule.Method)
+	}
+	// 分组节点可以没有路径，权限节点必须有路径
+	if rule.Path == "" {
+		if rule.Method != "" {
+			return errors.New("规则路径不能为空")
+		}
+	} else {
+		if !strings.HasPrefix(rule.Path, "/") {
+			return errors.New("规则路径必须以/开头")
+		}
+		// 路径和请求方法不能与其他规则重复
+		var count int
+		this.Db.Model(&models.Rules{}).Where("id <> ? and path = ? and method = ?", rule.ID, rule.Path, rule.Method).Count(&count)
+		if count > 0 {
+			return errors.New("已存在相同路径和请求方法的规则")
+		}
+	}
+	// 验证上级规则，并防止形成循环
+	for pid, depth := rule.Pid, 0; pid != 0; depth++ {
+		if pid == rule.ID || depth > 32 {
+			return errors.New("上级规则不能是自身或下级规则")
+		}
+		var parent models.Rules
+		if err := this.Db.First(&parent, "id = ?", pid).Error; err != nil {
+			return errors.New("上级规则不存在")
+		}
+		pid = parent.Pid
+	}
+	return nil
+}
+
 // 递归排序，父节点获取子节点
 func treeData(rules []*Tree, parentId int) []*Tree {
 	var nodes []*Tree
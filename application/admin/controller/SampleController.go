@@ -3,7 +3,9 @@ package controller
 import (
 	"FlyCloud/application"
 	"FlyCloud/models"
+	"FlyCloud/pkg/jwt"
 	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/tenant"
 	"FlyCloud/serves/cache"
 	"FlyCloud/serves/database"
 	"github.com/allegro/bigcache"
@@ -32,8 +34,8 @@ func (s *sampleController) GetAll(ctx *gin.Context) {
 	var samples []models.Sample
 	var total int
 
-	// 获取所有服装款式
-	if err := s.Db.Model(&models.Sample{}).Count(&total).Find(&samples).Error; err != nil {
+	// 获取数据范围内的所有服装款式
+	if err := tenant.DB(ctx, s.Db).Model(&models.Sample{}).Count(&total).Find(&samples).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -52,8 +54,8 @@ func (s *sampleController) Select(ctx *gin.Context) {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// 声明查询条件
-	query := s.Db.Model(&models.Sample{})
+	// 声明查询条件，只查询可见客户的款式
+	query := tenant.DB(ctx, s.Db).Model(&models.Sample{})
 	// 按条件查询
 	if sample.Name != "" { // 按名称查询,模糊查询
 		query = query.Where("name like ?", "%"+sample.Name+"%")
@@ -105,6 +107,10 @@ func (s *sampleController) Insert(ctx *gin.Context) {
 		response.Error(ctx, "请选择客户", http.StatusBadRequest)
 		return
 	}
+	// 只能为数据范围内的客户新增款式
+	if !s.checkCustomer(ctx, sample.CustomerId) {
+		return
+	}
 	// 判断是否选择了季节
 	if sample.Season == "" {
 		response.Error(ctx, "请选择季节", http.StatusBadRequest)
@@ -129,8 +135,10 @@ func (s *sampleController) Insert(ctx *gin.Context) {
 		Price:      sample.Price,
 		ImgSrc:     sample.ImgSrc,
 	}
+	// 记录创建人
+	newSample.CreatedBy = ctx.MustGet("claim").(*jwt.CustomClaims).UserId
 	// 新增
-	if err := s.Db.Create(&newSample).Error; err != nil {
+	if err := tenant.DB(ctx, s.Db).Create(&newSample).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -152,9 +160,20 @@ func (s *sampleController) Update(ctx *gin.Context) {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// 创建人不随编辑变更
+	sample.CreatedBy = 0
+	// 更换客户时，新客户也要在数据范围内
+	if sample.CustomerId != 0 && !s.checkCustomer(ctx, sample.CustomerId) {
+		return
+	}
 	// 更新
-	if err := s.Db.Model(&models.Sample{}).Where("id = ?", id).Updates(sample).Error; err != nil {
-		response.Error(ctx, err.Error(), http.StatusBadRequest)
+	result := tenant.DB(ctx, s.Db).Model(&models.Sample{}).Where("id = ?", id).Updates(sample)
+	if result.Error != nil {
+		response.Error(ctx, result.Error.Error(), http.StatusBadRequest)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, "服装款式不存在或无权操作", http.StatusBadRequest)
 		return
 	}
 	response.Success(ctx, nil, "更新成功")
@@ -170,7 +189,7 @@ func (s *sampleController) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
 	// 获取待删除的服装款式
 	var sample models.Sample
-	if err := s.Db.First(&sample, id).Error; err != nil {
+	if err := tenant.DB(ctx, s.Db).First(&sample, id).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -185,7 +204,7 @@ func (s *sampleController) Delete(ctx *gin.Context) {
 		return
 	}
 
-	if err := s.Db.Delete(&models.Sample{}, "id = ?", id).Error; err != nil {
+	if err := tenant.DB(ctx, s.Db).Delete(&models.Sample{}, "id = ?", id).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -202,13 +221,27 @@ func (s *sampleController) Find(ctx *gin.Context) {
 	id := ctx.Param("id")
 	// 获取待删除的服装款式
 	var sample models.Sample
-	if err := s.Db.First(&sample, id).Error; err != nil {
+	if err := tenant.DB(ctx, s.Db).First(&sample, id).Error; err != nil {
 		response.Error(ctx, err.Error(), http.StatusBadRequest)
 		return
 	}
 	response.Success(ctx, gin.H{"data": sample}, "获取成功")
 }
 
+// 判断客户是否在当前用户的数据范围内
+func (s *sampleController) checkCustomer(ctx *gin.Context, customerId int) bool {
+	var count int
+	if err := tenant.DB(ctx, s.Db).Model(&models.Customer{}).Where("id = ?", customerId).Count(&count).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if count == 0 {
+		response.Error(ctx, "客户不存在或无权操作", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
 func NewSampleController() *sampleController {
 	db := database.GetDB()
 	models.InitSampleTable(db)
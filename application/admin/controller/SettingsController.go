@@ -4,6 +4,8 @@ import (
 	"FlyCloud/models"
 	"FlyCloud/pkg/jwt"
 	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/tenant"
+	acs "FlyCloud/serves/casbin"
 	"FlyCloud/serves/database"
 	"net/http"
 
@@ -39,7 +41,17 @@ func NewSettingsController() *settingsController {
 // @router /settings [get]
 func (c *settingsController) GetSettings(ctx *gin.Context) {
 	settings := []models.Settings{}
-	if err := c.Db.Find(&settings).Error; err != nil {
+	db := tenant.DB(ctx, c.Db)
+	// 可以通过租户编码获取指定租户的设置，用于登录前展示
+	if code := ctx.Query("tenant"); code != "" {
+		t, err := models.GetTenantByCode(c.Db, code)
+		if err != nil {
+			response.Error(ctx, "租户不存在", http.StatusBadRequest)
+			return
+		}
+		db = database.WithTenant(c.Db, t.ID)
+	}
+	if err := db.Find(&settings).Error; err != nil {
 		response.Error(ctx, "获取系统设置失败："+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -62,7 +74,7 @@ func (c *settingsController) UpdateSettings(ctx *gin.Context) {
 	// 从ctx中获取claims
 	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
 	// 判断是否为超级管理员
-	if claim.UserRole != "super" {
+	if !acs.IsSuper(claim.UserId, claim.UserRole, tenant.Domain(ctx)) {
 		response.Error(ctx, "您没有权限更新系统设置", http.StatusBadRequest)
 		return
 	}
@@ -71,7 +83,14 @@ func (c *settingsController) UpdateSettings(ctx *gin.Context) {
 		response.Error(ctx, "获取系统设置失败："+err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := c.Db.Save(&settings).Error; err != nil {
+	// 校验设置值
+	if err := settings.Validate(tenant.DB(ctx, c.Db)); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 只能修改所属租户的设置
+	settings.TenantId = tenant.Id(ctx)
+	if err := tenant.DB(ctx, c.Db).Save(&settings).Error; err != nil {
 		response.Error(ctx, "更新系统设置失败："+err.Error(), http.StatusBadRequest)
 		return
 	}
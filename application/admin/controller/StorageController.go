@@ -6,6 +6,7 @@ import (
 	"FlyCloud/pkg/jwt"
 	"FlyCloud/pkg/response"
 	"FlyCloud/pkg/system"
+	"FlyCloud/pkg/tenant"
 	"FlyCloud/serves/cache"
 	"FlyCloud/serves/database"
 	"net/http"
@@ -59,7 +60,7 @@ func (c *storageController) UploadImage(ctx *gin.Context) {
 		return
 	}
 	// 从系统设置中获取文件上传大小
-	settings, err := models.GetSettingsByKeys(c.Db, []string{"site_upload_image_size", "site_upload_image_ext"})
+	settings, err := models.GetSettingsByKeys(tenant.DB(ctx, c.Db), []string{"site_upload_image_size", "site_upload_image_ext"})
 	if err != nil {
 		response.Error(ctx, "获取系统设置失败："+err.Error(), http.StatusBadRequest)
 		return
@@ -88,8 +89,8 @@ func (c *storageController) UploadImage(ctx *gin.Context) {
 	 * 判断 storage/upload/image 文件夹是否存在
 	 * 如果不存在则创建
 	**/
-	// 获取项目根目录
-	rootPath := "./storage/upload/image"
+	// 获取租户的存储目录
+	rootPath := tenant.StorageDir(tenant.Id(ctx), "upload/image")
 	// 判断 storage/upload/image 文件夹是否存在
 	isE, _ := system.IsExist(rootPath)
 	if !isE {
@@ -144,7 +145,7 @@ func (c *storageController) UploadImage(ctx *gin.Context) {
 		Type:     "image",
 	}
 	// 保存到数据库,并获取id
-	err = c.Db.Create(&storage).Error
+	err = tenant.DB(ctx, c.Db).Create(&storage).Error
 	if err != nil {
 		response.Error(ctx, "保存图片路径到数据库失败："+err.Error(), http.StatusBadRequest)
 		return
@@ -175,7 +176,7 @@ func (c *storageController) UploadFile(ctx *gin.Context) {
 		return
 	}
 	// 从系统设置中获取文件上传大小
-	settings, err := models.GetSettingsByKeys(c.Db, []string{"site_upload_ext", "site_upload_file_size"})
+	settings, err := models.GetSettingsByKeys(tenant.DB(ctx, c.Db), []string{"site_upload_ext", "site_upload_file_size"})
 	if err != nil {
 		response.Error(ctx, "获取系统设置失败："+err.Error(), http.StatusBadRequest)
 		return
@@ -204,8 +205,8 @@ func (c *storageController) UploadFile(ctx *gin.Context) {
 	 * 判断 storage/upload/file 文件夹是否存在
 	 * 如果不存在则创建
 	**/
-	// 获取项目根目录
-	rootPath := "./storage/upload/file"
+	// 获取租户的存储目录
+	rootPath := tenant.StorageDir(tenant.Id(ctx), "upload/file")
 	// 判断 storage/upload/file 文件夹是否存在
 	isE, _ := system.IsExist(rootPath)
 	if !isE {
@@ -260,7 +261,7 @@ func (c *storageController) UploadFile(ctx *gin.Context) {
 		Type:     "file",
 	}
 	// 保存到数据库,并获取id
-	err = c.Db.Create(&storage).Error
+	err = tenant.DB(ctx, c.Db).Create(&storage).Error
 	if err != nil {
 		response.Error(ctx, "保存文件路径到数据库失败："+err.Error(), http.StatusBadRequest)
 		return
@@ -287,7 +288,7 @@ func (c *storageController) GetImage(ctx *gin.Context) {
 	// 获取数据库中的图片路径
 	storage := models.Storage{}
 	// 查询数据库
-	err := c.Db.Where("location = ?", location).First(&storage).Error
+	err := tenant.DB(ctx, c.Db).Where("location = ?", location).First(&storage).Error
 	if err != nil {
 		response.Error(ctx, "获取图片失败："+err.Error(), http.StatusBadRequest)
 		return
@@ -309,7 +310,7 @@ func (c *storageController) GetFile(ctx *gin.Context) {
 	// 获取数据库中的文件路径
 	storage := models.Storage{}
 	// 查询数据库
-	err := c.Db.Where("location = ?", location).First(&storage).Error
+	err := tenant.DB(ctx, c.Db).Where("location = ?", location).First(&storage).Error
 	if err != nil {
 		response.Error(ctx, "获取文件失败："+err.Error(), http.StatusBadRequest)
 		return
@@ -334,8 +335,8 @@ func (c *storageController) Select(ctx *gin.Context) {
 		response.Error(ctx, "获取查询条件失败："+err.Error(), http.StatusBadRequest)
 		return
 	}
-	// 调用 storage 模型的 Filter 方法
-	db := model.Filter(c.Db)
+	// 调用 storage 模型的 Filter 方法，只查询数据范围内的文件
+	db := model.Filter(tenant.DB(ctx, c.Db).Model(&models.Storage{}))
 	// 获取总数
 	var count int
 	var data []models.Storage
@@ -359,8 +360,8 @@ func (c *storageController) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
 	// 创建存储对象
 	storage := models.Storage{}
-	// 查询数据库
-	err := c.Db.Where("id = ?", id).First(&storage).Error
+	// 查询数据范围内的文件
+	err := tenant.DB(ctx, c.Db).Where("id = ?", id).First(&storage).Error
 	if err != nil {
 		response.Error(ctx, "删除失败："+err.Error(), http.StatusInternalServerError)
 		return
@@ -372,7 +373,7 @@ func (c *storageController) Delete(ctx *gin.Context) {
 		return
 	}
 	// 删除数据库中的文件路径
-	err = c.Db.Delete(&storage).Error
+	err = tenant.DB(ctx, c.Db).Delete(&storage).Error
 	if err != nil {
 		response.Error(ctx, "删除失败："+err.Error(), http.StatusInternalServerError)
 		return
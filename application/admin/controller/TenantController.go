@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/account"
+	"FlyCloud/pkg/audit"
+	"FlyCloud/pkg/password"
+	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/system"
+	"FlyCloud/pkg/tenant"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"net/http"
+	"regexp"
+
+	"github.com/allegro/bigcache"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title TenantController
+// @Description 租户管理控制器，仅平台管理员可用
+
+// 定义租户控制器
+type TenantController interface {
+	Insert(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Select(ctx *gin.Context)
+	Suspend(ctx *gin.Context)
+	Resume(ctx *gin.Context)
+}
+
+// 定义租户控制器
+type tenantController struct {
+	Db    *gorm.DB
+	Cache *bigcache.BigCache
+}
+
+// 租户编码只能包含小写字母、数字和中划线
+var tenantCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+// 实例化租户控制器
+func NewTenantController() *tenantController {
+	db := database.GetDB()
+	// 初始化租户表
+	models.InitTenantTable(db)
+	return &tenantController{
+		Db:    db,
+		Cache: cache.GetCacheObj(),
+	}
+}
+
+// @Title Insert
+// @Description 新增租户，同时创建租户的超级管理员角色、默认设置和超级管理员账号
+// @Param	code	json	string	true	"租户编码"
+// @Param	name	json	string	true	"租户名称"
+// @Param	admin	json	models.Admin	true	"租户超级管理员，需要用户名、密码和手机号"
+// @Success 200 {data} data models.Tenant "新增成功"
+// @Failure 0 "新增失败"
+// @router /admin/tenant/add [post]
+func (c *tenantController) Insert(ctx *gin.Context) {
+	if !platformOnly(ctx, "管理租户") {
+		return
+	}
+	var p struct {
+		Code   string `json:"code"`
+		Name   string `json:"name"`
+		Remark string `json:"remark"`
+		Admin  struct {
+			Username  string `json:"username"`
+			Password  string `json:"password"`
+			Nickname  string `json:"nickname"`
+			Telephone string `json:"telephone"`
+			Email     string `json:"email"`
+		} `json:"admin"`
+	}
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !tenantCodePattern.MatchString(p.Code) {
+		response.Error(ctx, "租户编码只能包含小写字母、数字和中划线，长度为2到50", http.StatusBadRequest)
+		return
+	}
+	if p.Name == "" {
+		response.Error(ctx, "租户名称不能为空", http.StatusBadRequest)
+		return
+	}
+	if _, err := models.GetTenantByCode(c.Db, p.Code); err == nil {
+		response.Error(ctx, "租户编码已存在", http.StatusBadRequest)
+		return
+	}
+	// 校验租户超级管理员账号，用户名和手机号在所有租户中唯一
+	if p.Admin.Username == "" || p.Admin.Telephone == "" {
+		response.Error(ctx, "请填写租户超级管理员的用户名和手机号", http.StatusBadRequest)
+		return
+	}
+	if models.IsExistAdminByUsername(c.Db, p.Admin.Username) {
+		response.Error(ctx, "该用户名已被注册！", http.StatusBadRequest)
+		return
+	}
+	if models.IsExistAdminByTelephone(c.Db, p.Admin.Telephone) {
+		response.Error(ctx, "该手机号已被注册！", http.StatusBadRequest)
+		return
+	}
+	if err := password.Validate(p.Admin.Password); err != nil {
+		response.Error(ctx, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := password.Hash(p.Admin.Password)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	model := models.Tenant{Code: p.Code, Name: p.Name, Remark: p.Remark, Status: models.TenantStatusActive}
+	admin := models.Admin{
+		Username:  p.Admin.Username,
+		Password:  hash,
+		Nickname:  p.Admin.Nickname,
+		Telephone: p.Admin.Telephone,
+		Email:     p.Admin.Email,
+		Status:    models.AdminStatusEnabled,
+		RolesName: "super",
+	}
+	err = audit.DB(ctx, c.Db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&model).Error; err != nil {
+			return err
+		}
+		if err := models.InitTenantRoles(tx, model.ID); err != nil {
+			return err
+		}
+		models.InitTenantSettings(tx, model.ID)
+		if err := database.WithTenant(tx, model.ID).Create(&admin).Error; err != nil {
+			return err
+		}
+		return models.AddPasswordHistory(tx, admin.ID, hash)
+	})
+	if err != nil {
+		response.Error(ctx, "新增租户失败："+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{"data": model, "admin_id": admin.ID}, "新增成功")
+}
+
+// @Title Update
+// @Description 修改租户名称和备注
+// @Param	id		path	int		true	"租户id"
+// @Param	model	json	models.Tenant	true	"租户信息"
+// @Success 200 {data} data models.Tenant "更新成功"
+// @Failure 0 "更新失败"
+// @router /admin/tenant/edit/:id [put]
+func (c *tenantController) Update(ctx *gin.Context) {
+	if !platformOnly(ctx, "管理租户") {
+		return
+	}
+	var model models.Tenant
+	if err := c.Db.First(&model, "id = ?", ctx.Param("id")).Error; err != nil {
+		response.Error(ctx, "租户不存在", http.StatusBadRequest)
+		return
+	}
+	var p models.Tenant
+	if err := ctx.ShouldBindJSON(&p); err != nil {
+		response.Error(ctx, "参数错误："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if p.Name == "" {
+		response.Error(ctx, "租户名称不能为空", http.StatusBadRequest)
+		return
+	}
+	if err := audit.DB(ctx, c.Db).Model(&model).Updates(map[string]interface{}{"name": p.Name, "remark": p.Remark}).Error; err != nil {
+		response.Error(ctx, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Success(ctx, gin.H{"data": model}, "更新成功")
+}
+
+// @Title Select
+// @Description 查询租户列表
+// @Param model json models.Tenant true "查询条件"
+// @Success 200 {data,count} data []models.Tenant,count int "获取成功"
+// @Failure 0 "获取失败"
+// @router /admin/tenant/list [post]
+func (c *tenantController) Select(ctx *gin.Context) {
+	if !platformOnly(ctx, "管理租户") {
+		return
+	}
+	var model models.Tenant
+	if err := ctx.ShouldBindJSON(&model); err != nil {
+		response.Error(ctx, "获取查询条件失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 默认分页
+	if model.PageNum <= 0 {
+		model.PageNum = 1
+	}
+	if model.PageSize <= 0 {
+		model.PageSize = 20
+	}
+	db := c.Db.Model(&models.Tenant{})
+	if model.Code != "" {
+		db = db.Where("code = ?", model.Code)
+	}
+	if model.Name != "" {
+		db = db.Where("name like ?", "%"+model.Name+"%")
+	}
+	if model.Status != 0 {
+		db = db.Where("status = ?", model.Status)
+	}
+	var count int
+	var data []models.Tenant
+	if err := db.Count(&count).Order("id").Limit(model.PageSize).Offset((model.PageNum - 1) * model.PageSize).Find(&data).Error; err != nil {
+		response.Error(ctx, "获取数据失败："+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response.Success(ctx, gin.H{"data": data, "count": count}, "获取数据成功")
+}
+
+// @Title Suspend
+// @Description 停用租户，租户内的用户立即无法访问，也无法登录
+// @Param	id	path	int	true	"租户id"
+// @Success 200 "停用结果"
+// @router /admin/tenant/suspend/:id [put]
+func (c *tenantController) Suspend(ctx *gin.Context) {
+	c.setStatus(ctx, models.TenantStatusSuspended)
+}
+
+// @Title Resume
+// @Description 恢复已停用的租户
+// @Param	id	path	int	true	"租户id"
+// @Success 200 "恢复结果"
+// @router /admin/tenant/resume/:id [put]
+func (c *tenantController) Resume(ctx *gin.Context) {
+	c.setStatus(ctx, models.TenantStatusActive)
+}
+
+// 修改租户状态
+func (c *tenantController) setStatus(ctx *gin.Context, status int) {
+	if !platformOnly(ctx, "管理租户") {
+		return
+	}
+	id := system.StrToUint(ctx.Param("id"))
+	// 默认租户包含平台管理员，不能停用
+	if id == models.DefaultTenantId && status != models.TenantStatusActive {
+		response.Error(ctx, "不能停用默认租户", http.StatusBadRequest)
+		return
+	}
+	result := audit.DB(ctx, c.Db).Model(&models.Tenant{}).Where("id = ?", id).Update("status", status)
+	if result.Error != nil {
+		response.Error(ctx, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(ctx, "租户不存在", http.StatusBadRequest)
+		return
+	}
+	// 清除缓存的租户状态
+	account.InvalidateTenant(id)
+	response.Success(ctx, gin.H{
+		"id":     id,
+		"status": status,
+	}, "更新成功")
+}
+
+// 只允许平台管理员操作，失败时直接返回错误响应
+func platformOnly(ctx *gin.Context, action string) bool {
+	if !tenant.IsPlatformAdmin(ctx) {
+		response.Error(ctx, "只有平台管理员可以"+action, http.StatusForbidden)
+		return false
+	}
+	return true
+}
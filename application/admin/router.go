@@ -3,6 +3,7 @@ package admin
 import (
 	"FlyCloud/application/admin/controller"
 	"FlyCloud/middleware"
+	"FlyCloud/serves/routers"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,8 +19,26 @@ func Routes(r *gin.Engine) {
 	{
 		common_controller := controller.NewCommonController()
 		common.POST("/login", common_controller.Login)
+		common.POST("/refresh", common_controller.Refresh)
 		common.POST("/register", common_controller.Register)
 		common.GET("/captcha", common_controller.GetCaptcha)
+		mfa_controller := controller.NewMfaController()
+		common.POST("/mfa/verify", mfa_controller.Verify)
+		password_controller := controller.NewPasswordController()
+		common.POST("/password/forgot", password_controller.Forgot)
+		common.POST("/password/reset", password_controller.Reset)
+		oidc_controller := controller.NewOidcController()
+		common.GET("/oidc/authorize", oidc_controller.Authorize)
+		common.POST("/oidc/callback", oidc_controller.Callback)
+	}
+	// 注册两步验证绑定路由分组，登录时强制绑定的临时令牌也可访问
+	mfa := r.Group("/admin/common/mfa")
+	{
+		mfa.Use(middleware.MfaCheck())
+		mfa_controller := controller.NewMfaController()
+		mfa.POST("/setup", mfa_controller.Setup)
+		mfa.GET("/qrcode", mfa_controller.QrCode)
+		mfa.POST("/confirm", mfa_controller.Confirm)
 	}
 	// 注册上传控制器路由分组
 	upload := r.Group("/upload")
@@ -37,86 +56,144 @@ func Routes(r *gin.Engine) {
 		// Casbin验证中间件
 		admin.Use(middleware.AdminPrivilege())
 		// 注册管理员控制器路由分组
-		admins := admin.Group("/admin")
+		admins := routers.NewGroup(admin.Group("/admin"), "管理员管理")
 		{
 
 			admins_controller := controller.NewAdminController()
-			admins.POST("/add", admins_controller.Insert)
-			admins.PUT("/edit/:id", admins_controller.Update)
-			admins.DELETE("/delete/:id", admins_controller.Delete)
-			admins.POST("/list", admins_controller.Select)
-			admins.GET("/info/:id", admins_controller.Find)
+			admins.POST("/add", "管理员添加", admins_controller.Insert)
+			admins.PUT("/edit/:id", "管理员编辑", admins_controller.Update)
+			admins.DELETE("/delete/:id", "管理员删除", admins_controller.Delete)
+			admins.POST("/list", "管理员列表", admins_controller.Select)
+			admins.GET("/info/:id", "管理员信息", admins_controller.Find)
+			admins.PUT("/unlock/:id", "管理员解锁", admins_controller.Unlock)
+			admins.PUT("/enable/:id", "管理员启用", admins_controller.Enable)
+			admins.PUT("/disable/:id", "管理员禁用", admins_controller.Disable)
+			admins.PUT("/approve/:id", "注册审核通过", admins_controller.Approve)
+			admins.PUT("/reject/:id", "注册审核拒绝", admins_controller.Reject)
+			admins.GET("/grants/:id", "管理员授权信息", admins_controller.GetGrants)
+			admins.PUT("/roles/:id", "管理员角色设置", admins_controller.SetRoles)
+			admins.PUT("/permissions/:id", "管理员权限设置", admins_controller.SetPermissions)
 		}
 		// 注册角色控制器路由分组
-		roles := admin.Group("/roles")
+		roles := routers.NewGroup(admin.Group("/roles"), "角色管理")
 		{
 			roles_controller := controller.NewRoleController()
-			roles.POST("/add", roles_controller.Insert)
-			roles.PUT("/edit/:id", roles_controller.Update)
-			roles.DELETE("/delete/:alias", roles_controller.Delete)
-			roles.POST("/list", roles_controller.Select)
-			roles.GET("/info/:alias", roles_controller.Find)
-			roles.GET("/getAll", roles_controller.GetAllRoles)
+			roles.POST("/add", "角色添加", roles_controller.Insert)
+			roles.PUT("/edit/:id", "角色编辑", roles_controller.Update)
+			roles.DELETE("/delete/:alias", "角色删除", roles_controller.Delete)
+			roles.POST("/list", "角色列表", roles_controller.Select)
+			roles.GET("/info/:alias", "角色信息", roles_controller.Find)
+			roles.GET("/getAll", "获取所有角色", roles_controller.GetAllRoles)
+			roles.GET("/inherits/:alias", "角色继承信息", roles_controller.GetInherits)
+			roles.PUT("/inherits/:alias", "角色继承设置", roles_controller.SetInherits)
+			roles.GET("/datascope/:alias", "角色数据范围", roles_controller.GetDataScope)
+			roles.PUT("/datascope/:alias", "角色数据范围设置", roles_controller.SetDataScope)
+			roles.GET("/explain", "角色权限说明", roles_controller.Explain)
+			roles.GET("/export", "角色权限导出", roles_controller.Export)
+			roles.POST("/import", "角色权限导入", roles_controller.Import)
 		}
 		// 注册规则控制器路由分组
-		rules := admin.Group("/rules")
+		rules := routers.NewGroup(admin.Group("/rules"), "权限规则管理")
 		{
 			rules_controller := controller.NewRulesController()
-			//rules.POST("/add", rules_controller.Insert)
-			//rules.PUT("/edit/:id", rules_controller.Update)
-			//rules.DELETE("/delete/:id", rules_controller.Delete)
-			rules.GET("/list", rules_controller.Select)
-			//rules.GET("/info/:id", rules_controller.Find)
+			rules.POST("/add", "规则添加", rules_controller.Insert)
+			rules.PUT("/edit/:id", "规则编辑", rules_controller.Update)
+			rules.DELETE("/delete/:id", "规则删除", rules_controller.Delete)
+			rules.GET("/list", "规则列表", rules_controller.Select)
+			rules.GET("/info/:id", "规则信息", rules_controller.Find)
+		}
+		// 注册租户控制器路由分组
+		tenants := routers.NewGroup(admin.Group("/tenant"), "租户管理")
+		{
+			tenant_controller := controller.NewTenantController()
+			tenants.POST("/add", "租户添加", tenant_controller.Insert)
+			tenants.PUT("/edit/:id", "租户编辑", tenant_controller.Update)
+			tenants.POST("/list", "租户列表", tenant_controller.Select)
+			tenants.PUT("/suspend/:id", "租户停用", tenant_controller.Suspend)
+			tenants.PUT("/resume/:id", "租户恢复", tenant_controller.Resume)
 		}
 
+		// 注册邀请码控制器路由分组
+		invitation := routers.NewGroup(admin.Group("/invitation"), "邀请码管理")
+		{
+			invitation_controller := controller.NewInvitationController()
+			invitation.POST("/add", "邀请码生成", invitation_controller.Insert)
+			invitation.POST("/list", "邀请码列表", invitation_controller.Select)
+			invitation.DELETE("/delete/:id", "邀请码删除", invitation_controller.Delete)
+		}
+		// 注册登录日志控制器路由分组
+		loginLog := routers.NewGroup(admin.Group("/loginLog"), "登录日志")
+		{
+			login_log_controller := controller.NewLoginLogController()
+			loginLog.POST("/list", "登录日志列表", login_log_controller.Select)
+		}
+		// 注册审计日志控制器路由分组
+		audit := routers.NewGroup(admin.Group("/audit"), "审计日志")
+		{
+			audit_controller := controller.NewAuditController()
+			audit.POST("/list", "审计日志列表", audit_controller.Select)
+		}
+		// 注册日志管理控制器路由分组
+		logger := routers.NewGroup(admin.Group("/logger"), "日志管理")
+		{
+			logger_controller := controller.NewLoggerController()
+			logger.GET("/level", "日志级别查看", logger_controller.GetLevel)
+			logger.PUT("/level", "日志级别设置", logger_controller.SetLevel)
+		}
+		// 注册配置管理控制器路由分组
+		configs := routers.NewGroup(admin.Group("/config"), "配置管理")
+		{
+			config_controller := controller.NewConfigController()
+			configs.GET("", "配置查看", config_controller.Select)
+		}
 		// 注册存储控制器路由分组
-		storage := admin.Group("/storage")
+		storage := routers.NewGroup(admin.Group("/storage"), "存储管理")
 		{
 			storage_controller := controller.NewStorageController()
-			storage.DELETE("/delete/:id", storage_controller.Delete)
-			storage.POST("/list", storage_controller.Select)
+			storage.DELETE("/delete/:id", "存储删除", storage_controller.Delete)
+			storage.POST("/list", "存储列表", storage_controller.Select)
 		}
 		// 注册系统设置控制器路由分组
-		system := admin.Group("/setting")
+		system := routers.NewGroup(admin.Group("/setting"), "系统设置")
 		{
 			system_controller := controller.NewSettingsController()
-			system.PUT("/update", system_controller.UpdateSettings)
+			system.PUT("/update", "系统设置更新", system_controller.UpdateSettings)
 		}
 		// 注册客户控制器路由分组
-		customer := admin.Group("/customer")
+		customer := routers.NewGroup(admin.Group("/customer"), "客户管理")
 		{
 			customer_controller := controller.NewCustomerControllerImpl()
-			customer.POST("/add", customer_controller.Insert)
-			customer.PUT("/edit/:id", customer_controller.Update)
-			customer.DELETE("/delete/:id", customer_controller.Delete)
-			customer.POST("/list", customer_controller.Select)
-			customer.GET("/info/:id", customer_controller.Find)
-			customer.GET("/getAll", customer_controller.GetAll)
+			customer.POST("/add", "客户添加", customer_controller.Insert)
+			customer.PUT("/edit/:id", "客户编辑", customer_controller.Update)
+			customer.DELETE("/delete/:id", "客户删除", customer_controller.Delete)
+			customer.POST("/list", "客户列表", customer_controller.Select)
+			customer.GET("/info/:id", "客户信息", customer_controller.Find)
+			customer.GET("/getAll", "获取所有客户", customer_controller.GetAll)
 		}
 		// 注册服装控制器路由分组
-		clothes := admin.Group("/clothes")
+		clothes := routers.NewGroup(admin.Group("/clothes"), "服装管理")
 		{
 			// 注册服装款式控制器路由分组
-			sample := clothes.Group("/sample")
+			sample := clothes.Group("/sample", "款式管理")
 			{
 				sample_controller := controller.NewSampleController()
-				sample.POST("/add", sample_controller.Insert)
-				sample.PUT("/edit/:id", sample_controller.Update)
-				sample.DELETE("/delete/:id", sample_controller.Delete)
-				sample.POST("/list", sample_controller.Select)
-				sample.GET("/info/:id", sample_controller.Find)
-				sample.GET("/getAll", sample_controller.GetAll)
+				sample.POST("/add", "款式添加", sample_controller.Insert)
+				sample.PUT("/edit/:id", "款式编辑", sample_controller.Update)
+				sample.DELETE("/delete/:id", "款式删除", sample_controller.Delete)
+				sample.POST("/list", "款式列表", sample_controller.Select)
+				sample.GET("/info/:id", "款式信息", sample_controller.Find)
+				sample.GET("/getAll", "获取所有款式", sample_controller.GetAll)
 			}
 
 			// 注册服装颜色控制器路由分组
-			color := clothes.Group("/color")
+			color := clothes.Group("/color", "颜色管理")
 			{
 				color_controller := controller.NewColorControllerImpl()
-				color.POST("/add", color_controller.Insert)
-				color.PUT("/edit/:id", color_controller.Update)
-				color.DELETE("/delete/:id", color_controller.Delete)
-				color.POST("/list", color_controller.Select)
-				color.GET("/getAll", color_controller.GetAll)
+				color.POST("/add", "颜色添加", color_controller.Insert)
+				color.PUT("/edit/:id", "颜色编辑", color_controller.Update)
+				color.DELETE("/delete/:id", "颜色删除", color_controller.Delete)
+				color.POST("/list", "颜色列表", color_controller.Select)
+				color.GET("/getAll", "获取所有颜色", color_controller.GetAll)
 			}
 		}
 	}
@@ -126,6 +203,17 @@ func Routes(r *gin.Engine) {
 		app.Use(middleware.JWTCheck())
 		common_controller := controller.NewCommonController()
 		app.GET("/getUserInfo", common_controller.GetUserInfo)
+		app.GET("/permissions", common_controller.Permissions)
+		app.POST("/logout", common_controller.Logout)
+		mfa_controller := controller.NewMfaController()
+		app.POST("/mfa/disable", mfa_controller.Disable)
+		app.POST("/mfa/recovery", mfa_controller.RecoveryCodes)
+		password_controller := controller.NewPasswordController()
+		app.POST("/password/change", password_controller.Change)
+		apikey_controller := controller.NewApiKeyController()
+		app.GET("/apikey/list", apikey_controller.Select)
+		app.POST("/apikey/add", apikey_controller.Insert)
+		app.DELETE("/apikey/delete/:id", apikey_controller.Delete)
 	}
 	// 注册系统设置控制器路由分组
 	system := r.Group("/settings")
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"FlyCloud/pkg/response"
+	"FlyCloud/pkg/system"
+	"FlyCloud/pkg/tenant"
+	"FlyCloud/pkg/version"
+	acs "FlyCloud/serves/casbin"
+	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// @Title HealthController
+// @Description 健康检查控制器，供负载均衡和容器编排探测服务状态，无需登录
+
+// 单项检查的超时时间
+const checkTimeout = 2 * time.Second
+
+// 定义健康检查控制器
+type HealthController interface {
+	Healthz(ctx *gin.Context)
+	Readyz(ctx *gin.Context)
+	Version(ctx *gin.Context)
+}
+
+// 定义健康检查控制器
+type healthController struct {
+	Db *gorm.DB
+}
+
+// 实例化健康检查控制器
+func NewHealthController() *healthController {
+	return &healthController{Db: database.GetDB()}
+}
+
+// 单项检查的结果
+type checkResult struct {
+	Status string `json:"status"`
+	// 检查耗时，单位毫秒
+	Latency float64 `json:"latency_ms"`
+}
+
+// @Title Healthz
+// @Description 存活检查，进程能处理请求即返回成功，不检查依赖的服务
+// @Success 200 {status} status string "ok"
+// @router /healthz [get]
+func (c *healthController) Healthz(ctx *gin.Context) {
+	response.Success(ctx, gin.H{"status": "ok"}, "ok")
+}
+
+// @Title Readyz
+// @Description 就绪检查，依次检查数据库连接、权限策略和存储目录是否可写，任意一项失败时返回503
+// @Success 200 {status,checks} status string,checks object "各项检查的结果和耗时"
+// @Failure 503 {status,checks} status string,checks object "各项检查的结果和耗时"
+// @router /readyz [get]
+func (c *healthController) Readyz(ctx *gin.Context) {
+	checks := map[string]checkResult{
+		"database": run("database", func() error { return c.pingDatabase(ctx.Request.Context()) }),
+		"casbin":   run("casbin", acs.Ready),
+		"storage":  run("storage", checkStorage),
+	}
+	for _, result := range checks {
+		if result.Status != "ok" {
+			response.Response(ctx, http.StatusServiceUnavailable, http.StatusServiceUnavailable,
+				gin.H{"status": "fail", "checks": checks}, "服务未就绪")
+			return
+		}
+	}
+	response.Success(ctx, gin.H{"status": "ok", "checks": checks}, "ok")
+}
+
+// @Title Version
+// @Description 获取构建信息，包括git提交、构建时间和数据库结构版本
+// @Success 200 {commit,build_time,schema_version,go_version,modified} version.Info "构建信息"
+// @router /version [get]
+func (c *healthController) Version(ctx *gin.Context) {
+	info := version.Get()
+	response.Success(ctx, gin.H{
+		"commit":         info.Commit,
+		"build_time":     info.BuildTime,
+		"schema_version": info.SchemaVersion,
+		"go_version":     info.GoVersion,
+		"modified":       info.Modified,
+	}, "获取成功")
+}
+
+// 执行单项检查并记录耗时，失败原因可能包含连接串或路径，只写入日志不对外返回
+func run(name string, check func() error) checkResult {
+	start := time.Now()
+	err := check()
+	result := checkResult{Status: "ok", Latency: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = "fail"
+		logging.Warn("就绪检查失败：", name, " ", err)
+	}
+	return result
+}
+
+// 检查数据库连接
+func (c *healthController) pingDatabase(ctx context.Context) error {
+	if c.Db == nil {
+		return errors.New("数据库未连接")
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	return c.Db.DB().PingContext(ctx)
+}
+
+// 检查存储目录是否可写，写入并删除一个临时文件
+func checkStorage() error {
+	root := tenant.StorageRoot()
+	if isE, _ := system.IsExist(root); !isE {
+		if err := system.MkDir(root); err != nil {
+			return err
+		}
+	}
+	file, err := os.CreateTemp(root, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	_, err = file.WriteString("ok")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	return err
+}
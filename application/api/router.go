@@ -1,6 +1,11 @@
 package api
 
-import "github.com/gin-gonic/gin"
+import (
+	"FlyCloud/application/api/controller"
+	"FlyCloud/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+)
 
 func Routes(r *gin.Engine) {
 	r.GET("/ping", func(c *gin.Context) {
@@ -8,4 +13,14 @@ func Routes(r *gin.Engine) {
 			"message": "pong",
 		})
 	})
+	// 健康检查，供负载均衡和容器编排探测服务状态
+	health_controller := controller.NewHealthController()
+	r.GET("/healthz", health_controller.Healthz)
+	r.GET("/readyz", health_controller.Readyz)
+	r.GET("/version", health_controller.Version)
+	// 公开JWT验证公钥，供其它服务验证FlyCloud签发的令牌
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, jwt.JWKS())
+	})
 }
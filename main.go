@@ -1,8 +1,20 @@
 package main
 
-import "FlyCloud/serves/app"
+import (
+	"FlyCloud/serves/app"
+	"FlyCloud/serves/config"
+	"flag"
+)
 
 func main() {
+	// 全局参数需要写在子命令之前，例如 FlyCloud --config /etc/flycloud.yaml policy export
+	file := flag.String("config", "", "配置文件路径，默认读取 FLYCLOUD_CONFIG 环境变量或 "+config.DefaultFile)
+	flag.Parse()
+	config.SetFile(*file)
+	// 执行命令行子命令
+	if app.Command(flag.Args()) {
+		return
+	}
 	// 启动服务
 	app.Start()
 }
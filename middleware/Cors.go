@@ -1,17 +1,65 @@
 package middleware
 
 import (
+	"FlyCloud/serves/config"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"reflect"
+	"sync/atomic"
 )
 
+// 允许跨域访问的来源
+type corsOrigins struct {
+	// 是否允许所有来源
+	all  bool
+	list map[string]bool
+}
+
+// 当前生效的跨域来源，配置修改后整体替换
+var origins atomic.Value
+
+// 按配置生成允许跨域访问的来源，未配置时允许所有来源
+func newCorsOrigins(cfg *config.CorsConfig) *corsOrigins {
+	o := &corsOrigins{list: make(map[string]bool)}
+	if cfg == nil {
+		o.all = true
+		return o
+	}
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			o.all = true
+		}
+		o.list[origin] = true
+	}
+	return o
+}
+
+// OnCorsConfigChange 跨域配置修改后立即生效
+func OnCorsConfigChange(old, new *config.ConfigStruct) {
+	if reflect.DeepEqual(old.CorsConfig, new.CorsConfig) {
+		return
+	}
+	origins.Store(newCorsOrigins(new.CorsConfig))
+}
+
 // CORS跨域请求头
 func CorsMiddleware() gin.HandlerFunc {
+	origins.Store(newCorsOrigins(config.GetConfig().CorsConfig))
 	return func(ctx *gin.Context) {
 		method := ctx.Request.Method
-		ctx.Header("Access-Control-Allow-Origin", "*")
+		allowed := origins.Load().(*corsOrigins)
+		if allowed.all {
+			ctx.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			// 只返回允许的来源，其他来源的跨域请求由浏览器拦截
+			ctx.Writer.Header().Add("Vary", "Origin")
+			if origin := ctx.GetHeader("Origin"); allowed.list[origin] {
+				ctx.Header("Access-Control-Allow-Origin", origin)
+			}
+		}
 		ctx.Header("Access-Control-Allow-Methods", "POST,GET,OPTIONS,DELETE,PUT")
-		ctx.Header("Access-Control-Allow-Headers", "Content-Type,Content-Length,Accept-Encoding,X-Requested-with, Origin, Authorization")
+		ctx.Header("Access-Control-Allow-Headers", "Content-Type,Content-Length,Accept-Encoding,X-Requested-with, Origin, Authorization, X-Request-ID")
+		ctx.Header("Access-Control-Expose-Headers", "X-Request-ID")
 		ctx.Header("Content-Type", "application/json;charset=UTF-8")
 		// 放行OPTIONS
 		if method == "OPTIONS" {
@@ -1,27 +1,34 @@
 package middleware
 
 import (
+	"FlyCloud/pkg/account"
+	"FlyCloud/pkg/apikey"
 	"FlyCloud/pkg/jwt"
 	"FlyCloud/pkg/response"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 )
 
 func JWTCheck() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-
-		tokenString := ctx.GetHeader("Authorization")
-		if tokenString == "" {
-			response.Error(ctx, "token is empty", http.StatusUnauthorized)
-			ctx.Abort()
+		// 使用API密钥访问
+		if raw := apiKeyHeader(ctx); raw != "" {
+			claim, ok := checkApiKey(ctx, raw)
+			if !ok || !apiKeyAllowed(ctx) {
+				return
+			}
+			ctx.Set("claim", claim)
+			ctx.Next()
 			return
 		}
-		// 声明jwt实例
-		j := jwt.NewJwt()
-		// 验证token
-		_, claim, err := j.ParseToken(tokenString)
-		if err != nil {
-			response.Error(ctx, err.Error(), http.StatusUnauthorized)
+		claim, ok := checkToken(ctx)
+		if !ok {
+			return
+		}
+		// 两步验证临时令牌不能访问业务接口
+		if claim.TokenType != "" {
+			response.Error(ctx, "token类型错误", http.StatusUnauthorized)
 			ctx.Abort()
 			return
 		}
@@ -30,3 +37,69 @@ func JWTCheck() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// 解析并验证请求头中的token，失败时直接返回错误响应
+func checkToken(ctx *gin.Context) (*jwt.CustomClaims, bool) {
+	tokenString := ctx.GetHeader("Authorization")
+	if tokenString == "" {
+		response.Error(ctx, "token is empty", http.StatusUnauthorized)
+		ctx.Abort()
+		return nil, false
+	}
+	// 声明jwt实例
+	j := jwt.NewJwt()
+	// 验证token
+	_, claim, err := j.ParseToken(tokenString)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusUnauthorized)
+		ctx.Abort()
+		return nil, false
+	}
+	// 验证token是否已被吊销
+	if jwt.IsRevoked(claim) {
+		response.Error(ctx, "token已失效", http.StatusUnauthorized)
+		ctx.Abort()
+		return nil, false
+	}
+	// 验证账号是否已被禁用
+	state, err := account.Check(claim.UserId)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusUnauthorized)
+		ctx.Abort()
+		return nil, false
+	}
+	// 以当前的角色为准，角色变更后立即生效
+	claim.UserRole = state.Role
+	claim.TenantId = state.TenantId
+	return claim, true
+}
+
+// 获取请求头中的API密钥，支持X-API-Key和Authorization: ApiKey两种方式
+func apiKeyHeader(ctx *gin.Context) string {
+	if raw := ctx.GetHeader("X-API-Key"); raw != "" {
+		return strings.TrimSpace(raw)
+	}
+	if auth := ctx.GetHeader("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "ApiKey ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// 验证API密钥，以密钥所有者的身份生成claim，失败时直接返回错误响应
+func checkApiKey(ctx *gin.Context, raw string) (*jwt.CustomClaims, bool) {
+	key, err := apikey.Authenticate(raw, ctx.ClientIP())
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusUnauthorized)
+		ctx.Abort()
+		return nil, false
+	}
+	// 验证密钥所有者是否已被禁用
+	state, err := account.Check(key.AdminId)
+	if err != nil {
+		response.Error(ctx, err.Error(), http.StatusUnauthorized)
+		ctx.Abort()
+		return nil, false
+	}
+	ctx.Set("apikey", key)
+	return &jwt.CustomClaims{UserId: key.AdminId, UserRole: state.Role, TenantId: state.TenantId}, true
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/response"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 两步验证绑定接口的鉴权，同时接受访问令牌和需要绑定两步验证的临时令牌
+func MfaCheck() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claim, ok := checkToken(ctx)
+		if !ok {
+			return
+		}
+		if claim.TokenType != "" && !(claim.TokenType == jwt.TokenTypeMfa && claim.MfaSetup) {
+			response.Error(ctx, "token类型错误", http.StatusUnauthorized)
+			ctx.Abort()
+			return
+		}
+		// 将验证通过的信息放入上下文
+		ctx.Set("claim", claim)
+		ctx.Next()
+	}
+}
@@ -1,9 +1,10 @@
 package middleware
 
 import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/apikey"
 	"FlyCloud/pkg/jwt"
 	"FlyCloud/pkg/response"
-	"FlyCloud/serves/cache"
 	acs "FlyCloud/serves/casbin"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -20,55 +21,48 @@ func AdminPrivilege() gin.HandlerFunc {
 			ctx.Abort()
 			return
 		} else {
-			// 如果用户是超级管理员，则直接放行
-			if claim.UserRole == "super" {
+			// 使用API密钥访问时，先验证密钥的权限范围，超级管理员的密钥同样受限
+			if !apiKeyAllowed(ctx) {
+				return
+			}
+			// 如果用户的主角色或附加角色是超级管理员，则直接放行
+			dom := acs.Domain(claim.TenantId)
+			if acs.IsSuper(claim.UserId, claim.UserRole, dom) {
 				ctx.Next()
 				return
 			} else { // 如果不是超级管理员，则需要判断用户是否有权限访问该资源
-				// 实例化缓存
-				ce := cache.GetCacheObj()
-				// 实例化enforce实例
-				enforcer := acs.GetEnforcer()
 				// 从ctx中获取路由信息
 				path := ctx.Request.URL.Path
 				method := ctx.Request.Method
-				// 定义缓存key
-				cache_key := claim.UserRole + path + method
-				// 判断缓存中是否存在该key
-				entry, err := ce.Get(cache_key)
-				if err == nil && entry != nil {
-					if string(entry) == "true" {
-						ctx.Next()
-					} else {
-						response.Error(ctx, "没有权限访问该资源", http.StatusForbidden)
-						ctx.Abort()
-						return
-					}
-				} else {
-					// 加载策略
-					err := enforcer.LoadPolicy()
-					if err != nil {
-						response.Error(ctx, "加载策略失败", http.StatusInternalServerError)
-					}
-					// 判断用户是否有权限访问该资源
-					result, err := enforcer.EnforceSafe(claim.UserRole, path, method)
-					if err != nil {
-						response.Error(ctx, "权限表找不到该资源", http.StatusForbidden)
-						ctx.Abort()
-						return
-					}
-					if !result {
-						ce.Set(cache_key, []byte("false"))
-						response.Error(ctx, "没有权限访问该资源", http.StatusForbidden)
-						ctx.Abort()
-						return
-					} else {
-						ce.Set(cache_key, []byte("true"))
-					}
-					ctx.Next()
+				// 判断用户是否有权限访问该资源，结果按用户缓存
+				result, err := acs.CachedEnforce(claim.UserId, claim.UserRole, dom, path, method)
+				if err != nil {
+					response.Error(ctx, "权限表找不到该资源", http.StatusForbidden)
+					ctx.Abort()
+					return
 				}
+				if !result {
+					response.Error(ctx, "没有权限访问该资源", http.StatusForbidden)
+					ctx.Abort()
+					return
+				}
+				ctx.Next()
 			}
 		}
 
 	}
 }
+
+// 验证API密钥的权限范围，非API密钥访问时直接通过
+func apiKeyAllowed(ctx *gin.Context) bool {
+	value, exists := ctx.Get("apikey")
+	if !exists {
+		return true
+	}
+	if !apikey.Allowed(value.(*models.ApiKey), ctx.Request.Method, ctx.Request.URL.Path) {
+		response.Error(ctx, "API密钥没有权限访问该资源", http.StatusForbidden)
+		ctx.Abort()
+		return false
+	}
+	return true
+}
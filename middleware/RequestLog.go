@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/response"
+	"FlyCloud/serves/logging"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求id的请求头和响应头
+const RequestIdHeader = "X-Request-ID"
+
+// 客户端传入的请求id只接受字母、数字和少量符号，避免写入日志的内容被伪造
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestId 为每个请求分配请求id，客户端已传入时沿用，并在响应头中返回
+func RequestId() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIdHeader)
+		if !requestIdPattern.MatchString(id) {
+			id = newRequestId()
+		}
+		logging.SetRequest(ctx, id)
+		ctx.Header(RequestIdHeader, id)
+		ctx.Next()
+	}
+}
+
+// AccessLog 记录请求的方法、路径、状态码、耗时和当前用户
+func AccessLog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		fields := []interface{}{
+			"method", ctx.Request.Method,
+			"path", ctx.Request.URL.Path,
+			"route", ctx.FullPath(),
+			"status", ctx.Writer.Status(),
+			"latency", time.Since(start),
+			"ip", ctx.ClientIP(),
+		}
+		// 登录后的请求记录用户id和角色
+		if v, ok := ctx.Get("claim"); ok {
+			if claim, ok := v.(*jwt.CustomClaims); ok {
+				fields = append(fields, "user_id", claim.UserId, "role", claim.UserRole)
+			}
+		}
+		if len(ctx.Errors) > 0 {
+			fields = append(fields, "errors", ctx.Errors.String())
+		}
+		logger := logging.Ctx(ctx)
+		if logger == nil {
+			return
+		}
+		if ctx.Writer.Status() >= http.StatusInternalServerError {
+			logger.Errorw("请求处理失败", fields...)
+		} else {
+			logger.Infow("请求", fields...)
+		}
+	}
+}
+
+// Recovery 捕获处理请求时的异常，记录堆栈并返回错误信息
+func Recovery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// 客户端已断开连接时无法返回响应
+			brokenPipe := isBrokenPipe(err)
+			if logger := logging.Ctx(ctx); logger != nil {
+				logger.Errorw("请求处理异常", "error", err, "stack", string(debug.Stack()))
+			}
+			if brokenPipe || ctx.Writer.Written() {
+				ctx.Abort()
+				return
+			}
+			response.Response(ctx, http.StatusInternalServerError, http.StatusInternalServerError, gin.H{
+				"request_id": logging.RequestId(ctx),
+			}, "服务器内部错误")
+			ctx.Abort()
+		}()
+		ctx.Next()
+	}
+}
+
+// 生成随机的请求id
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// 判断异常是否由客户端断开连接引起
+func isBrokenPipe(err interface{}) bool {
+	ne, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	se, ok := ne.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
@@ -2,25 +2,42 @@ package models
 
 import (
 	"FlyCloud/pkg/Db"
-	"FlyCloud/pkg/md5"
+	"FlyCloud/pkg/password"
 
 	"github.com/jinzhu/gorm"
 )
 
+// 管理员状态
+const (
+	// 已启用
+	AdminStatusEnabled = 1
+	// 已禁用
+	AdminStatusDisabled = 2
+	// 注册后等待审核
+	AdminStatusPending = 3
+)
+
 // Admin struct
 type Admin struct {
 	Db.Field
+	// 所属租户
+	TenantId        uint   `gorm:"not null;default:1;index" json:"-"`
 	Username        string `gorm:"type:varchar(100);unique_index" json:"username"`
-	Password        string `gorm:"type:varchar(255)" json:"password"`
+	Password        string `gorm:"type:varchar(255)" json:"-"`
 	Sex             string `gorm:"type:varchar(4);not null;DEFAULT:'未知'" json:"sex"`
 	Nickname        string `gorm:"type:varchar(15)" json:"nickname"`
 	Telephone       string `gorm:"type:varchar(15);not null;unique" json:"telephone"`
+	Email           string `gorm:"type:varchar(100)" json:"email"`
 	Department      string `gorm:"type:varchar(45)" json:"department"`
 	ImgSrc          string `gorm:"type:text" json:"img_src"`
 	Description     string `gorm:"type:text" json:"description"`
 	Status          int    `gorm:"type:int(1);default(1)" json:"status"`
 	RolesName       string `gorm:"type:varchar(255)" json:"roles_name"`
 	Roles           Roles  `gorm:"foreignKey:RolesName;association_foreignkey:Alias" json:"roles"`
+	TotpSecret      string `gorm:"type:varchar(64)" json:"-"`
+	TotpEnabled     int    `gorm:"type:int(1);default:0" json:"totp_enabled"`
+	TotpLastStep    int64  `gorm:"type:bigint" json:"-"`
+	LockedUntil     int64  `gorm:"type:bigint" json:"locked_until"`
 	ConfirmPassword string `gorm:"-" json:"confirm_password"`
 	Captcha         string `gorm:"-" json:"captcha"`
 	Appid           string `gorm:"-" json:"appid"`
@@ -37,19 +54,24 @@ func InitAdminTable(db *gorm.DB) {
 	if db.HasTable("admin") != true {
 		// 创建表
 		db.CreateTable(&Admin{})
+		// 生成初始密码哈希
+		hash, _ := password.Hash("123456")
 		// 新增初始数据
 		db.Create(&Admin{
 			Username:    "admin",
-			Password:    md5.Encry("123456"),
+			Password:    hash,
 			Nickname:    "管理员",
 			Telephone:   "12345678901",
 			Sex:         "男",
 			ImgSrc:      "https://q.qlogo.cn/g?b=qq&nk=804966813&s=640",
-			Status:      1,
+			Status:      AdminStatusEnabled,
 			RolesName:   "super",
 			Department:  "管理员",
 			Description: "超级管理员",
 		})
+	} else {
+		// 补充新增的字段
+		db.AutoMigrate(&Admin{})
 	}
 }
 
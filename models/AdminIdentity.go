@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 管理员在外部身份提供方的身份，用于单点登录
+type AdminIdentity struct {
+	ID          uint       `gorm:"primary_key" json:"id"`
+	AdminId     uint       `gorm:"type:int(11);not null;index" json:"admin_id"`
+	Issuer      string     `gorm:"type:varchar(255);not null;unique_index:idx_identity_subject" json:"issuer"`
+	Subject     string     `gorm:"type:varchar(255);not null;unique_index:idx_identity_subject" json:"subject"`
+	Email       string     `gorm:"type:varchar(100)" json:"email"`
+	LastLoginAt *time.Time `gorm:"column:last_login_at" json:"last_login_at"`
+	CreatedAt   time.Time  `gorm:"column:create_time" json:"create_time"`
+}
+
+// TableName 设置表名
+func (AdminIdentity) TableName() string {
+	return "admin_identity"
+}
+
+// InitAdminIdentityTable 初始化外部身份表
+func InitAdminIdentityTable(db *gorm.DB) {
+	if !db.HasTable(&AdminIdentity{}) {
+		db.CreateTable(&AdminIdentity{})
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 管理员的个人API密钥，仅保存密钥的哈希值
+type ApiKey struct {
+	ID      uint   `gorm:"primary_key" json:"id"`
+	AdminId uint   `gorm:"type:int(11);not null;index" json:"admin_id"`
+	Name    string `gorm:"type:varchar(100)" json:"name"`
+	// 密钥前缀，用于识别密钥
+	Prefix  string `gorm:"type:varchar(16)" json:"prefix"`
+	KeyHash string `gorm:"type:varchar(64);not null;unique_index" json:"-"`
+	// 权限范围，多个以逗号分隔，格式为“请求方法 路径”，路径规则与Casbin的keyMatch2一致
+	Scopes     string     `gorm:"type:text" json:"scopes"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	LastUsedIp string     `gorm:"type:varchar(64)" json:"last_used_ip"`
+	CreatedAt  time.Time  `gorm:"column:create_time" json:"create_time"`
+}
+
+// TableName 设置表名
+func (ApiKey) TableName() string {
+	return "admin_api_key"
+}
+
+// InitApiKeyTable 初始化API密钥表
+func InitApiKeyTable(db *gorm.DB) {
+	if !db.HasTable(&ApiKey{}) {
+		db.CreateTable(&ApiKey{})
+	}
+}
+
+// ScopeList 获取权限范围列表
+func (key *ApiKey) ScopeList() []string {
+	var list []string
+	for _, v := range strings.Split(key.Scopes, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Expired 密钥是否已过期
+func (key *ApiKey) Expired() bool {
+	return key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)
+}
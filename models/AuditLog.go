@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 审计操作
+const (
+	// 新增
+	AuditActionCreate = "create"
+	// 修改
+	AuditActionUpdate = "update"
+	// 删除
+	AuditActionDelete = "delete"
+)
+
+// 审计日志保留天数的默认值，0表示永久保留
+const DefaultAuditRetentionDays = "180"
+
+// 审计日志，记录管理操作对数据的修改
+type AuditLog struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	TenantId  uint      `gorm:"not null;default:1;index" json:"-"`
+	AdminId   uint      `gorm:"index" json:"admin_id"`
+	Ip        string    `gorm:"type:varchar(64)" json:"ip"`
+	Method    string    `gorm:"type:varchar(10)" json:"method"`
+	Route     string    `gorm:"type:varchar(255);index" json:"route"`
+	Action    string    `gorm:"type:varchar(10)" json:"action"`
+	Entity    string    `gorm:"type:varchar(100);index" json:"entity"`
+	EntityId  string    `gorm:"type:varchar(255);index" json:"entity_id"`
+	Changes   string    `gorm:"type:text" json:"changes"`
+	CreatedAt time.Time `gorm:"column:create_time;index" json:"create_time"`
+	StartTime string    `gorm:"-" json:"start_time"`
+	EndTime   string    `gorm:"-" json:"end_time"`
+	PageNum   int       `gorm:"-" json:"pageNum"`
+	PageSize  int       `gorm:"-" json:"pageSize"`
+}
+
+// TableName 设置表名
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+// InitAuditLogTable 初始化审计日志表
+func InitAuditLogTable(db *gorm.DB) {
+	if !db.HasTable(&AuditLog{}) {
+		db.CreateTable(&AuditLog{})
+	}
+}
+
+// 过滤空值，并生成查询条件
+func (log *AuditLog) Filter(Db *gorm.DB) *gorm.DB {
+	if log.AdminId != 0 {
+		Db = Db.Where("admin_id = ?", log.AdminId)
+	}
+	if log.Ip != "" {
+		Db = Db.Where("ip = ?", log.Ip)
+	}
+	if log.Route != "" {
+		Db = Db.Where("route like ?", "%"+log.Route+"%")
+	}
+	if log.Action != "" {
+		Db = Db.Where("action = ?", log.Action)
+	}
+	if log.Entity != "" {
+		Db = Db.Where("entity = ?", log.Entity)
+	}
+	if log.EntityId != "" {
+		Db = Db.Where("entity_id = ?", log.EntityId)
+	}
+	if log.StartTime != "" {
+		Db = Db.Where("create_time >= ?", log.StartTime)
+	}
+	if log.EndTime != "" {
+		Db = Db.Where("create_time <= ?", log.EndTime)
+	}
+	return Db
+}
+
+// 删除指定时间之前的审计日志
+func CleanAuditLog(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("create_time < ?", before).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
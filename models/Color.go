@@ -8,8 +8,10 @@ import (
 // Color struct
 type Color struct {
 	Db.Field
-	Name  string `gorm:"type:varchar(255);not null"`
-	Value string `gorm:"type:text"`
+	// 所属租户
+	TenantId uint   `gorm:"not null;default:1;index" json:"-"`
+	Name     string `gorm:"type:varchar(255);not null"`
+	Value    string `gorm:"type:text"`
 }
 
 // TableName set table name
@@ -22,5 +24,8 @@ func InitColorTable(db *gorm.DB) {
 	// 判断表是否存在
 	if !db.HasTable(&Color{}) {
 		db.CreateTable(&Color{})
+	} else {
+		// 补充新增的字段
+		db.AutoMigrate(&Color{})
 	}
 }
@@ -15,6 +15,10 @@ type Customer struct {
 	Company string `gorm:"type:varchar(100);" json:"company"`
 	Notes   string `gorm:"type:varchar(100);" json:"notes"`
 	Status  int    `gorm:"type:int(2);" json:"status"`
+	// 创建客户的管理员，用于数据范围过滤
+	CreatedBy uint `gorm:"column:created_by;index" json:"created_by"`
+	// 所属租户
+	TenantId uint `gorm:"not null;default:1;index" json:"-"`
 }
 
 // TableName sets the insert table name for this struct type
@@ -27,5 +31,8 @@ func InitCustomerTable(db *gorm.DB) {
 	// 判断表是否存在，不存在则创建
 	if !db.HasTable(&Customer{}) {
 		db.CreateTable(&Customer{})
+	} else {
+		// 补充新增的字段
+		db.AutoMigrate(&Customer{})
 	}
 }
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 邀请码无效
+var ErrInvitationInvalid = errors.New("邀请码无效或已过期")
+
+// 注册邀请码，仅保存哈希值，明文只在创建时返回一次
+type Invitation struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	TenantId  uint       `gorm:"not null;default:1;index" json:"-"`
+	CodeHash  string     `gorm:"type:varchar(64);not null;unique_index" json:"-"`
+	Prefix    string     `gorm:"type:varchar(8)" json:"prefix"`
+	RolesName string     `gorm:"type:varchar(255)" json:"roles_name"`
+	MaxUses   int        `gorm:"type:int(11);default:1" json:"max_uses"`
+	UsedCount int        `gorm:"type:int(11);default:0" json:"used_count"`
+	ExpiresAt *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	CreatedBy uint       `gorm:"type:int(11)" json:"created_by"`
+	Remark    string     `gorm:"type:varchar(255)" json:"remark"`
+	CreatedAt time.Time  `gorm:"column:create_time" json:"create_time"`
+	PageNum   int        `gorm:"-" json:"pageNum"`
+	PageSize  int        `gorm:"-" json:"pageSize"`
+}
+
+// TableName 设置表名
+func (Invitation) TableName() string {
+	return "admin_invitation"
+}
+
+// InitInvitationTable 初始化邀请码表
+func InitInvitationTable(db *gorm.DB) {
+	if !db.HasTable(&Invitation{}) {
+		db.CreateTable(&Invitation{})
+	} else {
+		// 补充新增的字段
+		db.AutoMigrate(&Invitation{})
+	}
+}
+
+// 计算邀请码哈希，忽略大小写和分隔符
+func hashInvitationCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成邀请码并保存，返回明文邀请码
+func CreateInvitation(db *gorm.DB, invitation *Invitation) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := hex.EncodeToString(b)
+	code := raw[:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:]
+	invitation.CodeHash = hashInvitationCode(code)
+	invitation.Prefix = raw[:4]
+	if invitation.MaxUses <= 0 {
+		invitation.MaxUses = 1
+	}
+	invitation.UsedCount = 0
+	if err := db.Create(invitation).Error; err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// 查找可用的邀请码
+func FindInvitation(db *gorm.DB, code string) (*Invitation, error) {
+	var invitation Invitation
+	if err := db.Where("code_hash = ?", hashInvitationCode(code)).First(&invitation).Error; err != nil {
+		return nil, ErrInvitationInvalid
+	}
+	if invitation.UsedCount >= invitation.MaxUses || (invitation.ExpiresAt != nil && time.Now().After(*invitation.ExpiresAt)) {
+		return nil, ErrInvitationInvalid
+	}
+	return &invitation, nil
+}
+
+// 使用一次邀请码，条件更新防止并发使用超出次数
+func UseInvitation(db *gorm.DB, invitation *Invitation) error {
+	result := db.Model(&Invitation{}).
+		Where("id = ? and used_count < max_uses and (expires_at is null or expires_at > ?)", invitation.ID, time.Now()).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationInvalid
+	}
+	return nil
+}
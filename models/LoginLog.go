@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 登录结果
+const (
+	// 登录成功
+	LoginResultSuccess = "success"
+	// 密码已验证，等待两步验证
+	LoginResultMfa = "mfa"
+	// 用户名或密码错误
+	LoginResultFailed = "failed"
+	// 验证码错误
+	LoginResultCaptcha = "captcha"
+	// 账号或IP被锁定、请求过于频繁
+	LoginResultLocked = "locked"
+	// 账号已被禁用
+	LoginResultDisabled = "disabled"
+)
+
+// 登录日志
+type LoginLog struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	AdminId   uint      `gorm:"index" json:"admin_id"`
+	Username  string    `gorm:"type:varchar(100);index" json:"username"`
+	Ip        string    `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(512)" json:"user_agent"`
+	Result    string    `gorm:"type:varchar(20)" json:"result"`
+	Message   string    `gorm:"type:varchar(255)" json:"message"`
+	CreatedAt time.Time `gorm:"column:create_time;index" json:"create_time"`
+	StartTime string    `gorm:"-" json:"start_time"`
+	EndTime   string    `gorm:"-" json:"end_time"`
+	PageNum   int       `gorm:"-" json:"pageNum"`
+	PageSize  int       `gorm:"-" json:"pageSize"`
+}
+
+// TableName 设置表名
+func (LoginLog) TableName() string {
+	return "admin_login_log"
+}
+
+// InitLoginLogTable 初始化登录日志表
+func InitLoginLogTable(db *gorm.DB) {
+	if !db.HasTable(&LoginLog{}) {
+		db.CreateTable(&LoginLog{})
+	}
+}
+
+// 过滤空值，并生成查询条件
+func (log *LoginLog) Filter(Db *gorm.DB) *gorm.DB {
+	if log.AdminId != 0 {
+		Db = Db.Where("admin_id = ?", log.AdminId)
+	}
+	if log.Username != "" {
+		Db = Db.Where("username like ?", "%"+log.Username+"%")
+	}
+	if log.Ip != "" {
+		Db = Db.Where("ip = ?", log.Ip)
+	}
+	if log.Result != "" {
+		Db = Db.Where("result = ?", log.Result)
+	}
+	if log.StartTime != "" {
+		Db = Db.Where("create_time >= ?", log.StartTime)
+	}
+	if log.EndTime != "" {
+		Db = Db.Where("create_time <= ?", log.EndTime)
+	}
+	return Db
+}
+
+// 记录一次登录尝试，写入失败不影响登录流程
+func AddLoginLog(db *gorm.DB, log *LoginLog) {
+	if len(log.UserAgent) > 512 {
+		log.UserAgent = log.UserAgent[:512]
+	}
+	db.Create(log)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"FlyCloud/pkg/password"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 管理员历史密码
+type PasswordHistory struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	AdminId   uint      `gorm:"type:int(11);not null;index" json:"admin_id"`
+	Password  string    `gorm:"type:varchar(255);not null" json:"-"`
+	CreatedAt time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+// TableName 设置表名
+func (PasswordHistory) TableName() string {
+	return "admin_password_history"
+}
+
+// InitPasswordHistoryTable 初始化历史密码表
+func InitPasswordHistoryTable(db *gorm.DB) {
+	if !db.HasTable(&PasswordHistory{}) {
+		db.CreateTable(&PasswordHistory{})
+	}
+}
+
+// 判断密码是否与当前密码或最近N次使用过的密码相同
+func IsPasswordReused(db *gorm.DB, admin *Admin, plain string) bool {
+	size := password.HistorySize()
+	if size <= 0 {
+		return false
+	}
+	if admin.Password != "" && password.Verify(plain, admin.Password) {
+		return true
+	}
+	var histories []PasswordHistory
+	db.Where("admin_id = ?", admin.ID).Order("id desc").Limit(size).Find(&histories)
+	for _, v := range histories {
+		if password.Verify(plain, v.Password) {
+			return true
+		}
+	}
+	return false
+}
+
+// 记录历史密码，并清理超出保留数量的记录
+func AddPasswordHistory(db *gorm.DB, adminId uint, hash string) error {
+	if err := db.Create(&PasswordHistory{AdminId: adminId, Password: hash}).Error; err != nil {
+		return err
+	}
+	size := password.HistorySize()
+	var ids []uint
+	db.Model(&PasswordHistory{}).Where("admin_id = ?", adminId).Order("id desc").Pluck("id", &ids)
+	if size > 0 && len(ids) > size {
+		return db.Where("id in (?)", ids[size:]).Delete(&PasswordHistory{}).Error
+	}
+	return nil
+}
+
+// 修改管理员密码：校验密码策略及历史密码，更新哈希并记录历史
+func UpdateAdminPassword(db *gorm.DB, admin *Admin, plain string) error {
+	if err := password.Validate(plain); err != nil {
+		return err
+	}
+	if IsPasswordReused(db, admin, plain) {
+		return errors.New("不能使用最近使用过的密码")
+	}
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&Admin{}).Where("id = ?", admin.ID).Update("password", hash).Error; err != nil {
+		return err
+	}
+	admin.Password = hash
+	return AddPasswordHistory(db, admin.ID, hash)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 找回密码令牌无效
+var ErrResetTokenInvalid = errors.New("重置链接无效或已过期")
+
+// 找回密码令牌，仅保存令牌的哈希值，使用一次后失效
+type PasswordReset struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	AdminId   uint       `gorm:"type:int(11);not null;index" json:"admin_id"`
+	TokenHash string     `gorm:"type:varchar(64);not null;unique_index" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `gorm:"column:create_time" json:"create_time"`
+}
+
+// TableName 设置表名
+func (PasswordReset) TableName() string {
+	return "admin_password_reset"
+}
+
+// InitPasswordResetTable 初始化找回密码令牌表
+func InitPasswordResetTable(db *gorm.DB) {
+	if !db.HasTable(&PasswordReset{}) {
+		db.CreateTable(&PasswordReset{})
+	}
+}
+
+// 计算找回密码令牌的哈希值
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成找回密码令牌，同一用户之前未使用的令牌全部作废，返回明文令牌
+func CreatePasswordReset(db *gorm.DB, adminId uint, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 清理过期或已使用的令牌，并作废未使用的令牌
+		if err := tx.Where("admin_id = ? or expires_at < ?", adminId, time.Now()).Delete(&PasswordReset{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&PasswordReset{
+			AdminId:   adminId,
+			TokenHash: hashResetToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	return token, err
+}
+
+// 使用找回密码令牌，成功时返回对应的用户id
+func UsePasswordReset(db *gorm.DB, token string) (uint, error) {
+	var reset PasswordReset
+	if err := db.Where("token_hash = ?", hashResetToken(token)).First(&reset).Error; err != nil {
+		return 0, ErrResetTokenInvalid
+	}
+	// 条件更新，防止令牌被并发重复使用
+	result := db.Model(&PasswordReset{}).
+		Where("id = ? and used_at is null and expires_at > ?", reset.ID, time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0, ErrResetTokenInvalid
+	}
+	return reset.AdminId, nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 两步验证恢复码，仅保存哈希值
+type RecoveryCode struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	AdminId   uint       `gorm:"type:int(11);not null;index" json:"admin_id"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `gorm:"column:create_time" json:"create_time"`
+}
+
+// TableName 设置表名
+func (RecoveryCode) TableName() string {
+	return "admin_recovery_code"
+}
+
+// InitRecoveryCodeTable 初始化恢复码表
+func InitRecoveryCodeTable(db *gorm.DB) {
+	if !db.HasTable(&RecoveryCode{}) {
+		db.CreateTable(&RecoveryCode{})
+	}
+}
+
+// 计算恢复码哈希，忽略大小写和分隔符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// 重新生成恢复码，旧的恢复码全部作废，返回明文供用户保存
+func GenerateRecoveryCodes(db *gorm.DB, adminId uint, count int) ([]string, error) {
+	codes := make([]string, 0, count)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("admin_id = ?", adminId).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			b := make([]byte, 5)
+			if _, err := rand.Read(b); err != nil {
+				return err
+			}
+			raw := hex.EncodeToString(b)
+			code := raw[:5] + "-" + raw[5:]
+			if err := tx.Create(&RecoveryCode{AdminId: adminId, CodeHash: hashRecoveryCode(code)}).Error; err != nil {
+				return err
+			}
+			codes = append(codes, code)
+		}
+		return nil
+	})
+	return codes, err
+}
+
+// 使用恢复码，每个恢复码仅能使用一次
+func UseRecoveryCode(db *gorm.DB, adminId uint, code string) bool {
+	result := db.Model(&RecoveryCode{}).
+		Where("admin_id = ? and code_hash = ? and used_at is null", adminId, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// 删除用户的所有恢复码
+func DeleteRecoveryCodes(db *gorm.DB, adminId uint) error {
+	return db.Where("admin_id = ?", adminId).Delete(&RecoveryCode{}).Error
+}
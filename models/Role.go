@@ -2,14 +2,33 @@ package models
 
 import (
 	"FlyCloud/pkg/Db"
+	"strconv"
+	"strings"
+
 	"github.com/jinzhu/gorm"
 )
 
+// 角色的数据范围
+const (
+	// 全部数据
+	DataScopeAll = "all"
+	// 本部门成员创建的数据
+	DataScopeDept = "dept"
+	// 本人创建的数据
+	DataScopeOwn = "own"
+	// 指定的客户及其数据
+	DataScopeCustom = "custom"
+)
+
 type Roles struct {
 	Db.Field
-	Name        string `gorm:"type:varchar(25);not null;" json:"name"`
-	Alias       string `gorm:"type:varchar(55);not null;unique;unique_index" json:"alias"`
-	Description string `gorm:"type:text" json:"description"`
+	// 所属租户，角色别名在租户内唯一
+	TenantId      uint   `gorm:"not null;default:1;unique_index:uix_roles_tenant_alias" json:"-"`
+	Name          string `gorm:"type:varchar(25);not null;" json:"name"`
+	Alias         string `gorm:"type:varchar(55);not null;unique_index:uix_roles_tenant_alias" json:"alias"`
+	Description   string `gorm:"type:text" json:"description"`
+	DataScope     string `gorm:"type:varchar(10);default:'all'" json:"data_scope"`
+	DataCustomers string `gorm:"type:text" json:"data_customers"`
 }
 
 // TableName 设置表名
@@ -28,13 +47,61 @@ func InitRolesTable(db *gorm.DB) {
 			Name:        "超级管理员",
 			Alias:       "super",
 			Description: "超级管理员",
+			DataScope:   DataScopeAll,
 		})
+	} else {
+		// 角色别名改为租户内唯一
+		if err := rebuildTenantTable(db, &Roles{}); err != nil {
+			panic(err)
+		}
+		// 补充新增的字段
+		db.AutoMigrate(&Roles{})
+		// 已有角色默认可查看全部数据
+		db.Model(&Roles{}).Where("data_scope is null or data_scope = ''").Update("data_scope", DataScopeAll)
 	}
 }
 
+// InitTenantRoles 为新租户创建超级管理员角色
+func InitTenantRoles(db *gorm.DB, tenantId uint) error {
+	return db.Create(&Roles{
+		TenantId:    tenantId,
+		Name:        "超级管理员",
+		Alias:       "super",
+		Description: "超级管理员",
+		DataScope:   DataScopeAll,
+	}).Error
+}
+
+// CustomerIds 获取自定义数据范围中的客户id
+func (role *Roles) CustomerIds() []uint {
+	var ids []uint
+	for _, v := range strings.Split(role.DataCustomers, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// IsDataScope 判断是否为有效的数据范围
+func IsDataScope(scope string) bool {
+	switch scope {
+	case DataScopeAll, DataScopeDept, DataScopeOwn, DataScopeCustom:
+		return true
+	}
+	return false
+}
+
 // 获取所有角色
 func GetAllRoles(db *gorm.DB) []Roles {
 	var roles []Roles
 	db.Find(&roles)
 	return roles
 }
+
+// 判断角色是否存在
+func IsExistRole(db *gorm.DB, alias string) bool {
+	var count int
+	db.Model(&Roles{}).Where("alias = ?", alias).Count(&count)
+	return count > 0
+}
@@ -8,6 +8,8 @@ import (
 // 服装款式 struct
 type Sample struct {
 	Db.Field
+	// 所属租户
+	TenantId   uint     `gorm:"not null;default:1;index" json:"-"`
 	Name       string   `gorm:"type:varchar(100);not null" json:"name"`
 	Year       int      `gorm:"type:int(4);" json:"year"`
 	CustomerId int      `gorm:"type:int(4);not null" json:"customer_id"`
@@ -20,6 +22,8 @@ type Sample struct {
 	ImgSrc     string   `gorm:"type:text;" json:"img_src"`
 	Status     int      `gorm:"type:int(2);" json:"status"`
 	IsStorage  int      `gorm:"type:int(2);" json:"is_storage"`
+	// 创建款式的管理员
+	CreatedBy uint `gorm:"column:created_by" json:"created_by"`
 }
 
 // TableName 设置表名
@@ -35,5 +39,8 @@ func InitSampleTable(db *gorm.DB) {
 		db.CreateTable(&Sample{})
 		// 创建索引
 		db.Model(&Sample{}).AddIndex("idx_sample_customer_id", "customer_id")
+	} else {
+		// 补充新增的字段
+		db.AutoMigrate(&Sample{})
 	}
 }
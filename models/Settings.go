@@ -1,11 +1,18 @@
 package models
 
-import "github.com/jinzhu/gorm"
+import (
+	"errors"
+	"strconv"
+	"strings"
 
-// 定义系统设置模型
+	"github.com/jinzhu/gorm"
+)
+
+// 定义系统设置模型，每个租户有各自的设置
 type Settings struct {
-	Key string `gorm:"column:key;type:varchar(255);primary_key" json:"key"`
-	Val string `gorm:"column:value;type:text" json:"value"`
+	TenantId uint   `gorm:"primary_key;auto_increment:false;default:1" json:"-"`
+	Key      string `gorm:"column:key;type:varchar(255);primary_key" json:"key"`
+	Val      string `gorm:"column:value;type:text" json:"value"`
 }
 
 // TableName 设置表名
@@ -13,70 +20,117 @@ func (Settings) TableName() string {
 	return "settings"
 }
 
+// 默认设置
+var defaultSettings = []Settings{
+	{Key: "site_name", Val: "FlyCloud"},
+	{Key: "site_description", Val: "FlyCloud is a file storage service."},
+	{Key: "site_keywords", Val: "FlyCloud,file storage,storage service"},
+	{Key: "site_url", Val: "http://flycloud.inzj.cn"},
+	{Key: "site_email", Val: "empty@inzj.cn"},
+	{Key: "site_icp", Val: ""},
+	{Key: "site_copyright", Val: "Copyright © 2019 inzj.cn"},
+	{Key: "site_tongji", Val: ""},
+	{Key: "site_status", Val: "1"},
+	{Key: "site_theme", Val: "default"},
+	{Key: "site_upload_file_size", Val: "15728640"},
+	{Key: "site_upload_ext", Val: "jpg,jpeg,png,gif,bmp,zip,rar,7z,doc,docx,xls,xlsx,ppt,pptx,pdf,txt,mp4,avi,mp3,wma,wmv,flv,swf,mkv,rm,rmvb,mov,asf,asx,vob,dat,ts,m4v,m3u8,3gp,3g2,m4a,aac,ape,ogg,wav,flac,ape,wma,mpc,mp+"},
+	{Key: "site_upload_image_size", Val: "2097152"},
+	{Key: "site_upload_image_ext", Val: "jpg,jpeg,png,gif,bmp"},
+	// 强制开启两步验证的角色，多个角色以逗号分隔
+	{Key: "mfa_force_roles", Val: ""},
+	// 注册方式，默认关闭注册
+	{Key: "register_mode", Val: RegisterModeClosed},
+	// 注册用户的默认角色
+	{Key: "register_default_role", Val: ""},
+	// 审计日志保留天数，0表示永久保留
+	{Key: "audit_retention_days", Val: DefaultAuditRetentionDays},
+}
+
 // InitSettingsTable 初始化settings
 func InitSettingsTable(Db *gorm.DB) {
 	// 判断表是否存在
 	if !Db.HasTable(&Settings{}) {
 		// 创建表
 		Db.CreateTable(&Settings{})
-		// 添加默认设置
-		Db.Create(&Settings{
-			Key: "site_name",
-			Val: "FlyCloud",
-		})
-		Db.Create(&Settings{
-			Key: "site_description",
-			Val: "FlyCloud is a file storage service.",
-		})
-		Db.Create(&Settings{
-			Key: "site_keywords",
-			Val: "FlyCloud,file storage,storage service",
-		})
-		Db.Create(&Settings{
-			Key: "site_url",
-			Val: "http://flycloud.inzj.cn",
-		})
-		Db.Create(&Settings{
-			Key: "site_email",
-			Val: "empty@inzj.cn",
-		})
-		Db.Create(&Settings{
-			Key: "site_icp",
-			Val: "",
-		})
-		Db.Create(&Settings{
-			Key: "site_copyright",
-			Val: "Copyright © 2019 inzj.cn",
-		})
-		Db.Create(&Settings{
-			Key: "site_tongji",
-			Val: "",
-		})
-		Db.Create(&Settings{
-			Key: "site_status",
-			Val: "1",
-		})
-		Db.Create(&Settings{
-			Key: "site_theme",
-			Val: "default",
-		})
-		Db.Create(&Settings{
-			Key: "site_upload_file_size",
-			Val: "15728640",
-		})
-		Db.Create(&Settings{
-			Key: "site_upload_ext",
-			Val: "jpg,jpeg,png,gif,bmp,zip,rar,7z,doc,docx,xls,xlsx,ppt,pptx,pdf,txt,mp4,avi,mp3,wma,wmv,flv,swf,mkv,rm,rmvb,mov,asf,asx,vob,dat,ts,m4v,m3u8,3gp,3g2,m4a,aac,ape,ogg,wav,flac,ape,wma,mpc,mp+",
-		})
-		Db.Create(&Settings{
-			Key: "site_upload_image_size",
-			Val: "2097152",
-		})
-		Db.Create(&Settings{
-			Key: "site_upload_image_ext",
-			Val: "jpg,jpeg,png,gif,bmp",
-		})
+	} else if err := rebuildTenantTable(Db, &Settings{}); err != nil {
+		// 主键改为租户和key
+		panic(err)
+	}
+	// 补充新增的设置项，已有的设置不会被覆盖
+	for _, tenantId := range GetTenantIds(Db) {
+		InitTenantSettings(Db, tenantId)
+	}
+}
+
+// InitTenantSettings 以默认值补充租户缺少的设置项
+func InitTenantSettings(Db *gorm.DB, tenantId uint) {
+	for _, v := range defaultSettings {
+		Db.Where(Settings{TenantId: tenantId, Key: v.Key}).Attrs(Settings{Val: v.Val}).FirstOrCreate(&Settings{})
+	}
+}
+
+// 注册方式
+const (
+	// 关闭注册
+	RegisterModeClosed = "closed"
+	// 开放注册，使用默认角色
+	RegisterModeOpen = "open"
+	// 凭邀请码注册
+	RegisterModeInvite = "invite"
+	// 注册后需要超级管理员审核并分配角色
+	RegisterModeApproval = "approval"
+)
+
+// 获取注册方式，未设置或设置错误时视为关闭注册
+func GetRegisterMode(DB *gorm.DB) string {
+	settings, err := GetSettingsByKey(DB, "register_mode")
+	if err != nil {
+		return RegisterModeClosed
+	}
+	switch settings.Val {
+	case RegisterModeOpen, RegisterModeInvite, RegisterModeApproval:
+		return settings.Val
 	}
+	return RegisterModeClosed
+}
+
+// 获取注册用户的默认角色，角色不存在时返回错误
+func GetRegisterDefaultRole(DB *gorm.DB) (string, error) {
+	settings, err := GetSettingsByKey(DB, "register_default_role")
+	if err != nil || settings.Val == "" {
+		return "", errors.New("未配置注册用户的默认角色")
+	}
+	if !IsExistRole(DB, settings.Val) {
+		return "", errors.New("注册用户的默认角色不存在")
+	}
+	return settings.Val, nil
+}
+
+// 校验设置值是否合法
+func (settings *Settings) Validate(DB *gorm.DB) error {
+	switch settings.Key {
+	case "register_mode":
+		switch settings.Val {
+		case RegisterModeClosed, RegisterModeOpen, RegisterModeInvite, RegisterModeApproval:
+			return nil
+		}
+		return errors.New("注册方式只能是 closed、open、invite 或 approval")
+	case "register_default_role":
+		if settings.Val != "" && !IsExistRole(DB, settings.Val) {
+			return errors.New("角色不存在")
+		}
+	case "audit_retention_days":
+		if days, err := strconv.Atoi(settings.Val); err != nil || days < 0 {
+			return errors.New("审计日志保留天数必须是非负整数")
+		}
+	case "mfa_force_roles":
+		for _, role := range strings.Split(settings.Val, ",") {
+			if role = strings.TrimSpace(role); role != "" && !IsExistRole(DB, role) {
+				return errors.New("角色 " + role + " 不存在")
+			}
+		}
+	}
+	return nil
 }
 
 // 过滤空值，并生成查询条件
@@ -110,6 +164,21 @@ func GetSettingsByKeys(DB *gorm.DB, keys []string) (map[string]string, error) {
 	return settings, nil
 }
 
+// 根据key获取以逗号分隔的设置值列表，设置不存在时返回空列表
+func GetSettingsList(DB *gorm.DB, key string) []string {
+	var list []string
+	settings, err := GetSettingsByKey(DB, key)
+	if err != nil {
+		return list
+	}
+	for _, v := range strings.Split(settings.Val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 // 根据key获取设置
 func (settings *Settings) GetByKey(DB *gorm.DB) error {
 	return DB.Where(settings.Filter()).First(settings).Error
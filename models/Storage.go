@@ -9,6 +9,8 @@ import (
 // define storage struct
 type Storage struct {
 	Db.Field
+	// 所属租户
+	TenantId uint   `gorm:"not null;default:1;index" json:"-"`
 	Name     string `gorm:"column:name;type:varchar(255)" json:"name"`
 	Location string `gorm:"column:location;type:varchar(255)" json:"location"`
 	Type     string `gorm:"column:type;type:varchar(255)" json:"type"`
@@ -27,7 +29,9 @@ func InitStorageTable(Db *gorm.DB) {
 	if !Db.HasTable(&Storage{}) {
 		// 创建表
 		Db.CreateTable(&Storage{})
-
+	} else {
+		// 补充新增的字段
+		Db.AutoMigrate(&Storage{})
 	}
 }
 
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"FlyCloud/pkg/Db"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 默认租户，升级前的数据都归属于默认租户，默认租户的超级管理员为平台管理员
+const DefaultTenantId uint = 1
+
+// 租户状态
+const (
+	// 正常
+	TenantStatusActive = 1
+	// 已停用，停用后租户内的用户无法登录
+	TenantStatusSuspended = 2
+)
+
+// Tenant 租户
+type Tenant struct {
+	Db.Field
+	Code   string `gorm:"type:varchar(50);not null;unique_index" json:"code"`
+	Name   string `gorm:"type:varchar(100);not null" json:"name"`
+	Status int    `gorm:"type:int(1);default:1" json:"status"`
+	Remark string `gorm:"type:varchar(255)" json:"remark"`
+}
+
+// TableName 设置表名
+func (Tenant) TableName() string {
+	return "tenant"
+}
+
+// InitTenantTable 初始化租户表，并创建默认租户
+func InitTenantTable(db *gorm.DB) {
+	if !db.HasTable(&Tenant{}) {
+		db.CreateTable(&Tenant{})
+	}
+	db.Where(Tenant{Field: Db.Field{ID: DefaultTenantId}}).
+		Attrs(Tenant{Code: "default", Name: "默认租户", Status: TenantStatusActive}).
+		FirstOrCreate(&Tenant{})
+}
+
+// GetTenantByCode 根据编码获取租户
+func GetTenantByCode(db *gorm.DB, code string) (*Tenant, error) {
+	var tenant Tenant
+	if err := db.Where("code = ?", code).First(&tenant).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// GetTenantIds 获取所有租户的id
+func GetTenantIds(db *gorm.DB) []uint {
+	var ids []uint
+	if !db.HasTable(&Tenant{}) {
+		return []uint{DefaultTenantId}
+	}
+	db.Model(&Tenant{}).Pluck("id", &ids)
+	return ids
+}
+
+// 表中缺少租户字段时重建表。唯一索引或主键需要加上租户字段时无法直接修改，
+// 按新的结构创建临时表，复制数据后替换原表，已有数据归属默认租户
+func rebuildTenantTable(db *gorm.DB, model interface{}) error {
+	scope := db.NewScope(model)
+	table := scope.TableName()
+	if db.Dialect().HasColumn(table, "tenant_id") {
+		return nil
+	}
+	tmp := table + "_tmp"
+	// 只复制原表中已有的字段
+	var columns []string
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.IsNormal && !field.IsIgnored && field.DBName != "tenant_id" && db.Dialect().HasColumn(table, field.DBName) {
+			columns = append(columns, scope.Quote(field.DBName))
+		}
+	}
+	tx := db.Begin()
+	if err := tx.Table(tmp).CreateTable(model).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	list := strings.Join(columns, ", ")
+	steps := []string{
+		"INSERT INTO " + scope.Quote(tmp) + " (tenant_id, " + list + ") SELECT 1, " + list + " FROM " + scope.Quote(table),
+		"DROP TABLE " + scope.Quote(table),
+		"ALTER TABLE " + scope.Quote(tmp) + " RENAME TO " + scope.Quote(table),
+	}
+	for _, sql := range steps {
+		if err := tx.Exec(sql).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 刷新令牌，仅保存令牌的哈希值
+type RefreshToken struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	AdminId   uint       `gorm:"type:int(11);not null;index" json:"admin_id"`
+	TokenHash string     `gorm:"type:varchar(64);not null;unique_index" json:"-"`
+	Family    string     `gorm:"type:varchar(64);not null;index" json:"family"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	CreatedAt time.Time  `gorm:"column:create_time" json:"create_time"`
+}
+
+// TableName 设置表名
+func (RefreshToken) TableName() string {
+	return "admin_refresh_token"
+}
+
+// 已吊销的访问令牌，按jti记录直到令牌过期
+type TokenDenylist struct {
+	Jti       string    `gorm:"type:varchar(64);primary_key" json:"jti"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index" json:"expires_at"`
+}
+
+// TableName 设置表名
+func (TokenDenylist) TableName() string {
+	return "token_denylist"
+}
+
+// 用户级别的令牌吊销时间，早于该时间签发的令牌全部失效
+type TokenRevocation struct {
+	AdminId uint `gorm:"type:int(11);primary_key;auto_increment:false" json:"admin_id"`
+	// 吊销时间，单位毫秒，避免同一秒内先签发的令牌仍然有效
+	RevokedAt int64 `gorm:"type:bigint" json:"revoked_at"`
+}
+
+// TableName 设置表名
+func (TokenRevocation) TableName() string {
+	return "token_revocation"
+}
+
+// InitTokenTables 初始化令牌相关的表
+func InitTokenTables(db *gorm.DB) {
+	if !db.HasTable(&RefreshToken{}) {
+		db.CreateTable(&RefreshToken{})
+	}
+	if !db.HasTable(&TokenDenylist{}) {
+		db.CreateTable(&TokenDenylist{})
+	}
+	if !db.HasTable(&TokenRevocation{}) {
+		db.CreateTable(&TokenRevocation{})
+	} else {
+		// 旧的吊销时间单位为秒，转换为毫秒
+		db.Model(&TokenRevocation{}).Where("revoked_at > 0 and revoked_at < ?", int64(1e11)).Update("revoked_at", gorm.Expr("revoked_at * 1000"))
+	}
+}
@@ -0,0 +1,114 @@
+package account
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/serves/cache"
+	acs "FlyCloud/serves/casbin"
+	"FlyCloud/serves/database"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// 缓存的账号状态最长有效期，防止直接修改数据库后长期不生效
+const stateTTL = time.Minute
+
+var (
+	ErrNotFound = errors.New("账号不存在")
+	ErrDisabled = errors.New("账号已被禁用")
+	ErrTenant   = errors.New("所属租户已停用")
+)
+
+// State 每次请求都需要的账号状态，缓存以避免频繁查询数据库
+type State struct {
+	Status   int    `json:"s"`
+	Role     string `json:"r"`
+	TenantId uint   `json:"d"`
+	LoadedAt int64  `json:"t"`
+}
+
+// 缓存的租户状态
+type tenantState struct {
+	Status   int   `json:"s"`
+	LoadedAt int64 `json:"t"`
+}
+
+// Enabled 账号是否已启用
+func (s *State) Enabled() bool {
+	return s.Status == models.AdminStatusEnabled
+}
+
+// Get 获取账号状态，缓存未命中或过期时从数据库加载
+func Get(adminId uint) (*State, error) {
+	var state State
+	if entry, err := cache.GetCache(key(adminId)); err == nil && json.Unmarshal(entry, &state) == nil {
+		if time.Since(time.Unix(state.LoadedAt, 0)) < stateTTL {
+			return &state, nil
+		}
+	}
+	var admin models.Admin
+	if err := database.GetDB().Select("id, status, roles_name, tenant_id").First(&admin, "id = ?", adminId).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	state = State{Status: admin.Status, Role: admin.RolesName, TenantId: admin.TenantId, LoadedAt: time.Now().Unix()}
+	if data, err := json.Marshal(state); err == nil {
+		_ = cache.SetCache(key(adminId), data)
+	}
+	return &state, nil
+}
+
+// Check 获取账号状态，并要求账号已启用
+func Check(adminId uint) (*State, error) {
+	state, err := Get(adminId)
+	if err != nil {
+		return nil, err
+	}
+	if !state.Enabled() {
+		return nil, ErrDisabled
+	}
+	if !TenantActive(state.TenantId) {
+		return nil, ErrTenant
+	}
+	return state, nil
+}
+
+// TenantActive 租户是否正常，租户状态同样缓存
+func TenantActive(tenantId uint) bool {
+	var state tenantState
+	if entry, err := cache.GetCache(tenantKey(tenantId)); err == nil && json.Unmarshal(entry, &state) == nil {
+		if time.Since(time.Unix(state.LoadedAt, 0)) < stateTTL {
+			return state.Status == models.TenantStatusActive
+		}
+	}
+	var tenant models.Tenant
+	if err := database.GetDB().Select("id, status").First(&tenant, "id = ?", tenantId).Error; err != nil {
+		return false
+	}
+	state = tenantState{Status: tenant.Status, LoadedAt: time.Now().Unix()}
+	if data, err := json.Marshal(state); err == nil {
+		_ = cache.SetCache(tenantKey(tenantId), data)
+	}
+	return state.Status == models.TenantStatusActive
+}
+
+// Invalidate 账号的状态或角色变化后，清除缓存的账号状态和权限判断结果
+func Invalidate(adminId uint) {
+	_ = cache.DeleteCache(key(adminId))
+	acs.InvalidateUser(adminId)
+}
+
+// InvalidateTenant 租户状态变化后清除缓存的租户状态
+func InvalidateTenant(tenantId uint) {
+	_ = cache.DeleteCache(tenantKey(tenantId))
+}
+
+// 账号状态缓存key
+func key(adminId uint) string {
+	return "account:" + strconv.FormatUint(uint64(adminId), 10)
+}
+
+// 租户状态缓存key
+func tenantKey(tenantId uint) string {
+	return "account:tenant:" + strconv.FormatUint(uint64(tenantId), 10)
+}
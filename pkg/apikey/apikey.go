@@ -0,0 +1,165 @@
+package apikey
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/system"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/util"
+)
+
+const (
+	// 密钥前缀，便于在日志和代码仓库中识别泄露的密钥
+	keyPrefix = "fck_"
+	// 缓存的密钥最长有效期
+	cacheTTL = time.Minute
+	// 最近使用时间的更新间隔，避免每次请求都写数据库
+	touchInterval = time.Minute
+)
+
+var (
+	ErrInvalid = errors.New("API密钥无效")
+	ErrExpired = errors.New("API密钥已过期")
+)
+
+// 权限范围中的路径只允许这些字符，避免构造出非法的正则表达式
+var scopePath = regexp.MustCompile(`^/[A-Za-z0-9_\-/:.*]*$`)
+
+// 请求方法
+var methods = []string{"*", "GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// 缓存的密钥信息
+type entry struct {
+	Key      models.ApiKey `json:"k"`
+	LoadedAt int64         `json:"t"`
+}
+
+// Generate 生成新的API密钥，返回明文密钥、前缀和哈希值
+func Generate() (plain string, prefix string, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	raw := hex.EncodeToString(b)
+	plain = keyPrefix + raw
+	return plain, keyPrefix + raw[:8], Hash(plain), nil
+}
+
+// Hash 计算密钥的哈希值
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeScopes 校验并规范化权限范围，格式为“请求方法 路径”，省略请求方法表示任意方法
+func NormalizeScopes(scopes []string) (string, error) {
+	var list []string
+	for _, scope := range scopes {
+		fields := strings.Fields(scope)
+		if len(fields) == 0 {
+			continue
+		}
+		method, path := "*", fields[0]
+		if len(fields) == 2 {
+			method, path = strings.ToUpper(fields[0]), fields[1]
+		} else if len(fields) > 2 {
+			return "", errors.New("权限范围格式错误：" + scope)
+		}
+		if path == "*" {
+			path = "/*"
+		}
+		if !system.InArray(methods, method) {
+			return "", errors.New("不支持的请求方法：" + method)
+		}
+		if !scopePath.MatchString(path) {
+			return "", errors.New("权限范围路径错误：" + path)
+		}
+		list = append(list, method+" "+path)
+	}
+	if len(list) == 0 {
+		return "", errors.New("请至少指定一个权限范围")
+	}
+	return strings.Join(list, ","), nil
+}
+
+// Authenticate 验证密钥，成功时返回密钥信息，并记录最近使用时间
+func Authenticate(plain string, ip string) (*models.ApiKey, error) {
+	if !strings.HasPrefix(plain, keyPrefix) {
+		return nil, ErrInvalid
+	}
+	hash := Hash(plain)
+	key, err := load(hash)
+	if err != nil {
+		return nil, err
+	}
+	if key.Expired() {
+		return nil, ErrExpired
+	}
+	// 定期更新最近使用时间
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+		database.GetDB().Model(&models.ApiKey{}).Where("id = ?", key.ID).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		})
+		key.LastUsedAt = &now
+		store(hash, key)
+	}
+	return key, nil
+}
+
+// Allowed 判断密钥的权限范围是否允许访问该资源
+func Allowed(key *models.ApiKey, method string, path string) bool {
+	for _, scope := range key.ScopeList() {
+		fields := strings.Fields(scope)
+		if len(fields) != 2 {
+			continue
+		}
+		if (fields[0] == "*" || fields[0] == method) && util.KeyMatch2(path, fields[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Invalidate 删除密钥后清除缓存
+func Invalidate(hash string) {
+	_ = cache.DeleteCache(cacheKey(hash))
+}
+
+// 从缓存或数据库加载密钥
+func load(hash string) (*models.ApiKey, error) {
+	var e entry
+	if data, err := cache.GetCache(cacheKey(hash)); err == nil && json.Unmarshal(data, &e) == nil {
+		if time.Since(time.Unix(e.LoadedAt, 0)) < cacheTTL {
+			return &e.Key, nil
+		}
+	}
+	var key models.ApiKey
+	if err := database.GetDB().Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		return nil, ErrInvalid
+	}
+	store(hash, &key)
+	return &key, nil
+}
+
+// 写入缓存
+func store(hash string, key *models.ApiKey) {
+	if data, err := json.Marshal(entry{Key: *key, LoadedAt: time.Now().Unix()}); err == nil {
+		_ = cache.SetCache(cacheKey(hash), data)
+	}
+}
+
+// 密钥缓存key
+func cacheKey(hash string) string {
+	return "apikey:" + hash
+}
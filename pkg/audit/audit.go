@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// 保存操作人的键
+const actorKey = "audit:actor"
+
+// 清理过期审计日志的间隔
+const cleanupInterval = time.Hour
+
+// Actor 操作人和请求信息
+type Actor struct {
+	AdminId  uint
+	TenantId uint
+	Ip       string
+	Method   string
+	Route    string
+}
+
+// Change 字段修改前后的值
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// FromContext 获取当前请求的操作人，未登录的请求返回 nil
+func FromContext(ctx *gin.Context) *Actor {
+	v, ok := ctx.Get("claim")
+	if !ok {
+		return nil
+	}
+	claim, ok := v.(*jwt.CustomClaims)
+	if !ok {
+		return nil
+	}
+	tenantId := claim.TenantId
+	if tenantId == 0 {
+		tenantId = models.DefaultTenantId
+	}
+	return &Actor{
+		AdminId:  claim.UserId,
+		TenantId: tenantId,
+		Ip:       ctx.ClientIP(),
+		Method:   ctx.Request.Method,
+		Route:    ctx.FullPath(),
+	}
+}
+
+// WithActor 返回记录审计日志的数据库连接，通过该连接新增、修改和删除数据时自动记录修改前后的值
+func WithActor(db *gorm.DB, actor *Actor) *gorm.DB {
+	if actor == nil {
+		return db
+	}
+	return db.Set(actorKey, actor)
+}
+
+// DB 返回记录当前请求操作人的数据库连接
+func DB(ctx *gin.Context, db *gorm.DB) *gorm.DB {
+	return WithActor(db, FromContext(ctx))
+}
+
+// Record 记录不经过数据库回调的修改，例如权限策略的变更
+func Record(ctx *gin.Context, db *gorm.DB, action, entity, entityId string, changes map[string]Change) error {
+	actor := FromContext(ctx)
+	if actor == nil || len(changes) == 0 {
+		return nil
+	}
+	return write(db, actor, action, entity, entityId, changes)
+}
+
+// 写入审计日志，日志归属操作人所在的租户
+func write(db *gorm.DB, actor *Actor, action, entity, entityId string, changes map[string]Change) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	log := models.AuditLog{
+		AdminId:  actor.AdminId,
+		Ip:       actor.Ip,
+		Method:   actor.Method,
+		Route:    actor.Route,
+		Action:   action,
+		Entity:   entity,
+		EntityId: entityId,
+		Changes:  string(data),
+	}
+	return database.WithTenant(db, actor.TenantId).Create(&log).Error
+}
+
+// StartCleanup 定期按各租户设置的保留天数删除过期的审计日志，返回停止函数
+func StartCleanup(db *gorm.DB) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+		for {
+			cleanup(db)
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// 删除所有租户过期的审计日志
+func cleanup(db *gorm.DB) {
+	for _, tenantId := range models.GetTenantIds(db) {
+		tdb := database.WithTenant(db, tenantId)
+		days := retentionDays(tdb)
+		if days <= 0 {
+			continue
+		}
+		count, err := models.CleanAuditLog(tdb, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			logging.Error("清理审计日志失败：", err)
+			continue
+		}
+		if count > 0 {
+			logging.Info(fmt.Sprintf("租户%d清理过期审计日志%d条", tenantId, count))
+		}
+	}
+}
+
+// 获取审计日志保留天数，未设置或设置错误时使用默认值
+func retentionDays(db *gorm.DB) int {
+	value := models.DefaultAuditRetentionDays
+	if settings, err := models.GetSettingsByKey(db, "audit_retention_days"); err == nil {
+		value = settings.Val
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil {
+		days, _ = strconv.Atoi(models.DefaultAuditRetentionDays)
+	}
+	return days
+}
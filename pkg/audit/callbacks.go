@@ -0,0 +1,234 @@
+package audit
+
+import (
+	"FlyCloud/models"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 保存修改前数据的键
+const beforeKey = "audit:before"
+
+// 敏感字段在审计日志中的显示值
+const masked = "******"
+
+// 不记录的字段，所属租户和时间戳
+var skipColumns = map[string]bool{
+	"tenant_id":   true,
+	"create_time": true,
+	"update_time": true,
+	"delete_time": true,
+}
+
+// Register 注册审计日志的回调，需要在租户回调之后注册，修改前的数据才会限定在当前租户
+func Register(db *gorm.DB) {
+	db.Callback().Create().After("gorm:create").Register("audit:create", auditCreate)
+	db.Callback().Update().Before("gorm:update").Register("audit:before_update", snapshot)
+	db.Callback().Update().After("gorm:update").Register("audit:update", auditUpdate)
+	db.Callback().Delete().Before("gorm:delete").Register("audit:before_delete", snapshot)
+	db.Callback().Delete().After("gorm:delete").Register("audit:delete", auditDelete)
+}
+
+// 获取操作人，审计日志本身和没有操作人的操作不记录
+func scopeActor(scope *gorm.Scope) (*Actor, bool) {
+	value, ok := scope.Get(actorKey)
+	if !ok || scope.TableName() == (models.AuditLog{}).TableName() {
+		return nil, false
+	}
+	actor, ok := value.(*Actor)
+	return actor, ok && actor != nil
+}
+
+// 记录新增的数据
+func auditCreate(scope *gorm.Scope) {
+	actor, ok := scopeActor(scope)
+	if !ok || scope.HasError() {
+		return
+	}
+	row := map[string]interface{}{}
+	for _, field := range scope.Fields() {
+		if field.IsNormal && !field.IsIgnored && !field.IsBlank {
+			row[field.DBName] = field.Field.Interface()
+		}
+	}
+	changes := diff(scope, nil, row)
+	if len(changes) == 0 {
+		return
+	}
+	if err := write(scope.NewDB(), actor, models.AuditActionCreate, scope.TableName(), entityId(scope, row), changes); err != nil {
+		scope.Err(err)
+	}
+}
+
+// 修改和删除前查询将被修改的数据
+func snapshot(scope *gorm.Scope) {
+	if _, ok := scopeActor(scope); !ok || scope.HasError() {
+		return
+	}
+	// 使用与本次操作相同的条件查询，查询完成后恢复参数，避免影响本次操作的语句
+	vars := scope.SQLVars
+	scope.SQLVars = nil
+	query := "SELECT * FROM " + scope.QuotedTableName() + " " + scope.CombinedConditionSql()
+	args := scope.SQLVars
+	scope.SQLVars = vars
+	// 与 scope.Raw 相同，替换部分方言使用的占位符
+	query = strings.Replace(query, "$$$", "?", -1)
+	rows, err := queryRows(scope.SQLDB().Query(query, args...))
+	if err != nil {
+		scope.Err(err)
+		return
+	}
+	scope.InstanceSet(beforeKey, rows)
+}
+
+// 记录修改前后不同的字段
+func auditUpdate(scope *gorm.Scope) {
+	actor, ok := scopeActor(scope)
+	if !ok || scope.HasError() {
+		return
+	}
+	value, _ := scope.InstanceGet(beforeKey)
+	before, _ := value.([]map[string]interface{})
+	for _, old := range before {
+		// 按主键重新查询修改后的数据，修改后的数据可能不再满足原来的条件
+		db := scope.NewDB().Unscoped().Table(scope.TableName())
+		for _, field := range scope.PrimaryFields() {
+			db = db.Where(scope.Quote(field.DBName)+" = ?", old[field.DBName])
+		}
+		after, err := queryRows(db.Rows())
+		if err != nil {
+			scope.Err(err)
+			return
+		}
+		if len(after) == 0 {
+			continue
+		}
+		changes := diff(scope, old, after[0])
+		if len(changes) == 0 {
+			continue
+		}
+		if err := write(scope.NewDB(), actor, models.AuditActionUpdate, scope.TableName(), entityId(scope, old), changes); err != nil {
+			scope.Err(err)
+			return
+		}
+	}
+}
+
+// 记录删除的数据
+func auditDelete(scope *gorm.Scope) {
+	actor, ok := scopeActor(scope)
+	if !ok || scope.HasError() {
+		return
+	}
+	value, _ := scope.InstanceGet(beforeKey)
+	before, _ := value.([]map[string]interface{})
+	for _, old := range before {
+		if err := write(scope.NewDB(), actor, models.AuditActionDelete, scope.TableName(), entityId(scope, old), diff(scope, old, nil)); err != nil {
+			scope.Err(err)
+			return
+		}
+	}
+}
+
+// 比较修改前后的数据，新增时 before 为空，删除时 after 为空
+func diff(scope *gorm.Scope, before, after map[string]interface{}) map[string]Change {
+	hidden := hiddenColumns(scope)
+	changes := map[string]Change{}
+	for _, column := range columns(before, after) {
+		if skipColumns[column] {
+			continue
+		}
+		oldValue, hasOld := before[column]
+		newValue, hasNew := after[column]
+		if hasOld && hasNew && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		// 敏感字段只记录发生了修改
+		if hidden[column] {
+			oldValue, newValue = maskValue(oldValue, hasOld), maskValue(newValue, hasNew)
+		}
+		changes[column] = Change{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+// 合并修改前后数据的字段
+func columns(before, after map[string]interface{}) []string {
+	var list []string
+	for column := range before {
+		list = append(list, column)
+	}
+	for column := range after {
+		if _, ok := before[column]; !ok {
+			list = append(list, column)
+		}
+	}
+	return list
+}
+
+// 隐藏敏感字段的值
+func maskValue(value interface{}, ok bool) interface{} {
+	if !ok {
+		return nil
+	}
+	return masked
+}
+
+// 不对外输出的字段和密码视为敏感字段
+func hiddenColumns(scope *gorm.Scope) map[string]bool {
+	hidden := map[string]bool{}
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.Tag.Get("json") == "-" || field.DBName == "password" {
+			hidden[field.DBName] = true
+		}
+	}
+	return hidden
+}
+
+// 由主键生成数据的标识，多个主键以冒号连接，不包含租户
+func entityId(scope *gorm.Scope, row map[string]interface{}) string {
+	var ids []string
+	for _, field := range scope.GetModelStruct().PrimaryFields {
+		if field.DBName != "tenant_id" {
+			ids = append(ids, fmt.Sprint(row[field.DBName]))
+		}
+	}
+	return strings.Join(ids, ":")
+}
+
+// 读取查询结果，每行转换为字段名到值的映射
+func queryRows(rows *sql.Rows, err error) ([]map[string]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var list []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(names))
+		dest := make([]interface{}, len(names))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(names))
+		for i, column := range names {
+			// 文本字段可能以字节返回
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = values[i]
+		}
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,66 @@
+package datascope
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// 保存数据范围的键，值为解析当前用户数据范围的函数
+const dbKey = "datascope:resolve"
+
+// 受数据范围限制的表及其过滤条件
+var rules = map[string]func(s *Scope, table string) (string, []interface{}){
+	"customer": (*Scope).customerWhere,
+	"sample":   (*Scope).sampleWhere,
+	"storage":  (*Scope).storageWhere,
+}
+
+// WithContext 返回按当前请求用户的数据范围过滤的数据库连接。
+// 查询、更新和删除受数据范围限制的表时自动加上过滤条件，数据范围在第一次用到时解析。
+// 未登录的请求不限制
+func WithContext(ctx *gin.Context, db *gorm.DB) *gorm.DB {
+	if _, ok := ctx.Get("claim"); !ok {
+		return db
+	}
+	return db.Set(dbKey, func() (*Scope, error) {
+		return FromContext(ctx, db)
+	})
+}
+
+// Register 注册数据范围相关的回调
+func Register(db *gorm.DB) {
+	db.Callback().Query().Before("gorm:query").Register("datascope:query", condition)
+	db.Callback().RowQuery().Before("gorm:row_query").Register("datascope:row_query", condition)
+	db.Callback().Update().Before("gorm:update").Register("datascope:update", condition)
+	db.Callback().Delete().Before("gorm:delete").Register("datascope:delete", condition)
+}
+
+// 加上数据范围的过滤条件
+func condition(scope *gorm.Scope) {
+	value, ok := scope.Get(dbKey)
+	if !ok {
+		return
+	}
+	rule, ok := rules[scope.TableName()]
+	if !ok {
+		return
+	}
+	resolve, ok := value.(func() (*Scope, error))
+	if !ok {
+		return
+	}
+	s, err := resolve()
+	if err != nil {
+		// 查询回调不检查错误，加上不成立的条件，避免返回范围外的数据
+		scope.Search.Where("1 <> 1")
+		scope.Err(fmt.Errorf("获取数据范围失败：%w", err))
+		return
+	}
+	if s.All {
+		return
+	}
+	where, args := rule(s, scope.QuotedTableName())
+	scope.Search.Where(where, args...)
+}
@@ -0,0 +1,120 @@
+package datascope
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/jwt"
+	acs "FlyCloud/serves/casbin"
+	"FlyCloud/serves/database"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// 数据范围在请求上下文中的key，同一请求内只解析一次
+const contextKey = "datascope"
+
+// Scope 用户的数据范围，由主角色、附加角色和继承的角色合并而来，取并集
+type Scope struct {
+	// 可查看全部数据
+	All bool
+	// 当前用户id
+	UserId uint
+	// 可查看本部门成员创建的数据时为用户所在部门
+	Department string
+	// 可查看指定的客户
+	Customers []uint
+}
+
+// FromContext 获取当前请求用户的数据范围
+func FromContext(ctx *gin.Context, db *gorm.DB) (*Scope, error) {
+	if v, ok := ctx.Get(contextKey); ok {
+		return v.(*Scope), nil
+	}
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	scope, err := Resolve(database.WithTenant(db, claim.TenantId), claim.UserId, claim.UserRole)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Set(contextKey, scope)
+	return scope, nil
+}
+
+// Resolve 根据用户的角色计算数据范围，db 需限定为用户所属的租户
+func Resolve(db *gorm.DB, userId uint, role string) (*Scope, error) {
+	scope := &Scope{UserId: userId}
+	tenantId, _ := database.TenantOf(db)
+	dom := acs.Domain(tenantId)
+	if acs.IsSuper(userId, role, dom) {
+		scope.All = true
+		return scope, nil
+	}
+	// 主角色及其继承的角色，加上附加角色及其继承的角色
+	aliases := []string{}
+	if role != "" {
+		aliases = append(aliases, role)
+		aliases = append(aliases, acs.ImplicitRoles(role, dom)...)
+	}
+	aliases = append(aliases, acs.ImplicitRoles(acs.UserSubject(userId), dom)...)
+	if len(aliases) == 0 {
+		return scope, nil
+	}
+	var roles []models.Roles
+	if err := db.Where("alias in (?)", aliases).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	dept := false
+	for _, r := range roles {
+		switch r.DataScope {
+		case models.DataScopeAll:
+			scope.All = true
+			return scope, nil
+		case models.DataScopeDept:
+			dept = true
+		case models.DataScopeCustom:
+			scope.Customers = append(scope.Customers, r.CustomerIds()...)
+		}
+	}
+	if dept {
+		var admin models.Admin
+		if err := db.Select("department").Where("id = ?", userId).First(&admin).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+			return nil, err
+		}
+		// 没有部门的用户只能查看本人的数据
+		scope.Department = admin.Department
+	}
+	return scope, nil
+}
+
+// 客户表的过滤条件，本人创建的客户始终可见。table 为带引号的表名，子查询中为空
+func (s *Scope) customerWhere(table string) (string, []interface{}) {
+	prefix := ""
+	if table != "" {
+		prefix = table + "."
+	}
+	conds := []string{prefix + "created_by = ?"}
+	args := []interface{}{s.UserId}
+	if s.Department != "" {
+		conds = append(conds, prefix+"created_by in (select id from admin where department = ?)")
+		args = append(args, s.Department)
+	}
+	if len(s.Customers) > 0 {
+		conds = append(conds, prefix+"id in (?)")
+		args = append(args, s.Customers)
+	}
+	return "(" + strings.Join(conds, " or ") + ")", args
+}
+
+// 款式表的过滤条件，只能查看可见客户的款式
+func (s *Scope) sampleWhere(table string) (string, []interface{}) {
+	where, args := s.customerWhere("")
+	return table + ".customer_id in (select id from customer where delete_time is null and " + where + ")", args
+}
+
+// 文件表的过滤条件，文件不属于客户，自定义范围只能查看本人上传的文件
+func (s *Scope) storageWhere(table string) (string, []interface{}) {
+	if s.Department != "" {
+		return "(" + table + ".user_id = ? or " + table + ".user_id in (select id from admin where department = ?))", []interface{}{s.UserId, s.Department}
+	}
+	return table + ".user_id = ?", []interface{}{s.UserId}
+}
@@ -3,6 +3,9 @@ package jwt
 import (
 	"FlyCloud/models"
 	"FlyCloud/serves/config"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"time"
 )
@@ -16,7 +19,7 @@ type Jwt struct {
 // 初始化JWT对象
 func NewJwt() *Jwt {
 	return &Jwt{
-		config: config.Config.JwtConfig,
+		config: config.GetConfig().JwtConfig,
 	}
 }
 
@@ -26,27 +29,70 @@ type CustomClaims struct {
 	UserId uint `json:"userId"`
 	// 这里如果不设置jwt的过期时间，那么签名就会失败
 	UserRole string `json:"userRole"`
+	// 所属租户
+	TenantId uint `json:"tenantId,omitempty"`
+	// 令牌类型，空值为访问令牌，mfa为等待两步验证的临时令牌
+	TokenType string `json:"tokenType,omitempty"`
+	// 是否需要先绑定两步验证，仅用于mfa令牌
+	MfaSetup bool `json:"mfaSetup,omitempty"`
+	// 签发时间，单位毫秒，用于判断令牌是否已被吊销
+	IssuedAtMs int64 `json:"iatMs,omitempty"`
 	// StandardClaims包含了jwt的一些标准信息，如生成时间，签名，过期时间等
 	jwt.StandardClaims
 }
 
+// 令牌类型：等待两步验证
+const TokenTypeMfa = "mfa"
+
+// 两步验证临时令牌有效期
+const mfaTokenExpires = 5 * time.Minute
+
 // 创建一个jwt的方法
 func (j *Jwt) CreateToken(obj *models.Admin) (string, error) {
+	return j.sign(obj, "", false, time.Minute*time.Duration(j.config.ExpiresAt))
+}
 
+// CreateMfaToken 密码验证通过后签发的临时令牌，只能用于完成两步验证
+func (j *Jwt) CreateMfaToken(obj *models.Admin, setup bool) (string, error) {
+	return j.sign(obj, TokenTypeMfa, setup, mfaTokenExpires)
+}
+
+// 签发指定类型和有效期的令牌
+func (j *Jwt) sign(obj *models.Admin, tokenType string, setup bool, expires time.Duration) (string, error) {
+	// 获取当前签名密钥
+	key, err := keys.active()
+	if err != nil {
+		return "", err
+	}
+	// 生成令牌唯一标识，用于吊销
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
 	// 创建一个token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &CustomClaims{
-		UserId:   obj.ID,
-		UserRole: obj.RolesName,
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Method, &CustomClaims{
+		UserId:     obj.ID,
+		UserRole:   obj.RolesName,
+		TenantId:   obj.TenantId,
+		TokenType:  tokenType,
+		MfaSetup:   setup,
+		IssuedAtMs: now.UnixMilli(),
 		StandardClaims: jwt.StandardClaims{
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(time.Minute * time.Duration(j.config.ExpiresAt)).Unix(),
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(expires).Unix(),
 			Audience:  j.config.Audience,
 			Issuer:    j.config.Issuer,
 			Subject:   j.config.Subject,
 		},
 	})
+	// 写入密钥ID，便于验证方按kid选择公钥
+	if key.Kid != "" {
+		token.Header["kid"] = key.Kid
+	}
 	// 生成一个token
-	return token.SignedString([]byte(j.config.PrivateKey))
+	return token.SignedString(key.Private)
 }
 
 // []byte(j.config.PrivateKey
@@ -88,8 +134,26 @@ func (j *Jwt) CreateToken(obj *models.Admin) (string, error) {
 func (j *Jwt) ParseToken(tokenString string) (*jwt.Token, *CustomClaims, error) {
 	claims := &CustomClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(j.config.PrivateKey), nil
+		// 根据kid查找验证密钥，并确认算法一致，防止算法混淆攻击
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("未知的签名密钥")
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("签名算法不匹配")
+		}
+		return key.Public, nil
 	})
 
 	return token, claims, err
 }
+
+// 生成指定字节数的随机字符串，以十六进制表示
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
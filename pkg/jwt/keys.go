@@ -0,0 +1,347 @@
+package jwt
+
+import (
+	"FlyCloud/pkg/system"
+	"FlyCloud/serves/config"
+	"FlyCloud/serves/logging"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// 密钥ID中的时间格式
+const kidTimeFormat = "20060102T150405"
+
+// 签名密钥
+type signingKey struct {
+	Kid       string
+	Method    jwt.SigningMethod
+	Private   interface{}
+	Public    interface{}
+	CreatedAt time.Time
+}
+
+// 密钥集合，按创建时间升序排列，最后一个为当前签名密钥，其余仅用于验证
+type keySet struct {
+	mu   sync.RWMutex
+	cfg  *config.JwtConfig
+	keys []*signingKey
+}
+
+// 声明全局密钥集合
+var keys = &keySet{}
+
+// InitKeys 初始化签名密钥：HS256使用配置中的密钥，其它算法从PEM目录加载，目录为空时自动生成
+func InitKeys(cfg *config.JwtConfig) error {
+	fmt.Println("------------init jwt keys----------")
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	keys.cfg = cfg
+	keys.keys = nil
+	if cfg.Algorithm == "" || cfg.Algorithm == jwt.SigningMethodHS256.Alg() {
+		if cfg.PrivateKey == "" {
+			return errors.New("HS256 算法需要配置 private_key")
+		}
+		keys.keys = []*signingKey{{
+			Method:  jwt.SigningMethodHS256,
+			Private: []byte(cfg.PrivateKey),
+			Public:  []byte(cfg.PrivateKey),
+		}}
+		return nil
+	}
+	if _, err := methodFor(cfg.Algorithm); err != nil {
+		return err
+	}
+	if err := system.MkDir(cfg.KeyDir); err != nil {
+		return err
+	}
+	if err := keys.load(); err != nil {
+		return err
+	}
+	// 没有密钥或当前密钥与配置的算法不一致时，生成新密钥
+	if len(keys.keys) == 0 || keys.keys[len(keys.keys)-1].Method.Alg() != cfg.Algorithm {
+		if err := keys.generate(); err != nil {
+			return err
+		}
+	}
+	fmt.Println("------------init jwt keys success----------")
+	return nil
+}
+
+// StartKeyRotation 按配置的周期轮换密钥，返回停止函数
+func StartKeyRotation() func() {
+	done := make(chan struct{})
+	keys.mu.RLock()
+	disabled := keys.cfg == nil || keys.cfg.RotateInterval <= 0 || len(keys.keys) == 0 || isHMAC(keys.keys[0])
+	keys.mu.RUnlock()
+	if disabled {
+		return func() {}
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := keys.rotateIfDue(); err != nil {
+					logging.Error("密钥轮换失败：", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// Rotate 立即生成新的签名密钥，旧密钥在其签发的令牌过期前仍可用于验证
+func Rotate() error {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if len(keys.keys) > 0 && isHMAC(keys.keys[0]) {
+		return errors.New("HS256 算法不支持密钥轮换")
+	}
+	if err := keys.generate(); err != nil {
+		return err
+	}
+	keys.prune()
+	return nil
+}
+
+// JWKS 返回所有可用于验证的公钥，格式遵循 RFC 7517
+func JWKS() map[string]interface{} {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+	list := make([]map[string]interface{}, 0, len(keys.keys))
+	for _, k := range keys.keys {
+		if jwk := toJWK(k); jwk != nil {
+			list = append(list, jwk)
+		}
+	}
+	return map[string]interface{}{"keys": list}
+}
+
+// 获取当前签名密钥
+func (s *keySet) active() (*signingKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.keys) == 0 {
+		return nil, errors.New("签名密钥未初始化")
+	}
+	return s.keys[len(s.keys)-1], nil
+}
+
+// 根据kid查找验证密钥
+func (s *keySet) lookup(kid string) (*signingKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.Kid == kid {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// 当前密钥超过轮换周期时生成新密钥
+func (s *keySet) rotateIfDue() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) == 0 {
+		return nil
+	}
+	current := s.keys[len(s.keys)-1]
+	if time.Since(current.CreatedAt) >= time.Duration(s.cfg.RotateInterval)*time.Hour {
+		if err := s.generate(); err != nil {
+			return err
+		}
+	}
+	s.prune()
+	return nil
+}
+
+// 删除已退役且其签发的令牌均已过期的密钥
+func (s *keySet) prune() {
+	retention := time.Duration(s.cfg.ExpiresAt)*time.Minute + time.Minute
+	var kept []*signingKey
+	for i, k := range s.keys {
+		// 后继密钥的创建时间即为该密钥的退役时间
+		if i < len(s.keys)-1 && time.Since(s.keys[i+1].CreatedAt) > retention {
+			_ = os.Remove(filepath.Join(s.cfg.KeyDir, k.Kid+".pem"))
+			continue
+		}
+		kept = append(kept, k)
+	}
+	s.keys = kept
+}
+
+// 从密钥目录加载PEM格式的私钥，文件名即为kid
+func (s *keySet) load() error {
+	files, err := filepath.Glob(filepath.Join(s.cfg.KeyDir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		key, err := parsePrivateKey(data)
+		if err != nil {
+			return fmt.Errorf("解析密钥 %s 失败：%v", file, err)
+		}
+		key.Kid = strings.TrimSuffix(filepath.Base(file), ".pem")
+		// 自动生成的kid以创建时间开头，手动放入的密钥使用文件修改时间
+		if t, err := time.Parse(kidTimeFormat, strings.SplitN(key.Kid, "-", 2)[0]); err == nil {
+			key.CreatedAt = t
+		} else if info, err := os.Stat(file); err == nil {
+			key.CreatedAt = info.ModTime()
+		}
+		s.keys = append(s.keys, key)
+	}
+	sort.Slice(s.keys, func(i, j int) bool {
+		return s.keys[i].CreatedAt.Before(s.keys[j].CreatedAt)
+	})
+	return nil
+}
+
+// 按配置的算法生成新密钥并写入密钥目录
+func (s *keySet) generate() error {
+	var private interface{}
+	var err error
+	switch s.cfg.Algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return fmt.Errorf("不支持的签名算法：%s", s.cfg.Algorithm)
+	}
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	suffix, err := randomToken(2)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	kid := now.Format(kidTimeFormat) + "-" + suffix
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(s.cfg.KeyDir, kid+".pem"), data, 0600); err != nil {
+		return err
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return err
+	}
+	key.Kid = kid
+	key.CreatedAt, _ = time.Parse(kidTimeFormat, now.Format(kidTimeFormat))
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// 解析PEM格式的私钥，支持 PKCS8、PKCS1 和 SEC1
+func parsePrivateKey(data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("无效的PEM数据")
+	}
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 仅支持 P-256 曲线")
+		}
+		return &signingKey{Method: jwt.SigningMethodES256, Private: k, Public: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{Method: SigningMethodEdDSA, Private: k, Public: k.Public()}, nil
+	}
+	return nil, errors.New("不支持的密钥类型")
+}
+
+// 根据算法名称获取签名方法
+func methodFor(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		return jwt.SigningMethodRS256, nil
+	case jwt.SigningMethodES256.Alg():
+		return jwt.SigningMethodES256, nil
+	case SigningMethodEdDSA.Alg():
+		return SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("不支持的签名算法：%s", alg)
+}
+
+// 是否为HMAC密钥
+func isHMAC(k *signingKey) bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// 将公钥转换为JWK格式，HMAC密钥不公开
+func toJWK(k *signingKey) map[string]interface{} {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwk := map[string]interface{}{
+		"kid": k.Kid,
+		"use": "sig",
+		"alg": k.Method.Alg(),
+	}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = encode(pub.N.Bytes())
+		jwk["e"] = encode(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk["kty"] = "EC"
+		jwk["crv"] = pub.Curve.Params().Name
+		jwk["x"] = encode(pub.X.FillBytes(make([]byte, size)))
+		jwk["y"] = encode(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = encode(pub)
+	default:
+		return nil
+	}
+	return jwk
+}
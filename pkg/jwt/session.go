@@ -0,0 +1,193 @@
+package jwt
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/database"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// 令牌对，访问令牌短期有效，刷新令牌用于续期
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// 访问令牌有效期，单位秒
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+var (
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效")
+	ErrRefreshTokenExpired = errors.New("刷新令牌已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，该会话已全部失效")
+	ErrAccountDisabled     = errors.New("账号已被禁用")
+	ErrTenantSuspended     = errors.New("所属租户已停用")
+)
+
+// IssueTokens 登录成功后签发访问令牌与新的刷新令牌
+func (j *Jwt) IssueTokens(admin *models.Admin) (*TokenPair, error) {
+	family, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return j.issue(admin, family)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，旧的刷新令牌立即失效。
+// 如果已失效的刷新令牌被再次使用，视为令牌泄露，吊销整个会话。
+func (j *Jwt) Refresh(refreshToken string) (*TokenPair, error) {
+	db := database.GetDB()
+	var rt models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(refreshToken)).First(&rt).Error; err != nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if rt.RevokedAt != nil {
+		db.Model(&models.RefreshToken{}).Where("family = ? and revoked_at is null", rt.Family).Update("revoked_at", time.Now())
+		return nil, ErrRefreshTokenReused
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+	// 标记旧令牌已使用，条件更新防止并发重复刷新
+	result := db.Model(&models.RefreshToken{}).Where("id = ? and revoked_at is null", rt.ID).Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRefreshTokenReused
+	}
+	var admin models.Admin
+	if err := db.First(&admin, "id = ?", rt.AdminId).Error; err != nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if admin.Status != models.AdminStatusEnabled {
+		return nil, ErrAccountDisabled
+	}
+	var tenant models.Tenant
+	if err := db.Select("status").First(&tenant, "id = ?", admin.TenantId).Error; err != nil || tenant.Status != models.TenantStatusActive {
+		return nil, ErrTenantSuspended
+	}
+	return j.issue(&admin, rt.Family)
+}
+
+// 签发访问令牌，并在指定会话中保存新的刷新令牌
+func (j *Jwt) issue(admin *models.Admin, family string) (*TokenPair, error) {
+	access, err := j.CreateToken(admin)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	rt := models.RefreshToken{
+		AdminId:   admin.ID,
+		TokenHash: hashToken(refresh),
+		Family:    family,
+		ExpiresAt: time.Now().Add(time.Minute * time.Duration(j.config.RefreshExpiresAt)),
+	}
+	if err := database.GetDB().Create(&rt).Error; err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(j.config.ExpiresAt) * 60,
+	}, nil
+}
+
+// RevokeToken 将访问令牌加入黑名单，直到其自然过期
+func RevokeToken(claims *CustomClaims) error {
+	if claims.Id == "" {
+		return nil
+	}
+	db := database.GetDB()
+	// 顺便清理已过期的黑名单记录
+	db.Where("expires_at < ?", time.Now()).Delete(&models.TokenDenylist{})
+	if err := db.Save(&models.TokenDenylist{
+		Jti:       claims.Id,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}).Error; err != nil {
+		return err
+	}
+	_ = cache.SetCache(denyKey(claims.Id), []byte("1"))
+	return nil
+}
+
+// RevokeRefreshToken 吊销刷新令牌所在的整个会话
+func RevokeRefreshToken(refreshToken string) error {
+	db := database.GetDB()
+	var rt models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(refreshToken)).First(&rt).Error; err != nil {
+		return ErrRefreshTokenInvalid
+	}
+	return db.Model(&models.RefreshToken{}).Where("family = ? and revoked_at is null", rt.Family).Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllSessions 吊销用户的所有会话，用于禁用账号或修改密码
+func RevokeAllSessions(adminId uint) error {
+	db := database.GetDB()
+	now := time.Now()
+	if err := db.Model(&models.RefreshToken{}).Where("admin_id = ? and revoked_at is null", adminId).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := db.Save(&models.TokenRevocation{AdminId: adminId, RevokedAt: now.UnixMilli()}).Error; err != nil {
+		return err
+	}
+	_ = cache.SetCache(revokeKey(adminId), []byte(strconv.FormatInt(now.UnixMilli(), 10)))
+	return nil
+}
+
+// IsRevoked 判断访问令牌是否已被吊销
+func IsRevoked(claims *CustomClaims) bool {
+	// 检查jti黑名单
+	if claims.Id != "" {
+		entry, err := cache.GetCache(denyKey(claims.Id))
+		if err != nil {
+			var count int
+			database.GetDB().Model(&models.TokenDenylist{}).Where("jti = ?", claims.Id).Count(&count)
+			entry = []byte("0")
+			if count > 0 {
+				entry = []byte("1")
+			}
+			_ = cache.SetCache(denyKey(claims.Id), entry)
+		}
+		if string(entry) == "1" {
+			return true
+		}
+	}
+	// 检查用户级别的吊销时间
+	entry, err := cache.GetCache(revokeKey(claims.UserId))
+	if err != nil {
+		var revocation models.TokenRevocation
+		database.GetDB().Where("admin_id = ?", claims.UserId).First(&revocation)
+		entry = []byte(strconv.FormatInt(revocation.RevokedAt, 10))
+		_ = cache.SetCache(revokeKey(claims.UserId), entry)
+	}
+	revokedAt, _ := strconv.ParseInt(string(entry), 10, 64)
+	// 按毫秒比较，吊销后立即签发的新令牌仍然有效；没有毫秒签发时间的令牌按所在秒的开始计算
+	issuedAt := claims.IssuedAtMs
+	if issuedAt == 0 {
+		issuedAt = claims.IssuedAt * 1000
+	}
+	return issuedAt < revokedAt
+}
+
+// 计算令牌的哈希值
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 黑名单缓存key
+func denyKey(jti string) string {
+	return "jwt:deny:" + jti
+}
+
+// 用户吊销时间缓存key
+func revokeKey(adminId uint) string {
+	return "jwt:revoke:" + strconv.FormatUint(uint64(adminId), 10)
+}
@@ -0,0 +1,171 @@
+package lockout
+
+import (
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/config"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 失败计数，保存在缓存中
+type counter struct {
+	// 时间窗口内的失败次数
+	Failures int `json:"f"`
+	// 最近一次失败的时间
+	LastFailure int64 `json:"l"`
+	// 锁定截止时间
+	LockedUntil int64 `json:"u"`
+}
+
+// LimitError 登录受限错误
+type LimitError struct {
+	// 是否为锁定，否则为需要等待
+	Locked bool
+	// 需要等待的时长
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("登录失败次数过多，已被锁定，请%d分钟后再试", int(e.RetryAfter.Minutes())+1)
+	}
+	return fmt.Sprintf("登录过于频繁，请%d秒后再试", e.Seconds())
+}
+
+// Seconds 需要等待的秒数，向上取整
+func (e *LimitError) Seconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// 缓存的读写不是原子操作，统一加锁
+var mu sync.Mutex
+
+// 获取登录配置，未配置时使用默认值
+func getConfig() config.LoginConfig {
+	cfg := config.LoginConfig{}
+	if config.GetConfig().LoginConfig != nil {
+		cfg = *config.GetConfig().LoginConfig
+	}
+	if cfg.FailureWindow <= 0 {
+		cfg.FailureWindow = 15
+	}
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = 15
+	}
+	return cfg
+}
+
+// Check 登录前检查账号和IP是否被锁定或需要等待
+func Check(username, ip string) *LimitError {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg := getConfig()
+	now := time.Now()
+	var result *LimitError
+	for _, key := range []string{userKey(username), ipKey(ip)} {
+		c := load(key, cfg, now)
+		var err *LimitError
+		if c.LockedUntil > now.Unix() {
+			err = &LimitError{Locked: true, RetryAfter: time.Unix(c.LockedUntil, 0).Sub(now)}
+		} else if wait := delay(cfg, c.Failures); wait > 0 {
+			if remain := time.Unix(c.LastFailure, 0).Add(wait).Sub(now); remain > 0 {
+				err = &LimitError{RetryAfter: remain}
+			}
+		}
+		// 同时受限时，优先返回锁定，其次返回等待更久的
+		if err != nil && (result == nil || (err.Locked && !result.Locked) || (err.Locked == result.Locked && err.RetryAfter > result.RetryAfter)) {
+			result = err
+		}
+	}
+	return result
+}
+
+// Fail 记录一次登录失败，达到阈值时锁定。账号因此被锁定时返回锁定截止时间
+func Fail(username, ip string) time.Time {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg := getConfig()
+	now := time.Now()
+	fail(ipKey(ip), cfg.MaxIpFailures, cfg, now)
+	return fail(userKey(username), cfg.MaxUserFailures, cfg, now)
+}
+
+// Succeed 登录成功后清除账号的失败计数，IP计数保留以防止撞库
+func Succeed(username string) {
+	mu.Lock()
+	defer mu.Unlock()
+	_ = cache.DeleteCache(userKey(username))
+}
+
+// Unlock 解除账号的锁定，并清除失败计数
+func Unlock(usernames ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, username := range usernames {
+		if username != "" {
+			_ = cache.DeleteCache(userKey(username))
+		}
+	}
+}
+
+// 累加失败次数，返回锁定截止时间
+func fail(key string, max int, cfg config.LoginConfig, now time.Time) time.Time {
+	c := load(key, cfg, now)
+	c.Failures++
+	c.LastFailure = now.Unix()
+	var lockedUntil time.Time
+	if max > 0 && c.Failures >= max {
+		lockedUntil = now.Add(time.Duration(cfg.LockoutDuration) * time.Minute)
+		c.LockedUntil = lockedUntil.Unix()
+		// 锁定结束后重新计数
+		c.Failures = 0
+	}
+	if data, err := json.Marshal(c); err == nil {
+		_ = cache.SetCache(key, data)
+	}
+	return lockedUntil
+}
+
+// 读取失败计数，超出时间窗口的计数视为已过期
+func load(key string, cfg config.LoginConfig, now time.Time) counter {
+	var c counter
+	data, err := cache.GetCache(key)
+	if err != nil || json.Unmarshal(data, &c) != nil {
+		return counter{}
+	}
+	if c.LockedUntil <= now.Unix() && now.Sub(time.Unix(c.LastFailure, 0)) > time.Duration(cfg.FailureWindow)*time.Minute {
+		return counter{}
+	}
+	return c
+}
+
+// 计算失败次数对应的等待时长
+func delay(cfg config.LoginConfig, failures int) time.Duration {
+	if cfg.DelayBase <= 0 || failures < cfg.DelayAfter || failures == 0 {
+		return 0
+	}
+	wait := time.Duration(cfg.DelayBase) * time.Second
+	for i := cfg.DelayAfter; i < failures; i++ {
+		wait *= 2
+		if cfg.DelayMax > 0 && wait >= time.Duration(cfg.DelayMax)*time.Second {
+			break
+		}
+	}
+	if cfg.DelayMax > 0 && wait > time.Duration(cfg.DelayMax)*time.Second {
+		wait = time.Duration(cfg.DelayMax) * time.Second
+	}
+	return wait
+}
+
+// 账号失败计数缓存key
+func userKey(username string) string {
+	return "login:user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// IP失败计数缓存key
+func ipKey(ip string) string {
+	return "login:ip:" + ip
+}
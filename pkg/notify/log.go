@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"FlyCloud/pkg/system"
+	"FlyCloud/serves/logging"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 写入文件的通知方式，用于开发环境代替真实的邮件或短信
+type logNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (n *logNotifier) Channel() string {
+	return ChannelAny
+}
+
+func (n *logNotifier) Send(to, subject, body string) error {
+	logging.Info("notify to:", to, " subject:", subject)
+	if n.path == "" {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := system.MkDir(filepath.Dir(n.path)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "----- %s -----\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), to, subject, body)
+	return err
+}
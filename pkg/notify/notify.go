@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"FlyCloud/serves/config"
+	"fmt"
+	"sync"
+)
+
+// 通知渠道
+const (
+	// 不限渠道，优先使用邮箱
+	ChannelAny = ""
+	// 邮件
+	ChannelEmail = "email"
+	// 短信
+	ChannelSms = "sms"
+)
+
+// Notifier 消息通知接口
+type Notifier interface {
+	// Channel 通知渠道，决定使用邮箱还是手机号作为收件地址
+	Channel() string
+	// Send 发送通知，短信渠道会忽略标题
+	Send(to, subject, body string) error
+}
+
+var (
+	mu sync.RWMutex
+	// 未初始化时只写日志，避免开发环境误发消息
+	notifier Notifier = &logNotifier{}
+)
+
+// Init 根据配置初始化通知方式
+func Init(cfg *config.NotifyConfig) error {
+	fmt.Println("------------init notifier----------")
+	n, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	notifier = n
+	mu.Unlock()
+	fmt.Println("------------init notifier success----------")
+	return nil
+}
+
+// New 根据配置创建通知方式
+func New(cfg *config.NotifyConfig) (Notifier, error) {
+	if cfg == nil {
+		return &logNotifier{}, nil
+	}
+	switch cfg.Driver {
+	case "", "log":
+		return &logNotifier{path: cfg.LogPath}, nil
+	case "smtp":
+		if cfg.Smtp == nil || cfg.Smtp.Host == "" {
+			return nil, fmt.Errorf("smtp 通知方式需要配置邮件服务器")
+		}
+		return &smtpNotifier{cfg: cfg.Smtp}, nil
+	case "sms":
+		if cfg.Sms == nil {
+			return nil, fmt.Errorf("sms 通知方式需要配置短信网关")
+		}
+		provider, err := newSmsProvider(cfg.Sms)
+		if err != nil {
+			return nil, err
+		}
+		return &smsNotifier{provider: provider}, nil
+	}
+	return nil, fmt.Errorf("不支持的通知方式：%s", cfg.Driver)
+}
+
+// Get 获取当前的通知方式
+func Get() Notifier {
+	mu.RLock()
+	defer mu.RUnlock()
+	return notifier
+}
+
+// Address 根据通知渠道选择收件地址，没有可用地址时返回空字符串
+func Address(email, telephone string) string {
+	switch Get().Channel() {
+	case ChannelEmail:
+		return email
+	case ChannelSms:
+		return telephone
+	}
+	if email != "" {
+		return email
+	}
+	return telephone
+}
+
+// Send 使用当前的通知方式发送消息
+func Send(to, subject, body string) error {
+	return Get().Send(to, subject, body)
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"FlyCloud/serves/config"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SmsProvider 短信服务商接口，接入具体的短信平台时实现该接口并注册
+type SmsProvider interface {
+	Send(phone, content string) error
+}
+
+// 短信服务商构造函数
+type SmsProviderFactory func(cfg *config.SmsConfig) (SmsProvider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]SmsProviderFactory{
+		"webhook": newWebhookProvider,
+	}
+)
+
+// RegisterSmsProvider 注册短信服务商
+func RegisterSmsProvider(name string, factory SmsProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = factory
+}
+
+// 根据配置创建短信服务商
+func newSmsProvider(cfg *config.SmsConfig) (SmsProvider, error) {
+	name := cfg.Provider
+	if name == "" {
+		name = "webhook"
+	}
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的短信服务商：%s", name)
+	}
+	return factory(cfg)
+}
+
+// 短信通知方式
+type smsNotifier struct {
+	provider SmsProvider
+}
+
+func (n *smsNotifier) Channel() string {
+	return ChannelSms
+}
+
+func (n *smsNotifier) Send(to, subject, body string) error {
+	return n.provider.Send(to, body)
+}
+
+// 以JSON格式将短信POST到指定地址，由外部服务完成实际发送
+type webhookProvider struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newWebhookProvider(cfg *config.SmsConfig) (SmsProvider, error) {
+	if cfg.Url == "" {
+		return nil, fmt.Errorf("webhook 短信服务商需要配置 url")
+	}
+	return &webhookProvider{
+		url:    cfg.Url,
+		token:  cfg.Token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *webhookProvider) Send(phone, content string) error {
+	data, err := json.Marshal(map[string]string{
+		"phone":   phone,
+		"content": content,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", p.token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("短信发送失败，状态码：%d", resp.StatusCode)
+	}
+	return nil
+}
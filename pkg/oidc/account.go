@@ -0,0 +1,166 @@
+package oidc
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/account"
+	"FlyCloud/pkg/password"
+	"FlyCloud/serves/config"
+	"FlyCloud/serves/database"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrIdentityNotLinked 未找到对应的本地管理员
+var ErrIdentityNotLinked = errors.New("该账号未关联管理员，请联系管理员开通")
+
+// 用户名允许的字符
+var usernameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+// ResolveAdmin 根据外部身份查找管理员：先按已绑定的身份，再按已验证的邮箱，最后自动创建
+func ResolveAdmin(db *gorm.DB, claims *Claims) (*models.Admin, error) {
+	cfg := config.GetConfig().OidcConfig
+	role := Role(claims.Groups)
+	now := time.Now()
+	var admin models.Admin
+	// 已绑定的身份
+	var identity models.AdminIdentity
+	if err := db.Where("issuer = ? and subject = ?", claims.Issuer, claims.Subject).First(&identity).Error; err == nil {
+		if err := db.First(&admin, "id = ?", identity.AdminId).Error; err == nil {
+			db.Model(&identity).Updates(map[string]interface{}{"email": claims.Email, "last_login_at": now})
+			// 按用户组同步角色，角色需在管理员所属的租户中存在
+			if cfg.SyncRole && role != "" && role != admin.RolesName && models.IsExistRole(database.WithTenant(db, admin.TenantId), role) {
+				if err := db.Model(&models.Admin{}).Where("id = ?", admin.ID).Update("roles_name", role).Error; err != nil {
+					return nil, err
+				}
+				admin.RolesName = role
+				account.Invalidate(admin.ID)
+			}
+			return &admin, nil
+		}
+		// 管理员已被删除，解除绑定后重新匹配
+		db.Delete(&identity)
+	}
+	// 按已验证的邮箱匹配，邮箱对应多个管理员时不自动绑定
+	found := false
+	if cfg.LinkByEmail && claims.EmailVerified && claims.Email != "" {
+		var admins []models.Admin
+		db.Where("email = ?", claims.Email).Limit(2).Find(&admins)
+		if len(admins) == 1 {
+			admin, found = admins[0], true
+		}
+	}
+	// 自动创建管理员，创建在默认租户中
+	if !found {
+		if !cfg.AutoProvision {
+			return nil, ErrIdentityNotLinked
+		}
+		if role == "" || !models.IsExistRole(database.WithTenant(db, models.DefaultTenantId), role) {
+			return nil, errors.New("没有可分配的角色，请联系管理员开通")
+		}
+		if err := provision(db, claims, role, &admin); err != nil {
+			return nil, err
+		}
+	}
+	// 绑定身份
+	identity = models.AdminIdentity{
+		AdminId:     admin.ID,
+		Issuer:      claims.Issuer,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+	}
+	if err := db.Create(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// 根据外部身份创建管理员，密码随机生成，需要时可通过找回密码设置
+func provision(db *gorm.DB, claims *Claims, role string, admin *models.Admin) error {
+	plain, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	nickname := []rune(claims.Name)
+	if len(nickname) > 15 {
+		nickname = nickname[:15]
+	}
+	*admin = models.Admin{
+		Username:  uniqueUsername(db, claims),
+		Password:  hash,
+		Nickname:  string(nickname),
+		Telephone: telephone(db, claims),
+		Email:     claims.Email,
+		Status:    models.AdminStatusEnabled,
+		RolesName: role,
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(admin).Error; err != nil {
+			return err
+		}
+		return models.AddPasswordHistory(tx, admin.ID, hash)
+	})
+}
+
+// 生成不重复的用户名，依次尝试preferred_username、邮箱前缀和sub
+func uniqueUsername(db *gorm.DB, claims *Claims) string {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base = strings.Split(claims.Email, "@")[0]
+	}
+	if base = usernameInvalidChars.ReplaceAllString(base, ""); base == "" {
+		base = "sso"
+	}
+	if len(base) > 90 {
+		base = base[:90]
+	}
+	username := base
+	for i := 0; i < 5; i++ {
+		var count int
+		db.Model(&models.Admin{}).Where("username = ?", username).Count(&count)
+		if count == 0 {
+			return username
+		}
+		suffix, _ := randomHex(2)
+		username = base + "_" + suffix
+	}
+	return base + "_" + hashSubject(claims)[:8]
+}
+
+// 手机号不能为空且不能重复，身份提供方没有提供可用手机号时按身份生成占位值
+func telephone(db *gorm.DB, claims *Claims) string {
+	if phone := claims.PhoneNumber; phone != "" && len(phone) <= 15 {
+		var count int
+		db.Model(&models.Admin{}).Where("telephone = ?", phone).Count(&count)
+		if count == 0 {
+			return phone
+		}
+	}
+	return "sso_" + hashSubject(claims)[:11]
+}
+
+// 外部身份的哈希值
+func hashSubject(claims *Claims) string {
+	sum := sha256.Sum256([]byte(claims.Issuer + "|" + claims.Subject))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成随机十六进制字符串
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,253 @@
+package oidc
+
+import (
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 登录请求的有效期
+	stateTTL = 5 * time.Minute
+	// 发现文档的缓存时间
+	discoveryTTL = time.Hour
+)
+
+var (
+	ErrDisabled     = errors.New("未启用单点登录")
+	ErrInvalidState = errors.New("登录请求无效或已过期，请重新登录")
+)
+
+// HTTPClient 访问身份提供方时使用的客户端
+var HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// 身份提供方的发现文档
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// 缓存的发现文档
+var meta struct {
+	mu       sync.Mutex
+	issuer   string
+	doc      *discovery
+	loadedAt time.Time
+}
+
+// 登录请求，保存在缓存中，回调时取出校验
+type authRequest struct {
+	Nonce     string `json:"nonce"`
+	Verifier  string `json:"verifier"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Claims ID令牌中与登录相关的声明
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	PhoneNumber       string
+	Groups            []string
+}
+
+// Enabled 是否启用了单点登录
+func Enabled() bool {
+	cfg := config.GetConfig().OidcConfig
+	return cfg != nil && cfg.Enable && cfg.Issuer != "" && cfg.ClientId != ""
+}
+
+// AuthURL 生成跳转到身份提供方的授权地址，使用PKCE防止授权码被截获
+func AuthURL() (authUrl string, state string, err error) {
+	if !Enabled() {
+		return "", "", ErrDisabled
+	}
+	cfg := config.GetConfig().OidcConfig
+	doc, err := getDiscovery()
+	if err != nil {
+		return "", "", err
+	}
+	req := authRequest{CreatedAt: time.Now().Unix()}
+	if state, err = randomString(); err != nil {
+		return "", "", err
+	}
+	if req.Nonce, err = randomString(); err != nil {
+		return "", "", err
+	}
+	if req.Verifier, err = randomString(); err != nil {
+		return "", "", err
+	}
+	data, _ := json.Marshal(req)
+	if err = cache.SetCache(stateKey(state), data); err != nil {
+		return "", "", err
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	challenge := sha256.Sum256([]byte(req.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientId},
+		"redirect_uri":          {cfg.RedirectUrl},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + query.Encode(), state, nil
+}
+
+// Exchange 使用授权码换取ID令牌并验证，返回其中的声明
+func Exchange(code string, state string) (*Claims, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	// 取出登录请求，每个state只能使用一次
+	data, err := cache.GetCache(stateKey(state))
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	_ = cache.DeleteCache(stateKey(state))
+	var req authRequest
+	if err := json.Unmarshal(data, &req); err != nil || time.Since(time.Unix(req.CreatedAt, 0)) > stateTTL {
+		return nil, ErrInvalidState
+	}
+	doc, err := getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := requestToken(doc, code, req.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	return verifyIdToken(doc, idToken, req.Nonce)
+}
+
+// 向令牌端点提交授权码
+func requestToken(doc *discovery, code string, verifier string) (string, error) {
+	cfg := config.GetConfig().OidcConfig
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectUrl},
+		"client_id":     {cfg.ClientId},
+		"code_verifier": {verifier},
+	}
+	if cfg.ClientSecret != "" && cfg.AuthMethod == "client_secret_post" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" && cfg.AuthMethod != "client_secret_post" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientId), url.QueryEscape(cfg.ClientSecret))
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求令牌失败：%w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("令牌响应格式错误：%s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || result.Error != "" {
+		return "", fmt.Errorf("请求令牌失败：%s %s", result.Error, result.ErrorDescription)
+	}
+	if result.IdToken == "" {
+		return "", errors.New("令牌响应中没有id_token")
+	}
+	return result.IdToken, nil
+}
+
+// 获取身份提供方的发现文档，配置的地址变更后重新获取
+func getDiscovery() (*discovery, error) {
+	issuer := strings.TrimRight(config.GetConfig().OidcConfig.Issuer, "/")
+	meta.mu.Lock()
+	defer meta.mu.Unlock()
+	if meta.doc != nil && meta.issuer == issuer && time.Since(meta.loadedAt) < discoveryTTL {
+		return meta.doc, nil
+	}
+	var doc discovery
+	if err := getJSON(issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("获取身份提供方配置失败：%w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("身份提供方地址不一致：%s", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JwksUri == "" {
+		return nil, errors.New("身份提供方配置不完整")
+	}
+	meta.issuer, meta.doc, meta.loadedAt = issuer, &doc, time.Now()
+	return &doc, nil
+}
+
+// 以GET方式请求JSON数据
+func getJSON(u string, v interface{}) error {
+	resp, err := HTTPClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %s", u, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// 生成随机字符串，用于state、nonce和PKCE
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 登录请求缓存key
+func stateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+// Role 根据用户组获取对应的角色，没有匹配的用户组时返回默认角色
+func Role(groups []string) string {
+	cfg := config.GetConfig().OidcConfig
+	for _, m := range cfg.RoleMapping {
+		for _, g := range groups {
+			if m != nil && m.Group == g {
+				return m.Role
+			}
+		}
+	}
+	return cfg.DefaultRole
+}
@@ -0,0 +1,468 @@
+package oidc
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/config"
+	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jinzhu/gorm"
+)
+
+// 测试使用的客户端ID
+const testClientId = "flycloud"
+
+var testDB *gorm.DB
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "oidc-test")
+	if err != nil {
+		panic(err)
+	}
+	logging.InitLogger(&config.LoggerConfig{Level: "error", Console: true})
+	cache.InitCache(&config.CacheConfig{Shards: 16, LifeWindow: 5})
+	testDB = database.InitDB(&config.DatabaseConfig{Type: "sqlite3", Database: filepath.Join(dir, "test.db")})
+	models.InitTenantTable(testDB)
+	models.InitAdminTable(testDB)
+	models.InitRolesTable(testDB)
+	models.InitAdminIdentityTable(testDB)
+	models.InitPasswordHistoryTable(testDB)
+	testDB.Create(&models.Roles{Name: "管理员", Alias: "manage", DataScope: models.DataScopeAll})
+	code := m.Run()
+	_ = database.Close()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 模拟的身份提供方，提供发现文档、公钥集和令牌端点
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// 签名ID令牌使用的密钥，为空时使用公钥集中的密钥
+	signer *rsa.PrivateKey
+	mu     sync.Mutex
+	// 授权码对应的登录请求
+	codes map[string]mockCode
+}
+
+// 用户在身份提供方完成登录后签发的授权码
+type mockCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// 启动模拟的身份提供方，并将单点登录配置指向它
+func newMockProvider(t *testing.T, cfg *config.OidcConfig) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{t: t, key: key, codes: make(map[string]mockCode)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	// 通过包的HTTP客户端访问模拟的身份提供方
+	client := HTTPClient
+	HTTPClient = p.server.Client()
+	t.Cleanup(func() { HTTPClient = client })
+
+	if cfg == nil {
+		cfg = &config.OidcConfig{}
+	}
+	cfg.Enable = true
+	cfg.Issuer = p.server.URL
+	cfg.ClientId = testClientId
+	cfg.ClientSecret = "secret"
+	cfg.RedirectUrl = "http://localhost:8080/#/oidc/callback"
+	if cfg.RoleClaim == "" {
+		cfg.RoleClaim = "groups"
+	}
+	config.Set(&config.ConfigStruct{OidcConfig: cfg})
+	return p
+}
+
+func (p *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 p.server.URL,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+func (p *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   encode(p.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// 令牌端点，校验客户端、授权码和PKCE后签发ID令牌
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != testClientId || secret != "secret" {
+		fail("invalid_client")
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		fail("invalid_request")
+		return
+	}
+	p.mu.Lock()
+	c, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if !ok || challenge(r.PostForm.Get("code_verifier")) != c.challenge {
+		fail("invalid_grant")
+		return
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c.claims)
+	token.Header["kid"] = "test"
+	signer := p.key
+	if p.signer != nil {
+		signer = p.signer
+	}
+	idToken, err := token.SignedString(signer)
+	if err != nil {
+		p.t.Error(err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+// 模拟用户在身份提供方登录，按授权地址中的参数签发授权码。
+// modify 可以修改ID令牌中的声明
+func (p *mockProvider) authorize(t *testing.T, authUrl string, subject string, modify func(jwt.MapClaims)) string {
+	u, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("授权地址缺少PKCE参数：%s", authUrl)
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   testClientId,
+		"sub":   subject,
+		"nonce": query.Get("nonce"),
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+	}
+	if modify != nil {
+		modify(claims)
+	}
+	code, _ := randomString()
+	p.mu.Lock()
+	p.codes[code] = mockCode{challenge: query.Get("code_challenge"), claims: claims}
+	p.mu.Unlock()
+	return code
+}
+
+// 生成授权地址并完成登录，返回授权码和state
+func (p *mockProvider) login(t *testing.T, subject string, modify func(jwt.MapClaims)) (string, string) {
+	authUrl, state, err := AuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.authorize(t, authUrl, subject, modify), state
+}
+
+// 按S256方式计算PKCE的challenge
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestPKCERoundTrip(t *testing.T) {
+	p := newMockProvider(t, nil)
+	authUrl, state, err := AuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authUrl, p.server.URL+"/authorize?") {
+		t.Fatalf("授权地址错误：%s", authUrl)
+	}
+	query, _ := url.ParseQuery(strings.SplitN(authUrl, "?", 2)[1])
+	if query.Get("state") != state || query.Get("client_id") != testClientId {
+		t.Fatalf("授权地址参数错误：%s", authUrl)
+	}
+	// 缓存中的verifier与授权地址中的challenge对应
+	data, err := cache.GetCache(stateKey(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req authRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	if challenge(req.Verifier) != query.Get("code_challenge") {
+		t.Fatal("code_challenge 与 code_verifier 不对应")
+	}
+	code := p.authorize(t, authUrl, "user-1", nil)
+	claims, err := Exchange(code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" || claims.Issuer != p.server.URL {
+		t.Fatalf("声明错误：%+v", claims)
+	}
+	// 每个state只能使用一次
+	if _, err := Exchange(code, state); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("重复使用state应失败，实际：%v", err)
+	}
+}
+
+func TestPKCEMismatch(t *testing.T) {
+	p := newMockProvider(t, nil)
+	code, state := p.login(t, "user-1", nil)
+	// 身份提供方记录的challenge与登录请求的verifier不对应
+	p.mu.Lock()
+	c := p.codes[code]
+	c.challenge = challenge("other")
+	p.codes[code] = c
+	p.mu.Unlock()
+	if _, err := Exchange(code, state); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("PKCE不对应时应失败，实际：%v", err)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  string
+		modify func(jwt.MapClaims)
+		want   string
+	}{
+		{name: "wrong state", state: "wrong", want: ErrInvalidState.Error()},
+		{name: "wrong nonce", modify: func(c jwt.MapClaims) { c["nonce"] = "other" }, want: "nonce"},
+		{name: "missing nonce", modify: func(c jwt.MapClaims) { delete(c, "nonce") }, want: "nonce"},
+		{name: "wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "other-client" }, want: "接收者"},
+		{name: "wrong azp", modify: func(c jwt.MapClaims) {
+			c["aud"] = []interface{}{testClientId, "other-client"}
+			c["azp"] = "other-client"
+		}, want: "接收者"},
+		{name: "wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "http://evil.example.com" }, want: "签发者"},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, want: "过期"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newMockProvider(t, nil)
+			code, state := p.login(t, "user-1", tt.modify)
+			if tt.state != "" {
+				state = tt.state
+			}
+			_, err := Exchange(code, state)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("应包含 %q，实际：%v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsForeignKey(t *testing.T) {
+	p := newMockProvider(t, nil)
+	code, state := p.login(t, "user-1", nil)
+	// 使用其他密钥签名的ID令牌
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.signer = other
+	if _, err := Exchange(code, state); err == nil {
+		t.Fatal("签名公钥不对应时应失败")
+	}
+}
+
+func TestRole(t *testing.T) {
+	newMockProvider(t, &config.OidcConfig{
+		RoleMapping: []*config.OidcRoleMapping{
+			{Group: "admins", Role: "super"},
+			{Group: "managers", Role: "manage"},
+		},
+		DefaultRole: "guest",
+	})
+	tests := []struct {
+		groups []string
+		want   string
+	}{
+		{[]string{"managers"}, "manage"},
+		// 按映射的顺序匹配第一个
+		{[]string{"managers", "admins"}, "super"},
+		{[]string{"others"}, "guest"},
+		{nil, "guest"},
+	}
+	for _, tt := range tests {
+		if got := Role(tt.groups); got != tt.want {
+			t.Errorf("Role(%v) = %q，期望 %q", tt.groups, got, tt.want)
+		}
+	}
+}
+
+// 创建测试用的管理员
+func createAdmin(t *testing.T, username string, email string) *models.Admin {
+	admin := &models.Admin{
+		Username:  username,
+		Password:  "-",
+		Telephone: username,
+		Email:     email,
+		Status:    models.AdminStatusEnabled,
+		RolesName: "manage",
+	}
+	if err := testDB.Create(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	return admin
+}
+
+func TestResolveAdminBySubject(t *testing.T) {
+	newMockProvider(t, nil)
+	admin := createAdmin(t, "linked", "")
+	issuer := config.GetConfig().OidcConfig.Issuer
+	testDB.Create(&models.AdminIdentity{AdminId: admin.ID, Issuer: issuer, Subject: "sub-linked"})
+	got, err := ResolveAdmin(testDB, &Claims{Issuer: issuer, Subject: "sub-linked", Email: "new@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != admin.ID {
+		t.Fatalf("应匹配已绑定的管理员 %d，实际 %d", admin.ID, got.ID)
+	}
+	var identity models.AdminIdentity
+	testDB.First(&identity, "issuer = ? and subject = ?", issuer, "sub-linked")
+	if identity.Email != "new@example.com" || identity.LastLoginAt == nil {
+		t.Fatalf("登录后应更新身份信息：%+v", identity)
+	}
+}
+
+func TestResolveAdminByEmail(t *testing.T) {
+	newMockProvider(t, &config.OidcConfig{LinkByEmail: true})
+	admin := createAdmin(t, "by-email", "by-email@example.com")
+	issuer := config.GetConfig().OidcConfig.Issuer
+	// 邮箱未验证时不按邮箱匹配
+	claims := &Claims{Issuer: issuer, Subject: "sub-email", Email: "by-email@example.com"}
+	if _, err := ResolveAdmin(testDB, claims); !errors.Is(err, ErrIdentityNotLinked) {
+		t.Fatalf("邮箱未验证时应失败，实际：%v", err)
+	}
+	claims.EmailVerified = true
+	got, err := ResolveAdmin(testDB, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != admin.ID {
+		t.Fatalf("应按邮箱匹配管理员 %d，实际 %d", admin.ID, got.ID)
+	}
+	// 匹配后绑定身份，之后按身份匹配
+	var count int
+	testDB.Model(&models.AdminIdentity{}).Where("issuer = ? and subject = ? and admin_id = ?", issuer, "sub-email", admin.ID).Count(&count)
+	if count != 1 {
+		t.Fatal("按邮箱匹配后应绑定身份")
+	}
+	// 邮箱对应多个管理员时不自动绑定
+	createAdmin(t, "dup-1", "dup@example.com")
+	createAdmin(t, "dup-2", "dup@example.com")
+	if _, err := ResolveAdmin(testDB, &Claims{Issuer: issuer, Subject: "sub-dup", Email: "dup@example.com", EmailVerified: true}); !errors.Is(err, ErrIdentityNotLinked) {
+		t.Fatalf("邮箱重复时应失败，实际：%v", err)
+	}
+}
+
+func TestResolveAdminProvision(t *testing.T) {
+	p := newMockProvider(t, &config.OidcConfig{
+		AutoProvision: true,
+		RoleMapping: []*config.OidcRoleMapping{
+			{Group: "flycloud-admins", Role: "super"},
+			{Group: "flycloud-managers", Role: "manage"},
+		},
+	})
+	// 通过完整的登录流程获取用户组
+	code, state := p.login(t, "sub-new", func(c jwt.MapClaims) {
+		c["email"] = "new-user@example.com"
+		c["preferred_username"] = "new user"
+		c["name"] = "新用户"
+		c["groups"] = []interface{}{"others", "flycloud-managers"}
+	})
+	claims, err := Exchange(code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := ResolveAdmin(testDB, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.RolesName != "manage" || admin.Username != "newuser" || admin.Status != models.AdminStatusEnabled {
+		t.Fatalf("自动创建的管理员错误：%+v", admin)
+	}
+	var count int
+	testDB.Model(&models.PasswordHistory{}).Where("admin_id = ?", admin.ID).Count(&count)
+	if count != 1 {
+		t.Fatal("自动创建的管理员应记录密码历史")
+	}
+	// 再次登录时按身份匹配，不重复创建
+	again, err := ResolveAdmin(testDB, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != admin.ID {
+		t.Fatalf("再次登录应匹配同一个管理员 %d，实际 %d", admin.ID, again.ID)
+	}
+	// 没有匹配的用户组且没有默认角色时不创建
+	_, err = ResolveAdmin(testDB, &Claims{Issuer: claims.Issuer, Subject: "sub-nogroup", Groups: []string{"others"}})
+	if err == nil || errors.Is(err, ErrIdentityNotLinked) {
+		t.Fatalf("没有可分配的角色时应失败，实际：%v", err)
+	}
+	// 未启用自动创建时不创建
+	config.GetConfig().OidcConfig.AutoProvision = false
+	if _, err := ResolveAdmin(testDB, &Claims{Issuer: claims.Issuer, Subject: "sub-disabled", Groups: []string{"flycloud-admins"}}); !errors.Is(err, ErrIdentityNotLinked) {
+		t.Fatalf("未启用自动创建时应失败，实际：%v", err)
+	}
+}
+
+func TestResolveAdminSyncRole(t *testing.T) {
+	newMockProvider(t, &config.OidcConfig{
+		SyncRole:    true,
+		RoleMapping: []*config.OidcRoleMapping{{Group: "flycloud-admins", Role: "super"}},
+	})
+	admin := createAdmin(t, "sync-role", "")
+	issuer := config.GetConfig().OidcConfig.Issuer
+	testDB.Create(&models.AdminIdentity{AdminId: admin.ID, Issuer: issuer, Subject: "sub-sync"})
+	got, err := ResolveAdmin(testDB, &Claims{Issuer: issuer, Subject: "sub-sync", Groups: []string{"flycloud-admins"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored models.Admin
+	testDB.First(&stored, "id = ?", admin.ID)
+	if got.RolesName != "super" || stored.RolesName != "super" {
+		t.Fatalf("应按用户组同步角色，实际 %q", stored.RolesName)
+	}
+}
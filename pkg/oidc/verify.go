@@ -0,0 +1,233 @@
+package oidc
+
+import (
+	"FlyCloud/serves/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// 允许的时钟偏差
+	clockSkew = time.Minute
+	// 遇到未知kid时重新获取公钥的最短间隔
+	jwksRefreshInterval = time.Minute
+)
+
+// 身份提供方的公钥集
+var jwks struct {
+	mu       sync.Mutex
+	uri      string
+	keys     map[string]interface{}
+	loadedAt time.Time
+}
+
+// JWK格式的公钥
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// 验证ID令牌的签名和声明
+func verifyIdToken(doc *discovery, idToken string, nonce string) (*Claims, error) {
+	cfg := config.GetConfig().OidcConfig
+	parser := &jwt.Parser{
+		// 只接受非对称签名，防止使用公钥伪造HMAC签名
+		ValidMethods:         []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+		SkipClaimsValidation: true,
+	}
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return publicKey(doc.JwksUri, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ID令牌验证失败：%w", err)
+	}
+	// 验证签发者、接收者和nonce
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != strings.TrimRight(doc.Issuer, "/") {
+		return nil, errors.New("ID令牌签发者错误")
+	}
+	if !hasAudience(claims["aud"], cfg.ClientId) {
+		return nil, errors.New("ID令牌接收者错误")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != cfg.ClientId {
+		return nil, errors.New("ID令牌接收者错误")
+	}
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, errors.New("ID令牌nonce错误")
+	}
+	// 验证有效期，允许一定的时钟偏差
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("ID令牌已过期")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockSkew)) {
+		return nil, errors.New("ID令牌签发时间错误")
+	}
+	result := &Claims{
+		Issuer:            claims["iss"].(string),
+		Subject:           stringClaim(claims, "sub"),
+		Email:             stringClaim(claims, "email"),
+		Name:              stringClaim(claims, "name"),
+		PreferredUsername: stringClaim(claims, "preferred_username"),
+		PhoneNumber:       stringClaim(claims, "phone_number"),
+		Groups:            listClaim(claims, cfg.RoleClaim),
+	}
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = v
+	case string:
+		result.EmailVerified = v == "true"
+	}
+	if result.Subject == "" {
+		return nil, errors.New("ID令牌缺少sub")
+	}
+	return result, nil
+}
+
+// 根据kid获取公钥，找不到时重新获取公钥集，以支持身份提供方轮换密钥
+func publicKey(uri string, kid string) (interface{}, error) {
+	jwks.mu.Lock()
+	defer jwks.mu.Unlock()
+	if jwks.uri == uri {
+		if key := findKey(kid); key != nil {
+			return key, nil
+		}
+		if time.Since(jwks.loadedAt) < jwksRefreshInterval {
+			return nil, errors.New("找不到ID令牌的签名公钥")
+		}
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(uri, &set); err != nil {
+		return nil, fmt.Errorf("获取身份提供方公钥失败：%w", err)
+	}
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := parseKey(k); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	jwks.uri, jwks.keys, jwks.loadedAt = uri, keys, time.Now()
+	if key := findKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, errors.New("找不到ID令牌的签名公钥")
+}
+
+// 在公钥集中查找公钥，令牌没有kid且只有一个公钥时使用该公钥
+func findKey(kid string) interface{} {
+	if key, ok := jwks.keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(jwks.keys) == 1 {
+		for _, key := range jwks.keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// 解析JWK格式的公钥
+func parseKey(k jsonWebKey) (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("不支持的曲线：%s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型：%s", k.Kty)
+}
+
+// 判断aud声明中是否包含客户端ID
+func hasAudience(aud interface{}, clientId string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientId
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 获取字符串类型的声明
+func stringClaim(claims jwt.MapClaims, name string) string {
+	v, _ := claims[name].(string)
+	return v
+}
+
+// 获取列表类型的声明，兼容以逗号分隔的字符串
+func listClaim(claims jwt.MapClaims, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var list []string
+	switch v := claims[name].(type) {
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	case []interface{}:
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				list = append(list, str)
+			}
+		}
+	}
+	return list
+}
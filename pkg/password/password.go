@@ -0,0 +1,165 @@
+package password
+
+import (
+	"FlyCloud/pkg/md5"
+	"FlyCloud/serves/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// argon2id 算法名称
+	Argon2id = "argon2id"
+	// bcrypt 算法名称
+	Bcrypt = "bcrypt"
+	// 盐值长度
+	saltLength = 16
+	// argon2id 输出长度
+	keyLength = 32
+)
+
+// 旧版本未加盐的MD5哈希
+var legacyMd5 = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// 获取密码配置，未配置时使用默认值
+func getConfig() config.PasswordConfig {
+	cfg := config.PasswordConfig{}
+	if config.GetConfig().PasswordConfig != nil {
+		cfg = *config.GetConfig().PasswordConfig
+	}
+	if cfg.Algorithm != Bcrypt {
+		cfg.Algorithm = Argon2id
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		cfg.BcryptCost = 12
+	}
+	if cfg.Argon2Memory == 0 {
+		cfg.Argon2Memory = 64 * 1024
+	}
+	if cfg.Argon2Iterations == 0 {
+		cfg.Argon2Iterations = 3
+	}
+	if cfg.Argon2Parallelism == 0 {
+		cfg.Argon2Parallelism = 2
+	}
+	return cfg
+}
+
+// Hash 使用配置的算法生成带参数编码的密码哈希
+func Hash(plain string) (string, error) {
+	cfg := getConfig()
+	if cfg.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(plain), cfg.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+	// 生成随机盐值
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, cfg.Argon2Iterations, cfg.Argon2Memory, cfg.Argon2Parallelism, keyLength)
+	// 编码格式：$argon2id$v=19$m=65536,t=3,p=2$salt$hash
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id,
+		argon2.Version,
+		cfg.Argon2Memory,
+		cfg.Argon2Iterations,
+		cfg.Argon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify 校验明文密码与哈希是否匹配，兼容旧版MD5哈希
+func Verify(plain, encoded string) bool {
+	switch {
+	case strings.HasPrefix(encoded, "$"+Argon2id+"$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(plain), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	case isBcrypt(encoded):
+		return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain)) == nil
+	case legacyMd5.MatchString(encoded):
+		return subtle.ConstantTimeCompare([]byte(md5.Encry(plain)), []byte(encoded)) == 1
+	}
+	return false
+}
+
+// NeedsRehash 判断哈希是否为旧格式或参数与当前配置不一致，需要在登录成功后升级
+func NeedsRehash(encoded string) bool {
+	cfg := getConfig()
+	switch {
+	case strings.HasPrefix(encoded, "$"+Argon2id+"$"):
+		if cfg.Algorithm != Argon2id {
+			return true
+		}
+		params, _, _, err := decodeArgon2id(encoded)
+		if err != nil {
+			return true
+		}
+		return params.memory != cfg.Argon2Memory ||
+			params.iterations != cfg.Argon2Iterations ||
+			params.parallelism != cfg.Argon2Parallelism
+	case isBcrypt(encoded):
+		if cfg.Algorithm != Bcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != cfg.BcryptCost
+	}
+	return true
+}
+
+// argon2id 编码参数
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// 解析argon2id编码的哈希
+func decodeArgon2id(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, err
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, nil, nil, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	return params, salt, key, nil
+}
+
+// 判断是否为bcrypt哈希
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
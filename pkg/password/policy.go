@@ -0,0 +1,45 @@
+package password
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Validate 按配置的密码策略校验明文密码，返回第一个不满足的条件
+func Validate(plain string) error {
+	cfg := getConfig()
+	if len([]rune(plain)) < cfg.MinLength {
+		return fmt.Errorf("密码长度不能少于%d位", cfg.MinLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if cfg.RequireUpper && !upper {
+		return fmt.Errorf("密码必须包含大写字母")
+	}
+	if cfg.RequireLower && !lower {
+		return fmt.Errorf("密码必须包含小写字母")
+	}
+	if cfg.RequireDigit && !digit {
+		return fmt.Errorf("密码必须包含数字")
+	}
+	if cfg.RequireSymbol && !symbol {
+		return fmt.Errorf("密码必须包含特殊字符")
+	}
+	return nil
+}
+
+// HistorySize 获取禁止重复使用的历史密码数量
+func HistorySize() int {
+	return getConfig().HistorySize
+}
@@ -0,0 +1,61 @@
+package tenant
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/pkg/audit"
+	"FlyCloud/pkg/datascope"
+	"FlyCloud/pkg/jwt"
+	acs "FlyCloud/serves/casbin"
+	"FlyCloud/serves/database"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// 文件存储的根目录
+const storageRoot = "./storage"
+
+// Id 获取当前请求用户所属的租户，未登录的请求属于默认租户
+func Id(ctx *gin.Context) uint {
+	if v, ok := ctx.Get("claim"); ok {
+		if claim, ok := v.(*jwt.CustomClaims); ok && claim.TenantId > 0 {
+			return claim.TenantId
+		}
+	}
+	return models.DefaultTenantId
+}
+
+// Domain 获取当前租户在权限策略中的域
+func Domain(ctx *gin.Context) string {
+	return acs.Domain(Id(ctx))
+}
+
+// DB 返回限定为当前租户和当前用户数据范围的数据库连接，通过该连接的修改会记录审计日志
+func DB(ctx *gin.Context, db *gorm.DB) *gorm.DB {
+	return datascope.WithContext(ctx, database.WithTenant(audit.DB(ctx, db), Id(ctx)))
+}
+
+// IsSuper 当前用户是否为所属租户的超级管理员
+func IsSuper(ctx *gin.Context) bool {
+	claim := ctx.MustGet("claim").(*jwt.CustomClaims)
+	return acs.IsSuper(claim.UserId, claim.UserRole, Domain(ctx))
+}
+
+// IsPlatformAdmin 当前用户是否为平台管理员，即默认租户的超级管理员
+func IsPlatformAdmin(ctx *gin.Context) bool {
+	return Id(ctx) == models.DefaultTenantId && IsSuper(ctx)
+}
+
+// StorageRoot 获取文件存储的根目录
+func StorageRoot() string {
+	return storageRoot
+}
+
+// StorageDir 获取租户的文件存储目录，默认租户沿用原来的目录
+func StorageDir(tenantId uint, sub string) string {
+	if tenantId == models.DefaultTenantId {
+		return storageRoot + "/" + sub
+	}
+	return storageRoot + "/tenant/" + strconv.FormatUint(uint64(tenantId), 10) + "/" + sub
+}
@@ -0,0 +1,66 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// 构建信息在编译时通过 -ldflags 注入，例如：
+//
+//	go build -ldflags "-X FlyCloud/pkg/version.Commit=$(git rev-parse HEAD) \
+//		-X FlyCloud/pkg/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ) \
+//		-X FlyCloud/pkg/version.SchemaVersion=25"
+var (
+	// git 提交，未注入时使用 go 编译时记录的版本控制信息
+	Commit = ""
+	// 构建时间
+	BuildTime = ""
+	// 数据库结构版本
+	SchemaVersion = ""
+)
+
+// 未注入的构建信息
+const unknown = "unknown"
+
+// Info 构建信息
+type Info struct {
+	Commit        string `json:"commit"`
+	BuildTime     string `json:"build_time"`
+	SchemaVersion string `json:"schema_version"`
+	GoVersion     string `json:"go_version"`
+	// 构建时工作区是否有未提交的修改
+	Modified bool `json:"modified"`
+}
+
+// Get 获取构建信息
+func Get() Info {
+	info := Info{
+		Commit:        Commit,
+		BuildTime:     BuildTime,
+		SchemaVersion: SchemaVersion,
+		GoVersion:     runtime.Version(),
+	}
+	// 没有注入时从编译时记录的版本控制信息中获取
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = unknown
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = unknown
+	}
+	if info.SchemaVersion == "" {
+		info.SchemaVersion = unknown
+	}
+	return info
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"FlyCloud/models"
+	acs "FlyCloud/serves/casbin"
+	"FlyCloud/serves/config"
+	"FlyCloud/serves/database"
+	"FlyCloud/serves/logging"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 命令行用法
+const usage = `用法：
+  FlyCloud [--config 文件]                   启动服务
+  FlyCloud policy export [-tenant id] [-format yaml|json] [-o 文件]
+                                             导出租户的角色和权限
+  FlyCloud policy import [-tenant id] [-format yaml|json] [-dry-run] [-prune] 文件
+                                             导入租户的角色和权限，-dry-run 只显示变更，
+                                             规则为所有租户共用，只在导入默认租户时导入
+
+  --config 需写在子命令之前，未指定时读取 FLYCLOUD_CONFIG 环境变量或 ./config/config.yaml，
+  配置项可通过 FLYCLOUD_<分组>_<配置项> 环境变量覆盖，例如 FLYCLOUD_DATABASE_HOST
+`
+
+// Command 执行命令行子命令，没有子命令时返回 false
+func Command(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	var err error
+	switch {
+	case args[0] == "policy" && len(args) > 1 && args[1] == "export":
+		err = policyExport(args[2:])
+	case args[0] == "policy" && len(args) > 1 && args[1] == "import":
+		err = policyImport(args[2:])
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println("执行失败：", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// 导出角色和权限
+func policyExport(args []string) error {
+	flags := flag.NewFlagSet("policy export", flag.ExitOnError)
+	tenantId := flags.Uint("tenant", uint(models.DefaultTenantId), "租户id")
+	format := flags.String("format", "yaml", "文档格式，yaml 或 json")
+	output := flags.String("o", "", "输出文件，默认为 roles.<格式>")
+	_ = flags.Parse(args)
+	if *output == "" {
+		*output = "roles." + *format
+	}
+	db := initCommand()
+	if err := checkTenant(db, uint(*tenantId)); err != nil {
+		return err
+	}
+	doc, err := acs.Export(db, uint(*tenantId))
+	if err != nil {
+		return err
+	}
+	data, err := acs.EncodeDocument(doc, *format)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("已导出 %d 条规则、%d 个角色到 %s\n", len(doc.Rules), len(doc.Roles), *output)
+	return nil
+}
+
+// 导入角色和权限
+func policyImport(args []string) error {
+	flags := flag.NewFlagSet("policy import", flag.ExitOnError)
+	tenantId := flags.Uint("tenant", uint(models.DefaultTenantId), "租户id")
+	format := flags.String("format", "", "文档格式，yaml 或 json，默认根据内容判断")
+	dryRun := flags.Bool("dry-run", false, "只显示变更，不写入")
+	prune := flags.Bool("prune", false, "删除文档中没有的规则和角色")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	doc, err := acs.DecodeDocument(data, *format)
+	if err != nil {
+		return err
+	}
+	db := initCommand()
+	if err := checkTenant(db, uint(*tenantId)); err != nil {
+		return err
+	}
+	acs.InitEnforcer(db)
+	// 通知运行中的服务重新加载策略
+	if err := acs.InitWatcher(db, config.GetConfig().CasbinConfig); err != nil {
+		return err
+	}
+	changes, err := acs.Import(db, uint(*tenantId), doc, acs.ImportOptions{
+		DryRun:    *dryRun,
+		Prune:     *prune,
+		SkipRules: uint(*tenantId) != models.DefaultTenantId,
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		fmt.Printf("%-6s %-10s %-20s %s\n", c.Action, c.Kind, c.Target, c.Detail)
+	}
+	switch {
+	case len(changes) == 0:
+		fmt.Println("没有变更")
+	case *dryRun:
+		fmt.Printf("共 %d 项变更，试运行未写入\n", len(changes))
+	default:
+		fmt.Printf("已导入 %d 项变更\n", len(changes))
+		if config.GetConfig().CasbinConfig == nil || config.GetConfig().CasbinConfig.Watcher == "" {
+			fmt.Println("未启用策略同步，运行中的服务需重启后生效")
+		}
+	}
+	return nil
+}
+
+// 初始化命令行需要的配置、日志和数据库
+func initCommand() *gorm.DB {
+	if err := config.InitConfig(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	logging.InitLogger(config.GetConfig().LoggerConfig)
+	db := database.InitDB(config.GetConfig().DatabaseConfig)
+	models.InitTenantTable(db)
+	models.InitRolesTable(db)
+	models.InitRulesModel(db)
+	return db
+}
+
+// 检查租户是否存在
+func checkTenant(db *gorm.DB, tenantId uint) error {
+	var tenant models.Tenant
+	if err := db.First(&tenant, "id = ?", tenantId).Error; err != nil {
+		return fmt.Errorf("租户不存在：%d", tenantId)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"FlyCloud/serves/logging"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// 关闭服务时执行的清理函数
+type shutdownHook struct {
+	name string
+	fn   func() error
+}
+
+// 管理服务的启动和关闭
+type lifecycle struct {
+	hooks []shutdownHook
+}
+
+// 创建生命周期管理
+func newLifecycle() *lifecycle {
+	return &lifecycle{}
+}
+
+// OnShutdown 注册关闭时执行的清理函数，按注册的相反顺序执行，后启动的组件先关闭
+func (l *lifecycle) OnShutdown(name string, fn func() error) {
+	l.hooks = append(l.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// OnStop 注册关闭时执行的停止函数，用于后台任务返回的停止函数
+func (l *lifecycle) OnStop(name string, stop func()) {
+	l.OnShutdown(name, func() error {
+		stop()
+		return nil
+	})
+}
+
+// Run 启动服务并等待 SIGINT 或 SIGTERM 信号，收到信号后停止接收新请求，
+// 在 timeout 内等待处理中的请求完成，然后依次执行清理函数，返回是否正常退出
+func (l *lifecycle) Run(srv *http.Server, timeout time.Duration) bool {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 1)
+	go func() {
+		logging.Info("服务监听地址：", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+	ok := true
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logging.Error("服务启动失败：", err)
+			ok = false
+		}
+	case <-ctx.Done():
+		// 再次收到信号时按默认方式立即退出
+		stop()
+		logging.Info(fmt.Sprintf("收到退出信号，等待处理中的请求完成，最长%s", timeout))
+		drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := srv.Shutdown(drainCtx); err != nil {
+			logging.Warn("部分请求未在超时前完成：", err)
+			ok = false
+		}
+		cancel()
+	}
+	if !l.shutdown() {
+		ok = false
+	}
+	logging.Info("服务已停止")
+	_ = logging.Sync()
+	return ok
+}
+
+// 按注册的相反顺序执行清理函数，某个清理函数失败时继续执行其他清理函数
+func (l *lifecycle) shutdown() bool {
+	ok := true
+	for i := len(l.hooks) - 1; i >= 0; i-- {
+		hook := l.hooks[i]
+		if err := hook.fn(); err != nil {
+			logging.Error("关闭"+hook.name+"失败：", err)
+			ok = false
+			continue
+		}
+		logging.Info("已关闭" + hook.name)
+	}
+	l.hooks = nil
+	return ok
+}
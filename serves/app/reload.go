@@ -0,0 +1,48 @@
+package app
+
+import (
+	"FlyCloud/middleware"
+	"FlyCloud/serves/cache"
+	"FlyCloud/serves/config"
+	"FlyCloud/serves/logging"
+	"reflect"
+	"strings"
+)
+
+// 监听配置文件，日志级别、缓存和跨域配置修改后立即生效
+func watchConfig() {
+	config.Subscribe(logging.OnConfigChange)
+	config.Subscribe(cache.OnConfigChange)
+	config.Subscribe(middleware.OnCorsConfigChange)
+	config.Subscribe(restartRequired)
+	config.Watch(func(err error) {
+		logging.Error("重新加载配置失败，继续使用原来的配置：", err)
+	})
+}
+
+// 提示修改后需要重启才能生效的配置
+func restartRequired(old, new *config.ConfigStruct) {
+	var sections []string
+	if !reflect.DeepEqual(old.ServerConfig, new.ServerConfig) {
+		sections = append(sections, "server")
+	}
+	if !reflect.DeepEqual(old.DatabaseConfig, new.DatabaseConfig) {
+		sections = append(sections, "database")
+	}
+	// 签名密钥在启动时加载，其他JWT配置在签发令牌时读取
+	if old.JwtConfig != nil && new.JwtConfig != nil &&
+		(old.JwtConfig.Algorithm != new.JwtConfig.Algorithm || old.JwtConfig.PrivateKey != new.JwtConfig.PrivateKey ||
+			old.JwtConfig.KeyDir != new.JwtConfig.KeyDir || old.JwtConfig.RotateInterval != new.JwtConfig.RotateInterval) {
+		sections = append(sections, "jwt")
+	}
+	if !reflect.DeepEqual(old.NotifyConfig, new.NotifyConfig) {
+		sections = append(sections, "notify")
+	}
+	if !reflect.DeepEqual(old.CasbinConfig, new.CasbinConfig) {
+		sections = append(sections, "casbin")
+	}
+	if len(sections) > 0 {
+		logging.Warn("以下配置已修改，重启后生效：", strings.Join(sections, "、"))
+	}
+	logging.Info("配置已重新加载")
+}
@@ -3,6 +3,11 @@ package app
 import (
 	"FlyCloud/application/admin"
 	"FlyCloud/application/api"
+	"FlyCloud/models"
+	"FlyCloud/pkg/audit"
+	"FlyCloud/pkg/datascope"
+	"FlyCloud/pkg/jwt"
+	"FlyCloud/pkg/notify"
 	"FlyCloud/serves/cache"
 	acs "FlyCloud/serves/casbin"
 	"FlyCloud/serves/config"
@@ -10,26 +15,84 @@ import (
 	"FlyCloud/serves/logging"
 	"FlyCloud/serves/routers"
 	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
 // Start the application
 func Start() {
-	// 初始化配置
-	config.InitConfig()
+	// 初始化配置，配置不合法时拒绝启动
+	if err := config.InitConfig(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	// 初始化日志
-	logging.InitLogger(config.Config.LoggerConfig)
+	logging.InitLogger(config.GetConfig().LoggerConfig)
+	// 初始化JWT签名密钥
+	if err := jwt.InitKeys(config.GetConfig().JwtConfig); err != nil {
+		panic(fmt.Errorf("init jwt keys failed: %s", err))
+	}
+	lc := newLifecycle()
 	// 初始化数据库
-	db := database.InitDB(config.Config.DatabaseConfig)
+	db := database.InitDB(config.GetConfig().DatabaseConfig)
+	lc.OnShutdown("数据库", database.Close)
+	// 初始化租户表，并创建默认租户
+	models.InitTenantTable(db)
+	// 记录管理操作的审计日志
+	audit.Register(db)
+	// 按用户的数据范围过滤客户、款式和文件
+	datascope.Register(db)
 	// 初始化缓存
-	cache.InitCache(config.Config.CacheConfig)
+	cache.InitCache(config.GetConfig().CacheConfig)
+	lc.OnShutdown("缓存", cache.Close)
 	// 加载Casbin
 	acs.InitEnforcer(db)
+	// 多实例部署时同步权限策略
+	if err := acs.InitWatcher(db, config.GetConfig().CasbinConfig); err != nil {
+		logging.Error("初始化权限策略同步失败：", err)
+	}
+	lc.OnShutdown("权限策略同步", acs.Close)
+	// 初始化消息通知
+	if err := notify.Init(config.GetConfig().NotifyConfig); err != nil {
+		logging.Error("初始化消息通知失败：", err)
+	}
+	server := config.GetConfig().ServerConfig
+	if server.Mode != "" {
+		gin.SetMode(server.Mode)
+	}
 	// 加载多个app的路由
 	routers.Include(admin.Routes, api.Routes)
 	// 初始化路由
 	run := routers.Init()
+	// 只信任配置的代理传入的客户端IP
+	if err := run.SetTrustedProxies(server.TrustedProxies); err != nil {
+		fmt.Println("信任的代理配置错误：", err)
+		os.Exit(1)
+	}
+	// 根据注册的路由同步权限规则
+	if result, err := routers.SyncRules(db, run); err != nil {
+		logging.Error("同步权限规则失败：", err)
+	} else {
+		logging.Info(fmt.Sprintf("同步权限规则：新增%d条，更新%d条，失效%d条", result.Created, result.Updated, result.Stale))
+	}
+	// 启动密钥轮换
+	lc.OnStop("密钥轮换", jwt.StartKeyRotation())
+	// 定期清理过期的审计日志
+	lc.OnStop("审计日志清理", audit.StartCleanup(db))
+	// 监听配置文件修改
+	watchConfig()
 	// 启动服务
-	if err := run.Run(":8080"); err != nil {
-		fmt.Println("startup service failed, err:%v\n", err)
+	srv := &http.Server{
+		Addr:         server.Addr,
+		Handler:      run,
+		ReadTimeout:  time.Duration(server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(server.WriteTimeout) * time.Second,
+	}
+	// 收到退出信号后等待处理中的请求完成，再依次关闭各组件
+	if !lc.Run(srv, time.Duration(server.ShutdownTimeout)*time.Second) {
+		os.Exit(1)
 	}
 }
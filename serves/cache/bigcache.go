@@ -3,17 +3,72 @@ package cache
 import (
 	"FlyCloud/serves/config"
 	"FlyCloud/serves/logging"
+	"fmt"
 	"github.com/allegro/bigcache"
 	"log"
+	"reflect"
+	"sync"
 	"time"
 )
 
-// 声明一个全局的缓存对象
-var Cache *bigcache.BigCache
+// 声明一个全局的缓存对象，配置修改后整体替换
+var (
+	mu    sync.RWMutex
+	Cache *bigcache.BigCache
+)
 
 // 初始化缓存
 func InitCache(cfg *config.CacheConfig) {
 	log.Println("------------------初始化缓存------------------")
+	cache, err := newCache(cfg)
+	if err != nil {
+		logging.Error("初始化缓存失败", err)
+	}
+	// 赋值给全局变量
+	mu.Lock()
+	Cache = cache
+	mu.Unlock()
+	log.Println("------------------缓存初始化完成------------------")
+}
+
+// OnConfigChange 缓存配置修改后按新配置重建缓存，原有的条目复制到新缓存中
+func OnConfigChange(old, new *config.ConfigStruct) {
+	if new.CacheConfig == nil || reflect.DeepEqual(old.CacheConfig, new.CacheConfig) {
+		return
+	}
+	cache, err := newCache(new.CacheConfig)
+	if err != nil {
+		logging.Error("重建缓存失败，继续使用原来的缓存：", err)
+		return
+	}
+	mu.Lock()
+	previous := Cache
+	Cache = cache
+	mu.Unlock()
+	// 令牌吊销等状态保存在缓存中，不能因为修改配置而丢失
+	moved := 0
+	if previous != nil {
+		it := previous.Iterator()
+		for it.SetNext() {
+			entry, err := it.Value()
+			if err != nil {
+				continue
+			}
+			// 复制期间新缓存中已写入的条目更新，不覆盖
+			if _, err := cache.Get(entry.Key()); err == nil {
+				continue
+			}
+			if cache.Set(entry.Key(), entry.Value()) == nil {
+				moved++
+			}
+		}
+		_ = previous.Close()
+	}
+	logging.Info(fmt.Sprintf("缓存配置已更新，复制%d条缓存", moved))
+}
+
+// 按配置创建缓存
+func newCache(cfg *config.CacheConfig) (*bigcache.BigCache, error) {
 	// 构建config
 	config := bigcache.Config{
 		Shards:             cfg.Shards,
@@ -23,37 +78,41 @@ func InitCache(cfg *config.CacheConfig) {
 		Verbose:            cfg.Verbose,
 		HardMaxCacheSize:   cfg.HardMaxCacheSize,
 	}
-	// 初始化缓存
-	cache, err := bigcache.NewBigCache(config)
-	if err != nil {
-		logging.Error("初始化缓存失败", err)
-	}
-	// 赋值给全局变量
-	Cache = cache
-	log.Println("------------------缓存初始化完成------------------")
+	return bigcache.NewBigCache(config)
 }
 
 // 获取缓存
 func GetCache(key string) ([]byte, error) {
-	return Cache.Get(key)
+	return GetCacheObj().Get(key)
 }
 
 // 写入缓存
 func SetCache(key string, value []byte) error {
-	return Cache.Set(key, value)
+	return GetCacheObj().Set(key, value)
 }
 
 // 删除缓存
 func DeleteCache(key string) error {
-	return Cache.Delete(key)
+	return GetCacheObj().Delete(key)
 }
 
 // 清空缓存
 func ClearCache() error {
-	return Cache.Reset()
+	return GetCacheObj().Reset()
+}
+
+// Close 关闭缓存，停止清理过期条目，退出前调用
+func Close() error {
+	cache := GetCacheObj()
+	if cache == nil {
+		return nil
+	}
+	return cache.Close()
 }
 
-// 获取缓存对象
+// 获取缓存对象，缓存配置修改后返回新的缓存对象
 func GetCacheObj() *bigcache.BigCache {
+	mu.RLock()
+	defer mu.RUnlock()
 	return Cache
 }
@@ -0,0 +1,53 @@
+package acs
+
+import (
+	"FlyCloud/serves/cache"
+	"strconv"
+	"time"
+)
+
+// 权限判断结果的缓存key中包含用户和策略的版本号，
+// 版本号变化后旧的缓存不会再被命中，从而立即失效。
+// 策略的版本号在修改策略时递增，见 policy.go
+
+// CachedEnforce 判断用户是否有权限，优先使用缓存的判断结果
+func CachedEnforce(userId uint, role string, dom string, path string, method string) (bool, error) {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	key := "acs:" + strconv.FormatUint(Version(), 10) + ":" + generation(userKey(userId)) + ":" +
+		strconv.FormatUint(uint64(userId), 10) + ":" + role + ":" + dom + ":" + method + ":" + path
+	if entry, err := cache.GetCache(key); err == nil {
+		return string(entry) == "true", nil
+	}
+	result, err := enforce(userId, role, dom, path, method)
+	if err != nil {
+		return false, err
+	}
+	_ = cache.SetCache(key, []byte(strconv.FormatBool(result)))
+	return result, nil
+}
+
+// InvalidateUser 用户的角色或状态变化后，清除该用户的权限缓存
+func InvalidateUser(userId uint) {
+	bump(userKey(userId))
+}
+
+// 获取版本号，不存在时生成新的版本号
+func generation(key string) string {
+	if entry, err := cache.GetCache(key); err == nil {
+		return string(entry)
+	}
+	return bump(key)
+}
+
+// 生成新的版本号
+func bump(key string) string {
+	gen := strconv.FormatInt(time.Now().UnixNano(), 36)
+	_ = cache.SetCache(key, []byte(gen))
+	return gen
+}
+
+// 用户版本号缓存key
+func userKey(userId uint) string {
+	return "acs:gen:user:" + strconv.FormatUint(uint64(userId), 10)
+}
@@ -0,0 +1,588 @@
+package acs
+
+import (
+	"FlyCloud/models"
+	"FlyCloud/serves/database"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gormadapter "github.com/casbin/gorm-adapter"
+	"github.com/jinzhu/gorm"
+	"gopkg.in/yaml.v2"
+)
+
+// 角色和权限的导入导出。规则和角色在不同的部署中id不同，文档中用路径、方法和角色别名引用；
+// 直接授予用户的权限和用户的附加角色与具体用户相关，不导出。
+// 规则为所有租户共用，角色及其权限按租户导出和导入
+
+// DocumentVersion 当前的文档版本
+const DocumentVersion = 1
+
+// Document 角色和权限文档
+type Document struct {
+	Version    int            `json:"version" yaml:"version"`
+	ExportedAt string         `json:"exported_at" yaml:"exported_at"`
+	Rules      []DocumentRule `json:"rules" yaml:"rules"`
+	Roles      []DocumentRole `json:"roles" yaml:"roles"`
+}
+
+// DocumentRule 权限规则，上级规则在前
+type DocumentRule struct {
+	Name   string `json:"name" yaml:"name"`
+	Path   string `json:"path" yaml:"path"`
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	// 上级规则，格式同 Key
+	Parent string `json:"parent,omitempty" yaml:"parent,omitempty"`
+}
+
+// Key 规则在文档中的标识，路由规则为 "方法 路径"，分组节点为路径，没有路径的分组节点为 "#名称"
+func (r DocumentRule) Key() string {
+	return ruleKey(r.Name, r.Path, r.Method)
+}
+
+// DocumentRole 角色及其权限，自定义数据范围的客户与部署相关，不导出
+type DocumentRole struct {
+	Alias       string   `json:"alias" yaml:"alias"`
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	DataScope   string   `json:"data_scope,omitempty" yaml:"data_scope,omitempty"`
+	Inherits    []string `json:"inherits,omitempty" yaml:"inherits,omitempty"`
+	// 格式为 "方法 路径"
+	Permissions []string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+}
+
+// Change 导入时的一项变更
+type Change struct {
+	// rule、role、permission、inherit
+	Kind string `json:"kind"`
+	// create、update、delete
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	// 只计算变更，不写入
+	DryRun bool
+	// 删除文档中没有的规则和角色
+	Prune bool
+	// 不导入规则，只有平台管理员可以修改所有租户共用的规则
+	SkipRules bool
+}
+
+// 试运行时用于回滚事务
+var errDryRun = errors.New("dry run")
+
+// 规则的标识
+func ruleKey(name, path, method string) string {
+	if method != "" {
+		return method + " " + path
+	}
+	if path != "" {
+		return path
+	}
+	return "#" + name
+}
+
+// Export 导出权限规则，以及租户的角色和角色的策略
+func Export(db *gorm.DB, tenantId uint) (*Document, error) {
+	db = database.WithTenant(db, tenantId)
+	dom := Domain(tenantId)
+	doc := &Document{Version: DocumentVersion, ExportedAt: time.Now().Format(time.RFC3339), Rules: []DocumentRule{}, Roles: []DocumentRole{}}
+	// 失效的规则等待管理员删除，不导出
+	var rules []models.Rules
+	if err := db.Where("stale = ?", 0).Order("id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	byId := map[int]models.Rules{}
+	for _, r := range rules {
+		byId[r.ID] = r
+	}
+	// 按树的顺序导出，上级规则在前
+	var walk func(pid int)
+	walk = func(pid int) {
+		for _, r := range rules {
+			if r.Pid != pid {
+				continue
+			}
+			rule := DocumentRule{Name: r.Name, Path: r.Path, Method: r.Method}
+			if parent, ok := byId[r.Pid]; ok {
+				rule.Parent = ruleKey(parent.Name, parent.Path, parent.Method)
+			}
+			doc.Rules = append(doc.Rules, rule)
+			walk(r.ID)
+		}
+	}
+	walk(0)
+	// 上级规则已失效或不存在的规则挂到根节点
+	for _, r := range rules {
+		if _, ok := byId[r.Pid]; !ok && r.Pid != 0 {
+			doc.Rules = append(doc.Rules, DocumentRule{Name: r.Name, Path: r.Path, Method: r.Method})
+			walk(r.ID)
+		}
+	}
+
+	var roles []models.Roles
+	if err := db.Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		role := DocumentRole{Alias: r.Alias, Name: r.Name, Description: r.Description, DataScope: r.DataScope}
+		var err error
+		if role.Permissions, err = loadPermissions(db, r.Alias, dom); err != nil {
+			return nil, err
+		}
+		if role.Inherits, err = loadInherits(db, r.Alias, dom); err != nil {
+			return nil, err
+		}
+		doc.Roles = append(doc.Roles, role)
+	}
+	return doc, nil
+}
+
+// Import 将文档中的规则和角色导入到租户，返回变更列表。所有修改在一个事务中完成
+func Import(db *gorm.DB, tenantId uint, doc *Document, opts ImportOptions) ([]Change, error) {
+	db = database.WithTenant(db, tenantId)
+	im := &importer{dom: Domain(tenantId), changes: []Change{}}
+	if err := validateDocument(db, im.dom, doc, opts); err != nil {
+		return nil, err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		im.tx = tx
+		if !opts.SkipRules {
+			if err := im.rules(doc, opts.Prune); err != nil {
+				return err
+			}
+		}
+		if err := im.roles(doc, opts.Prune); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err == errDryRun {
+		return im.changes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(im.changes) > 0 {
+		if err := Reload(); err != nil {
+			return im.changes, err
+		}
+	}
+	return im.changes, nil
+}
+
+// EncodeDocument 按格式编码文档，格式为 json 或 yaml
+func EncodeDocument(doc *Document, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(doc, "", "  ")
+	case "yaml", "":
+		return yaml.Marshal(doc)
+	}
+	return nil, fmt.Errorf("不支持的格式：%s", format)
+}
+
+// DecodeDocument 解析文档，格式为空时根据内容判断
+func DecodeDocument(data []byte, format string) (*Document, error) {
+	if format == "" {
+		format = "yaml"
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			format = "json"
+		}
+	}
+	doc := &Document{}
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, doc); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(data, doc); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的格式：%s", format)
+	}
+	return doc, nil
+}
+
+// 校验文档
+func validateDocument(db *gorm.DB, dom string, doc *Document, opts ImportOptions) error {
+	if doc.Version != DocumentVersion {
+		return fmt.Errorf("不支持的文档版本：%d", doc.Version)
+	}
+	keys := map[string]bool{}
+	for _, r := range doc.Rules {
+		if r.Name == "" {
+			return errors.New("规则名称不能为空")
+		}
+		if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+			return fmt.Errorf("规则路径不正确：%s", r.Path)
+		}
+		if r.Method != "" && (r.Path == "" || !isMethod(r.Method)) {
+			return fmt.Errorf("规则的请求方法不正确：%s", r.Key())
+		}
+		if keys[r.Key()] {
+			return fmt.Errorf("规则重复：%s", r.Key())
+		}
+		keys[r.Key()] = true
+	}
+	// 最终的继承关系，文档中的角色使用文档中的继承关系
+	graph := map[string][]string{}
+	if !opts.Prune {
+		var rows []gormadapter.CasbinRule
+		if err := db.Where("p_type = ? and v2 = ?", "g", dom).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if !IsUserSubject(row.V0) {
+				graph[row.V0] = append(graph[row.V0], row.V1)
+			}
+		}
+	}
+	roles := map[string]bool{}
+	for _, r := range doc.Roles {
+		if r.Alias == "" || r.Name == "" {
+			return errors.New("角色名称和别名不能为空")
+		}
+		if IsUserSubject(r.Alias) {
+			return fmt.Errorf("角色别名不能以 %s 开头：%s", userPrefix, r.Alias)
+		}
+		if r.DataScope != "" && !models.IsDataScope(r.DataScope) {
+			return fmt.Errorf("角色 %s 的数据范围不正确：%s", r.Alias, r.DataScope)
+		}
+		if roles[r.Alias] {
+			return fmt.Errorf("角色重复：%s", r.Alias)
+		}
+		roles[r.Alias] = true
+		for _, p := range r.Permissions {
+			if _, _, ok := parsePermission(p); !ok {
+				return fmt.Errorf("角色 %s 的权限格式不正确：%s", r.Alias, p)
+			}
+		}
+		graph[r.Alias] = r.Inherits
+	}
+	for _, r := range doc.Roles {
+		for _, parent := range r.Inherits {
+			if parent == "super" {
+				return fmt.Errorf("角色 %s 不能继承超级管理员", r.Alias)
+			}
+			if !roles[parent] && (opts.Prune || !models.IsExistRole(db, parent)) {
+				return fmt.Errorf("角色 %s 继承的角色不存在：%s", r.Alias, parent)
+			}
+		}
+	}
+	// 检查循环继承
+	state := map[string]int{}
+	var visit func(role string) bool
+	visit = func(role string) bool {
+		switch state[role] {
+		case 1:
+			return false
+		case 2:
+			return true
+		}
+		state[role] = 1
+		for _, parent := range graph[role] {
+			if !visit(parent) {
+				return false
+			}
+		}
+		state[role] = 2
+		return true
+	}
+	for role := range graph {
+		if !visit(role) {
+			return fmt.Errorf("角色存在循环继承：%s", role)
+		}
+	}
+	return nil
+}
+
+// 导入过程中的状态
+type importer struct {
+	tx      *gorm.DB
+	dom     string
+	changes []Change
+}
+
+// 记录变更
+func (im *importer) add(kind, action, target, detail string) {
+	im.changes = append(im.changes, Change{Kind: kind, Action: action, Target: target, Detail: detail})
+}
+
+// 导入规则，按标识匹配已有规则
+func (im *importer) rules(doc *Document, prune bool) error {
+	var existing []models.Rules
+	if err := im.tx.Order("id").Find(&existing).Error; err != nil {
+		return err
+	}
+	ids := map[string]int{}
+	byKey := map[string]models.Rules{}
+	for _, r := range existing {
+		key := ruleKey(r.Name, r.Path, r.Method)
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = r
+			ids[key] = r.ID
+		}
+	}
+	wanted := map[string]bool{}
+	for _, r := range doc.Rules {
+		key := r.Key()
+		wanted[key] = true
+		pid := 0
+		if r.Parent != "" {
+			id, ok := ids[r.Parent]
+			if !ok {
+				return fmt.Errorf("规则 %s 的上级规则不存在：%s", key, r.Parent)
+			}
+			pid = id
+		}
+		old, ok := byKey[key]
+		if !ok {
+			rule := models.Rules{Name: r.Name, Path: r.Path, Method: r.Method, Pid: pid}
+			if err := im.tx.Create(&rule).Error; err != nil {
+				return err
+			}
+			ids[key] = rule.ID
+			im.add("rule", "create", key, r.Name)
+			continue
+		}
+		if old.Name == r.Name && old.Pid == pid {
+			continue
+		}
+		if err := im.tx.Model(&models.Rules{}).Where("id = ?", old.ID).Updates(map[string]interface{}{
+			"name": r.Name,
+			"pid":  pid,
+		}).Error; err != nil {
+			return err
+		}
+		im.add("rule", "update", key, r.Name)
+	}
+	if !prune {
+		return nil
+	}
+	// 路由仍存在的规则会在下次启动时重新生成，只删除失效的规则和管理员新增的分组节点
+	removed := []int{}
+	for _, r := range existing {
+		key := ruleKey(r.Name, r.Path, r.Method)
+		if wanted[key] || (!r.Manual() && r.Stale == 0) {
+			continue
+		}
+		if err := im.tx.Delete(&models.Rules{}, "id = ?", r.ID).Error; err != nil {
+			return err
+		}
+		im.add("rule", "delete", key, r.Name)
+		removed = append(removed, r.ID)
+		// 同时删除角色已有的该权限
+		if r.Method == "" {
+			continue
+		}
+		roles, err := RemovePermission(im.tx, r.Path, r.Method)
+		if err != nil {
+			return err
+		}
+		for _, role := range roles {
+			im.add("permission", "delete", role, key)
+		}
+	}
+	// 上级规则被删除的规则移到根节点
+	if len(removed) > 0 {
+		if err := im.tx.Model(&models.Rules{}).Where("pid in (?)", removed).Update("pid", 0).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 导入角色及其权限和继承关系，文档中的角色的权限和继承关系以文档为准
+func (im *importer) roles(doc *Document, prune bool) error {
+	wanted := map[string]bool{}
+	for _, r := range doc.Roles {
+		wanted[r.Alias] = true
+		scope := r.DataScope
+		if scope == "" {
+			scope = models.DataScopeAll
+		}
+		var old models.Roles
+		err := im.tx.Where("alias = ?", r.Alias).First(&old).Error
+		switch {
+		case gorm.IsRecordNotFoundError(err):
+			if err := im.tx.Create(&models.Roles{Name: r.Name, Alias: r.Alias, Description: r.Description, DataScope: scope}).Error; err != nil {
+				return err
+			}
+			im.add("role", "create", r.Alias, r.Name)
+		case err != nil:
+			return err
+		case old.Name != r.Name || old.Description != r.Description || old.DataScope != scope:
+			if err := im.tx.Model(&models.Roles{}).Where("id = ?", old.ID).Updates(map[string]interface{}{
+				"name":        r.Name,
+				"description": r.Description,
+				"data_scope":  scope,
+			}).Error; err != nil {
+				return err
+			}
+			im.add("role", "update", r.Alias, r.Name)
+		}
+		// 超级管理员不通过策略判断
+		if r.Alias == "super" {
+			continue
+		}
+		if err := im.permissions(r); err != nil {
+			return err
+		}
+		if err := im.inherits(r); err != nil {
+			return err
+		}
+	}
+	if !prune {
+		return nil
+	}
+	var existing []models.Roles
+	if err := im.tx.Find(&existing).Error; err != nil {
+		return err
+	}
+	for _, r := range existing {
+		if wanted[r.Alias] || r.Alias == "super" {
+			continue
+		}
+		if err := im.tx.Delete(&models.Roles{}, "id = ?", r.ID).Error; err != nil {
+			return err
+		}
+		// 删除角色的权限、继承关系，以及用户拥有的该附加角色
+		if err := im.tx.Where("((p_type = ? and v0 = ? and v1 = ?) or (p_type = ? and (v0 = ? or v1 = ?) and v2 = ?))", "p", r.Alias, im.dom, "g", r.Alias, r.Alias, im.dom).
+			Delete(&gormadapter.CasbinRule{}).Error; err != nil {
+			return err
+		}
+		im.add("role", "delete", r.Alias, r.Name)
+	}
+	return nil
+}
+
+// 替换角色的权限
+func (im *importer) permissions(r DocumentRole) error {
+	current, err := loadPermissions(im.tx, r.Alias, im.dom)
+	if err != nil {
+		return err
+	}
+	added, removed := diff(current, r.Permissions)
+	for _, p := range added {
+		method, path, _ := parsePermission(p)
+		if err := im.tx.Create(&gormadapter.CasbinRule{PType: "p", V0: r.Alias, V1: im.dom, V2: path, V3: method}).Error; err != nil {
+			return err
+		}
+		im.add("permission", "create", r.Alias, p)
+	}
+	for _, p := range removed {
+		method, path, _ := parsePermission(p)
+		if err := im.tx.Where("p_type = ? and v0 = ? and v1 = ? and v2 = ? and v3 = ?", "p", r.Alias, im.dom, path, method).
+			Delete(&gormadapter.CasbinRule{}).Error; err != nil {
+			return err
+		}
+		im.add("permission", "delete", r.Alias, p)
+	}
+	return nil
+}
+
+// 替换角色继承的上级角色
+func (im *importer) inherits(r DocumentRole) error {
+	current, err := loadInherits(im.tx, r.Alias, im.dom)
+	if err != nil {
+		return err
+	}
+	added, removed := diff(current, r.Inherits)
+	for _, parent := range added {
+		if err := im.tx.Create(&gormadapter.CasbinRule{PType: "g", V0: r.Alias, V1: parent, V2: im.dom}).Error; err != nil {
+			return err
+		}
+		im.add("inherit", "create", r.Alias, parent)
+	}
+	for _, parent := range removed {
+		if err := im.tx.Where("p_type = ? and v0 = ? and v1 = ? and v2 = ?", "g", r.Alias, parent, im.dom).
+			Delete(&gormadapter.CasbinRule{}).Error; err != nil {
+			return err
+		}
+		im.add("inherit", "delete", r.Alias, parent)
+	}
+	return nil
+}
+
+// 从策略表读取角色在域中的权限，已排序
+func loadPermissions(db *gorm.DB, alias string, dom string) ([]string, error) {
+	var rows []gormadapter.CasbinRule
+	if err := db.Where("p_type = ? and v0 = ? and v1 = ?", "p", alias, dom).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	list := []string{}
+	for _, row := range rows {
+		list = append(list, row.V3+" "+row.V2)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// 从策略表读取角色在域中继承的上级角色，已排序
+func loadInherits(db *gorm.DB, alias string, dom string) ([]string, error) {
+	var rows []gormadapter.CasbinRule
+	if err := db.Where("p_type = ? and v0 = ? and v2 = ?", "g", alias, dom).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	list := []string{}
+	for _, row := range rows {
+		list = append(list, row.V1)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// 解析 "方法 路径" 格式的权限
+func parsePermission(p string) (string, string, bool) {
+	fields := strings.Fields(p)
+	if len(fields) != 2 || !isMethod(fields[0]) || !strings.HasPrefix(fields[1], "/") {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// 是否为规则允许的请求方法
+func isMethod(method string) bool {
+	switch method {
+	case "GET", "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// 比较两个列表，返回需要新增和删除的项，重复项只算一次
+func diff(current []string, wanted []string) ([]string, []string) {
+	have := map[string]bool{}
+	for _, v := range current {
+		have[v] = true
+	}
+	want := map[string]bool{}
+	var added, removed []string
+	for _, v := range wanted {
+		if !want[v] && !have[v] {
+			added = append(added, v)
+		}
+		want[v] = true
+	}
+	for _, v := range current {
+		if !want[v] {
+			removed = append(removed, v)
+			want[v] = true
+		}
+	}
+	return added, removed
+}
@@ -1,6 +1,7 @@
 package acs
 
 import (
+	"FlyCloud/models"
 	"fmt"
 	"github.com/casbin/casbin"
 	gormadapter "github.com/casbin/gorm-adapter"
@@ -15,22 +16,39 @@ func InitEnforcer(db *gorm.DB) *casbin.Enforcer {
 	fmt.Println("------------------InitEnforcer------------------")
 	// 创建Adapters
 	adapter := gormadapter.NewAdapterByDB(db)
+	// 将没有域的策略迁移到默认租户
+	if err := migrateDomain(db); err != nil {
+		panic(fmt.Errorf("migrate casbin domain failed: %s", err))
+	}
 	// 创建Enforcer
 	Enforcer = casbin.NewEnforcer("./config/rbac_model.conf", adapter)
-	// 加载策略
+	// 创建时已加载策略，之后只在策略变化时重新加载
 	Enforcer.EnableLog(true)
 	fmt.Println("------------------InitEnforcer-Success-----------------")
 	return Enforcer
 }
 
 // 判断用户是否有权限
-func CheckPermission(role_name string, path string, method string) bool {
+func CheckPermission(role_name string, dom string, path string, method string) bool {
 	fmt.Println("------------------CheckPermission------------------")
+	policyLock.RLock()
+	defer policyLock.RUnlock()
 	// 判断用户是否有权限
-	return Enforcer.Enforce(fmt.Sprintf("%s", role_name), path, method)
+	return Enforcer.Enforce(fmt.Sprintf("%s", role_name), dom, path, method)
 }
 
-// 获取Enforcer
+// 启用多租户之前的策略没有域：p, 主体, 路径, 方法 和 g, 主体, 角色，
+// 补充默认租户的域，变为 p, 主体, 域, 路径, 方法 和 g, 主体, 角色, 域。
+// MySQL 按顺序赋值并使用已更新的值，因此先赋值 v3，再依次赋值 v2 和 v1
+func migrateDomain(db *gorm.DB) error {
+	dom := Domain(models.DefaultTenantId)
+	if err := db.Exec("UPDATE casbin_rule SET v3 = v2, v2 = v1, v1 = ? WHERE p_type = 'p' AND (v3 IS NULL OR v3 = '')", dom).Error; err != nil {
+		return err
+	}
+	return db.Exec("UPDATE casbin_rule SET v2 = ? WHERE p_type = 'g' AND (v2 IS NULL OR v2 = '')", dom).Error
+}
+
+// 获取Enforcer，修改策略请使用 Update，以便更新策略版本号
 func GetEnforcer() *casbin.Enforcer {
 	return Enforcer
 }
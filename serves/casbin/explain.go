@@ -0,0 +1,62 @@
+package acs
+
+import (
+	"github.com/casbin/casbin/util"
+)
+
+// Grant 命中的策略
+type Grant struct {
+	// 策略所属的角色或用户
+	Subject string `json:"subject"`
+	Path    string `json:"path"`
+	Method  string `json:"method"`
+	// 从被判断的主体到策略所属主体的继承路径，第一个为主体本身
+	Via []string `json:"via"`
+}
+
+// Explanation 权限判断的过程
+type Explanation struct {
+	Allowed bool `json:"allowed"`
+	// 主体本身及继承的所有角色
+	Roles []string `json:"roles"`
+	// 路径和方法都匹配的策略，任一命中即允许
+	Matched []Grant `json:"matched"`
+	// 路径匹配但请求方法不匹配的策略
+	MethodMismatch []Grant `json:"method_mismatch"`
+}
+
+// Explain 按照模型中的匹配规则，说明主体在域中访问路径和方法时命中了哪些策略。
+// 匹配规则为 g(r.sub, p.sub, r.dom) && r.dom == p.dom && keyMatch2(r.obj, p.obj) && regexMatch(r.act, p.act)
+func Explain(subject string, dom string, path string, method string) *Explanation {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	result := &Explanation{Roles: []string{}, Matched: []Grant{}, MethodMismatch: []Grant{}}
+	// 按继承关系广度遍历，记录到达每个角色的路径
+	via := map[string][]string{subject: {subject}}
+	queue := []string{subject}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		result.Roles = append(result.Roles, current)
+		for _, p := range Enforcer.GetPermissionsForUserInDomain(current, dom) {
+			if len(p) < 4 || !util.KeyMatch2(path, p[2]) {
+				continue
+			}
+			grant := Grant{Subject: current, Path: p[2], Method: p[3], Via: via[current]}
+			if util.RegexMatch(method, p[3]) {
+				result.Matched = append(result.Matched, grant)
+			} else {
+				result.MethodMismatch = append(result.MethodMismatch, grant)
+			}
+		}
+		for _, parent := range Enforcer.GetRolesForUserInDomain(current, dom) {
+			if _, ok := via[parent]; ok {
+				continue
+			}
+			via[parent] = append(append([]string{}, via[current]...), parent)
+			queue = append(queue, parent)
+		}
+	}
+	result.Allowed = len(result.Matched) > 0
+	return result
+}
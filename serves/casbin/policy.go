@@ -0,0 +1,116 @@
+package acs
+
+import (
+	"FlyCloud/serves/logging"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/casbin/casbin"
+	"github.com/casbin/casbin/persist"
+)
+
+// 策略只在启动时加载一次，之后所有修改都通过 Update 或 Reload 完成：
+// 修改期间阻塞权限判断，完成后递增策略版本号，使所有缓存的判断结果同时失效，
+// 并通过 watcher 通知其他实例重新加载策略
+
+var (
+	// 保护 Enforcer 的读写，casbin 的 Enforcer 本身不是并发安全的
+	policyLock sync.RWMutex
+	// 策略版本号，缓存的判断结果以此区分
+	policyVersion uint64
+	// 通知其他实例的 watcher，未配置时为 nil
+	watcher persist.Watcher
+)
+
+// Update 修改策略，fn 返回错误时已完成的修改同样生效
+func Update(fn func(e *casbin.Enforcer) error) error {
+	policyLock.Lock()
+	err := fn(Enforcer)
+	atomic.AddUint64(&policyVersion, 1)
+	policyLock.Unlock()
+	notify()
+	return err
+}
+
+// View 读取策略，读取期间策略不会被修改
+func View(fn func(e *casbin.Enforcer)) {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	fn(Enforcer)
+}
+
+// Reload 直接修改策略表后重新加载策略，并通知其他实例
+func Reload() error {
+	if err := load(); err != nil {
+		return err
+	}
+	notify()
+	return nil
+}
+
+// Version 获取当前的策略版本号
+func Version() uint64 {
+	return atomic.LoadUint64(&policyVersion)
+}
+
+// SetWatcher 设置 watcher，其他实例修改策略后重新加载
+func SetWatcher(w persist.Watcher) error {
+	policyLock.Lock()
+	watcher = w
+	policyLock.Unlock()
+	return w.SetUpdateCallback(func(string) {
+		if err := load(); err != nil {
+			logging.Error("重新加载权限策略失败：", err)
+		}
+	})
+}
+
+// Close 停止 watcher，退出前调用。策略修改时已写入数据库，无需保存
+func Close() error {
+	policyLock.Lock()
+	w := watcher
+	watcher = nil
+	policyLock.Unlock()
+	if c, ok := w.(interface{ Close() }); ok {
+		c.Close()
+	}
+	return nil
+}
+
+// Ready 检查权限策略是否已加载
+func Ready() error {
+	if Enforcer == nil {
+		return errors.New("权限策略未加载")
+	}
+	var err error
+	View(func(e *casbin.Enforcer) {
+		if e.GetModel()["p"] == nil {
+			err = errors.New("权限模型缺少策略定义")
+		}
+	})
+	return err
+}
+
+// 从策略表重新加载策略
+func load() error {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	if err := Enforcer.LoadPolicy(); err != nil {
+		return err
+	}
+	atomic.AddUint64(&policyVersion, 1)
+	return nil
+}
+
+// 通知其他实例策略已变化
+func notify() {
+	policyLock.RLock()
+	w := watcher
+	policyLock.RUnlock()
+	if w != nil {
+		if err := w.Update(); err != nil {
+			logging.Error("通知其他实例更新权限策略失败：", err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package acs
+
+import (
+	gormadapter "github.com/casbin/gorm-adapter"
+	"github.com/jinzhu/gorm"
+)
+
+// RenamePermission 权限节点的路径或请求方法变更后，改写所有租户中引用旧路径和方法的策略，返回受影响的角色。
+// keepOld 为 true 时保留旧策略，用于仍有其他权限节点使用旧路径和方法的情况
+func RenamePermission(db *gorm.DB, oldPath, oldMethod, newPath, newMethod string, keepOld bool) ([]string, error) {
+	var rows []gormadapter.CasbinRule
+	if err := db.Where("p_type = ? and v2 = ? and v3 = ?", "p", oldPath, oldMethod).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	roles := make([]string, 0, len(rows))
+	for _, row := range rows {
+		// 角色已拥有新的权限时不重复添加
+		var count int
+		if err := db.Model(&gormadapter.CasbinRule{}).Where("p_type = ? and v0 = ? and v1 = ? and v2 = ? and v3 = ?", "p", row.V0, row.V1, newPath, newMethod).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			if err := db.Create(&gormadapter.CasbinRule{PType: "p", V0: row.V0, V1: row.V1, V2: newPath, V3: newMethod}).Error; err != nil {
+				return nil, err
+			}
+		}
+		roles = append(roles, row.V0)
+	}
+	if !keepOld {
+		if err := db.Where("p_type = ? and v2 = ? and v3 = ?", "p", oldPath, oldMethod).Delete(&gormadapter.CasbinRule{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// RemovePermission 删除所有租户中引用该路径和方法的策略，返回受影响的角色
+func RemovePermission(db *gorm.DB, path, method string) ([]string, error) {
+	var rows []gormadapter.CasbinRule
+	if err := db.Where("p_type = ? and v2 = ? and v3 = ?", "p", path, method).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("p_type = ? and v2 = ? and v3 = ?", "p", path, method).Delete(&gormadapter.CasbinRule{}).Error; err != nil {
+		return nil, err
+	}
+	roles := make([]string, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, row.V0)
+	}
+	return roles, nil
+}
@@ -0,0 +1,68 @@
+package acs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 用户在策略中的主体名称前缀，用于与角色别名区分。
+// 策略中的主体可以是角色别名，也可以是 user:<管理员id>，域为租户id：
+//
+//	g, user:<id>, <角色>, <域>     用户拥有的附加角色
+//	g, <角色>, <上级角色>, <域>     角色继承上级角色的全部权限
+//	p, user:<id>, <域>, 路径, 方法  直接授予用户的权限
+const userPrefix = "user:"
+
+// Domain 获取租户在策略中的域
+func Domain(tenantId uint) string {
+	return strconv.FormatUint(uint64(tenantId), 10)
+}
+
+// UserSubject 获取用户在策略中的主体名称
+func UserSubject(userId uint) string {
+	return userPrefix + strconv.FormatUint(uint64(userId), 10)
+}
+
+// IsUserSubject 判断主体名称是否为用户
+func IsUserSubject(subject string) bool {
+	return strings.HasPrefix(subject, userPrefix)
+}
+
+// IsSuper 用户的主角色或附加角色中是否包含超级管理员，超级管理员只能管理所属租户
+func IsSuper(userId uint, role string, dom string) bool {
+	if role == "super" {
+		return true
+	}
+	for _, r := range ImplicitRoles(UserSubject(userId), dom) {
+		if r == "super" {
+			return true
+		}
+	}
+	return false
+}
+
+// UserRoles 获取用户的附加角色，不含继承的角色
+func UserRoles(userId uint, dom string) []string {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	return Enforcer.GetRolesForUserInDomain(UserSubject(userId), dom)
+}
+
+// ImplicitRoles 获取用户或角色直接和间接拥有的所有角色
+func ImplicitRoles(subject string, dom string) []string {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	return Enforcer.GetImplicitRolesForUser(subject, dom)
+}
+
+// 判断用户是否有权限，用户本身的策略包含附加角色、继承的角色和直接授予的权限，
+// 主角色保存在管理员表中，单独判断。调用前需持有读锁
+func enforce(userId uint, role string, dom string, path string, method string) (bool, error) {
+	if ok, err := Enforcer.EnforceSafe(UserSubject(userId), dom, path, method); err != nil || ok {
+		return ok, err
+	}
+	if role == "" {
+		return false, nil
+	}
+	return Enforcer.EnforceSafe(role, dom, path, method)
+}
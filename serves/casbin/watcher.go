@@ -0,0 +1,128 @@
+package acs
+
+import (
+	"FlyCloud/serves/config"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 策略版本表，多个实例共用同一个数据库时通过它同步策略
+type policyRevision struct {
+	ID       uint  `gorm:"primary_key"`
+	Revision int64 `gorm:"type:bigint;not null;default:0"`
+}
+
+// TableName 设置表名
+func (policyRevision) TableName() string {
+	return "casbin_revision"
+}
+
+// DbWatcher 基于数据库轮询的 watcher，实现 persist.Watcher 接口。
+// 修改策略后递增版本表中的版本号，其他实例轮询发现版本号变化后重新加载策略
+type DbWatcher struct {
+	db       *gorm.DB
+	interval time.Duration
+	mu       sync.Mutex
+	callback func(string)
+	revision int64
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewDbWatcher 创建数据库 watcher 并开始轮询
+func NewDbWatcher(db *gorm.DB, interval time.Duration) (*DbWatcher, error) {
+	if !db.HasTable(&policyRevision{}) {
+		if err := db.CreateTable(&policyRevision{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := db.FirstOrCreate(&policyRevision{}, policyRevision{ID: 1}).Error; err != nil {
+		return nil, err
+	}
+	w := &DbWatcher{db: db, interval: interval, stop: make(chan struct{})}
+	// 以启动时的版本号为起点，启动时已加载最新的策略
+	revision, err := w.current()
+	if err != nil {
+		return nil, err
+	}
+	w.revision = revision
+	go w.watch()
+	return w, nil
+}
+
+// SetUpdateCallback 设置策略变化后的回调
+func (w *DbWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update 递增版本号，通知其他实例。本实例轮询时也会重新加载一次，不影响结果
+func (w *DbWatcher) Update() error {
+	return w.db.Model(&policyRevision{}).Where("id = ?", 1).
+		Update("revision", gorm.Expr("revision + ?", 1)).Error
+}
+
+// Close 停止轮询
+func (w *DbWatcher) Close() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// 轮询版本号
+func (w *DbWatcher) watch() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			revision, err := w.current()
+			if err != nil {
+				continue
+			}
+			w.mu.Lock()
+			changed := revision != w.revision
+			w.revision = revision
+			callback := w.callback
+			w.mu.Unlock()
+			if changed && callback != nil {
+				callback(fmt.Sprintf("%d", revision))
+			}
+		}
+	}
+}
+
+// 获取数据库中的版本号
+func (w *DbWatcher) current() (int64, error) {
+	var row policyRevision
+	if err := w.db.First(&row, "id = ?", 1).Error; err != nil {
+		return 0, err
+	}
+	return row.Revision, nil
+}
+
+// InitWatcher 根据配置启用 watcher
+func InitWatcher(db *gorm.DB, cfg *config.CasbinConfig) error {
+	if cfg == nil || cfg.Watcher == "" {
+		return nil
+	}
+	switch cfg.Watcher {
+	case "database":
+		interval := time.Duration(cfg.Interval) * time.Second
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		w, err := NewDbWatcher(db, interval)
+		if err != nil {
+			return err
+		}
+		return SetWatcher(w)
+	default:
+		return fmt.Errorf("不支持的watcher：%s", cfg.Watcher)
+	}
+}
@@ -0,0 +1,9 @@
+package config
+
+// CasbinConfig 权限策略配置
+type CasbinConfig struct {
+	// 多实例部署时同步策略的方式，为空时不同步，database 为轮询数据库
+	Watcher string `mapstructure:"watcher"`
+	// 轮询间隔，单位秒
+	Interval int `mapstructure:"interval"`
+}
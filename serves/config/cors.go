@@ -0,0 +1,7 @@
+package config
+
+// 声明一个跨域配置
+type CorsConfig struct {
+	// 允许跨域访问的来源，例如 https://admin.example.com，* 表示允许所有来源，列表为空时不允许跨域；未配置 cors 时允许所有来源
+	AllowOrigins []string `mapstructure:"allow_origins"`
+}
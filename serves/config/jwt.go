@@ -1,9 +1,19 @@
 package config
 
 type JwtConfig struct {
+	// 签名算法，可选 RS256、ES256、EdDSA、HS256
+	Algorithm string `mapstructure:"algorithm"`
+	// HMAC密钥，仅在 HS256 算法下使用
 	PrivateKey string `mapstructure:"private_key"`
-	ExpiresAt  int    `mapstructure:"expires_at"`
-	Issuer     string `mapstructure:"issuer"`
-	Subject    string `mapstructure:"subject"`
-	Audience   string `mapstructure:"audience"`
+	// 非对称密钥目录，PEM格式，目录为空时首次启动自动生成
+	KeyDir string `mapstructure:"key_dir"`
+	// 密钥轮换周期，单位小时，0表示不轮换
+	RotateInterval int `mapstructure:"rotate_interval"`
+	// 访问令牌有效期，单位分钟
+	ExpiresAt int `mapstructure:"expires_at"`
+	// 刷新令牌有效期，单位分钟
+	RefreshExpiresAt int    `mapstructure:"refresh_expires_at"`
+	Issuer           string `mapstructure:"issuer"`
+	Subject          string `mapstructure:"subject"`
+	Audience         string `mapstructure:"audience"`
 }
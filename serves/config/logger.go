@@ -4,4 +4,30 @@ package config
 type LoggerConfig struct {
 	// 日志文件路径
 	Path string `mapstructure:"path"`
+	// 日志级别，可选 debug、info、warn、error，运行中可通过管理接口修改
+	Level string `mapstructure:"level"`
+	// 日志格式，可选 json、console
+	Encoding string `mapstructure:"encoding"`
+	// 是否同时输出到控制台
+	Console bool `mapstructure:"console"`
+	// 错误日志文件路径，error 及以上级别的日志额外写入该文件，为空时不单独记录
+	ErrorPath string `mapstructure:"error_path"`
+	// 单个日志文件的最大大小，单位MB，超过后切割
+	MaxSize int `mapstructure:"max_size"`
+	// 是否每天零点切割日志
+	Daily bool `mapstructure:"daily"`
+	// 旧日志文件保留天数，0表示不按时间删除
+	MaxAge int `mapstructure:"max_age"`
+	// 旧日志文件保留个数，0表示不按个数删除
+	MaxBackups int `mapstructure:"max_backups"`
+	// 是否压缩旧日志文件
+	Compress bool `mapstructure:"compress"`
+	// 日志采样，为空时不采样
+	Sampling *LoggerSamplingConfig `mapstructure:"sampling"`
+}
+
+// 日志采样配置，每秒内相同内容的日志先记录 Initial 条，之后每 Thereafter 条记录一条
+type LoggerSamplingConfig struct {
+	Initial    int `mapstructure:"initial"`
+	Thereafter int `mapstructure:"thereafter"`
 }
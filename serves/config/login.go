@@ -0,0 +1,18 @@
+package config
+
+type LoginConfig struct {
+	// 统计登录失败次数的时间窗口，单位分钟
+	FailureWindow int `mapstructure:"failure_window"`
+	// 同一账号在时间窗口内失败多少次后锁定，0表示不锁定
+	MaxUserFailures int `mapstructure:"max_user_failures"`
+	// 同一IP在时间窗口内失败多少次后锁定，0表示不锁定
+	MaxIpFailures int `mapstructure:"max_ip_failures"`
+	// 锁定时长，单位分钟
+	LockoutDuration int `mapstructure:"lockout_duration"`
+	// 失败多少次后开始要求等待
+	DelayAfter int `mapstructure:"delay_after"`
+	// 首次等待时长，单位秒，之后每失败一次翻倍
+	DelayBase int `mapstructure:"delay_base"`
+	// 最长等待时长，单位秒
+	DelayMax int `mapstructure:"delay_max"`
+}
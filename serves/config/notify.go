@@ -0,0 +1,33 @@
+package config
+
+// 声明一个消息通知配置
+type NotifyConfig struct {
+	// 通知方式，可选 log、smtp、sms
+	Driver string `mapstructure:"driver"`
+	// log 方式下写入的文件，用于开发环境
+	LogPath string      `mapstructure:"log_path"`
+	Smtp    *SmtpConfig `mapstructure:"smtp"`
+	Sms     *SmsConfig  `mapstructure:"sms"`
+}
+
+// 邮件服务器配置
+type SmtpConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// 发件人地址
+	From string `mapstructure:"from"`
+	// 是否使用 SSL/TLS 直连，否则尝试 STARTTLS
+	Tls bool `mapstructure:"tls"`
+}
+
+// 短信网关配置
+type SmsConfig struct {
+	// 短信服务商，默认 webhook
+	Provider string `mapstructure:"provider"`
+	// webhook 地址，以JSON格式POST手机号和短信内容
+	Url string `mapstructure:"url"`
+	// 请求时携带的 Authorization 头
+	Token string `mapstructure:"token"`
+}
@@ -0,0 +1,34 @@
+package config
+
+// 声明一个OpenID Connect单点登录配置
+type OidcConfig struct {
+	// 是否启用单点登录
+	Enable bool `mapstructure:"enable"`
+	// 身份提供方地址，用于获取 /.well-known/openid-configuration
+	Issuer       string `mapstructure:"issuer"`
+	ClientId     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	// 客户端认证方式，可选 client_secret_basic、client_secret_post
+	AuthMethod string `mapstructure:"auth_method"`
+	// 登录完成后身份提供方回调的前端地址
+	RedirectUrl string   `mapstructure:"redirect_url"`
+	Scopes      []string `mapstructure:"scopes"`
+	// 未绑定的账号是否按已验证的邮箱匹配本地管理员
+	LinkByEmail bool `mapstructure:"link_by_email"`
+	// 是否自动创建本地管理员
+	AutoProvision bool `mapstructure:"auto_provision"`
+	// 用户组所在的声明名称
+	RoleClaim string `mapstructure:"role_claim"`
+	// 用户组与角色的映射，按顺序匹配第一个
+	RoleMapping []*OidcRoleMapping `mapstructure:"role_mapping"`
+	// 没有匹配的用户组时使用的角色，为空时不自动创建
+	DefaultRole string `mapstructure:"default_role"`
+	// 每次登录时是否按用户组同步角色
+	SyncRole bool `mapstructure:"sync_role"`
+}
+
+// 用户组与角色的映射
+type OidcRoleMapping struct {
+	Group string `mapstructure:"group"`
+	Role  string `mapstructure:"role"`
+}
@@ -0,0 +1,31 @@
+package config
+
+// 声明一个密码策略配置
+type PasswordConfig struct {
+	// 哈希算法，可选 argon2id、bcrypt
+	Algorithm string `mapstructure:"algorithm"`
+	// bcrypt 计算成本，取值 4-31
+	BcryptCost int `mapstructure:"bcrypt_cost"`
+	// argon2id 内存占用，单位KB
+	Argon2Memory uint32 `mapstructure:"argon2_memory"`
+	// argon2id 迭代次数
+	Argon2Iterations uint32 `mapstructure:"argon2_iterations"`
+	// argon2id 并行度
+	Argon2Parallelism uint8 `mapstructure:"argon2_parallelism"`
+	// 密码最小长度
+	MinLength int `mapstructure:"min_length"`
+	// 是否必须包含大写字母
+	RequireUpper bool `mapstructure:"require_upper"`
+	// 是否必须包含小写字母
+	RequireLower bool `mapstructure:"require_lower"`
+	// 是否必须包含数字
+	RequireDigit bool `mapstructure:"require_digit"`
+	// 是否必须包含特殊字符
+	RequireSymbol bool `mapstructure:"require_symbol"`
+	// 禁止重复使用最近N次的密码，0表示不限制
+	HistorySize int `mapstructure:"history_size"`
+	// 找回密码令牌有效期，单位分钟
+	ResetExpiresAt int `mapstructure:"reset_expires_at"`
+	// 找回密码页面地址，令牌会拼接在地址后面
+	ResetUrl string `mapstructure:"reset_url"`
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"reflect"
+)
+
+// 敏感配置项，展示配置时隐藏值为字符串的同名配置项
+var secretKeys = map[string]bool{
+	"password":      true,
+	"private_key":   true,
+	"client_secret": true,
+	"token":         true,
+}
+
+// 隐藏后的敏感配置项
+const redacted = "******"
+
+// Redacted 按配置文件的结构返回配置，密码、密钥等敏感配置项已隐藏
+func (c *ConfigStruct) Redacted() map[string]interface{} {
+	out, _ := redact(reflect.ValueOf(c)).(map[string]interface{})
+	return out
+}
+
+// 按 mapstructure 标签转换配置，结构体转为 map，敏感配置项非空时隐藏
+func redact(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+			field := v.Field(i)
+			// 只隐藏字符串配置项，同名的配置节（如 password 密码策略）照常展示
+			if secretKeys[name] && field.Kind() == reflect.String && !field.IsZero() {
+				out[name] = redacted
+				continue
+			}
+			out[name] = redact(field)
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = redact(v.Index(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
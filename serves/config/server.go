@@ -0,0 +1,17 @@
+package config
+
+// 声明一个HTTP服务配置
+type ServerConfig struct {
+	// 监听地址，例如 :8080、127.0.0.1:8080
+	Addr string `mapstructure:"addr"`
+	// 读取请求的超时时间，单位秒，0表示不限制
+	ReadTimeout int `mapstructure:"read_timeout"`
+	// 写入响应的超时时间，单位秒，0表示不限制
+	WriteTimeout int `mapstructure:"write_timeout"`
+	// 退出时等待处理中的请求完成的最长时间，单位秒，默认30秒
+	ShutdownTimeout int `mapstructure:"shutdown_timeout"`
+	// 信任的反向代理地址，支持IP和CIDR，为空时不信任任何代理，客户端IP取连接地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// gin运行模式，可选 debug、release、test
+	Mode string `mapstructure:"mode"`
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ValidationError 配置校验错误，包含所有不合法的配置项
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return "配置校验失败：\n  " + strings.Join(e.Errors, "\n  ")
+}
+
+// 收集校验错误
+type validator struct {
+	errors []string
+}
+
+// 添加一条错误
+func (v *validator) add(key string, format string, args ...interface{}) {
+	v.errors = append(v.errors, key+"："+fmt.Sprintf(format, args...))
+}
+
+// 校验取值是否在可选值中
+func (v *validator) oneOf(key string, value string, options ...string) {
+	for _, option := range options {
+		if value == option {
+			return
+		}
+	}
+	v.add(key, "%q 不合法，可选 %s", value, strings.Join(options, "、"))
+}
+
+// 校验不能为空
+func (v *validator) required(key string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(key, "不能为空")
+	}
+}
+
+// 校验不能为负数
+func (v *validator) nonNegative(key string, value int) {
+	if value < 0 {
+		v.add(key, "不能为负数")
+	}
+}
+
+// Validate 校验配置，返回所有不合法或缺少的配置项。未配置的服务项使用默认值
+func (c *ConfigStruct) Validate() error {
+	v := &validator{}
+	if c.ServerConfig == nil {
+		c.ServerConfig = &ServerConfig{}
+	}
+	if c.ServerConfig.Addr == "" {
+		c.ServerConfig.Addr = ":8080"
+	}
+	if c.ServerConfig.ShutdownTimeout == 0 {
+		c.ServerConfig.ShutdownTimeout = 30
+	}
+	c.ServerConfig.validate(v)
+	// 以下配置缺少时无法启动
+	if c.DatabaseConfig == nil {
+		v.add("database", "缺少数据库配置")
+	} else {
+		c.DatabaseConfig.validate(v)
+	}
+	if c.LoggerConfig == nil {
+		v.add("logger", "缺少日志配置")
+	} else {
+		c.LoggerConfig.validate(v)
+	}
+	if c.CacheConfig == nil {
+		v.add("cache", "缺少缓存配置")
+	} else {
+		c.CacheConfig.validate(v)
+	}
+	if c.JwtConfig == nil {
+		v.add("jwt", "缺少JWT配置")
+	} else {
+		c.JwtConfig.validate(v)
+	}
+	// 以下配置缺少时使用默认值
+	if c.PasswordConfig != nil {
+		c.PasswordConfig.validate(v)
+	}
+	if c.LoginConfig != nil {
+		c.LoginConfig.validate(v)
+	}
+	if c.NotifyConfig != nil {
+		c.NotifyConfig.validate(v)
+	}
+	if c.OidcConfig != nil {
+		c.OidcConfig.validate(v)
+	}
+	if c.CasbinConfig != nil {
+		c.CasbinConfig.validate(v)
+	}
+	if c.CorsConfig != nil {
+		c.CorsConfig.validate(v)
+	}
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+func (c *ServerConfig) validate(v *validator) {
+	if _, port, err := net.SplitHostPort(c.Addr); err != nil || port == "" {
+		v.add("server.addr", "%q 不是合法的监听地址", c.Addr)
+	}
+	v.nonNegative("server.read_timeout", c.ReadTimeout)
+	v.nonNegative("server.write_timeout", c.WriteTimeout)
+	v.nonNegative("server.shutdown_timeout", c.ShutdownTimeout)
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			v.add("server.trusted_proxies", "%q 不是合法的IP或CIDR", proxy)
+		}
+	}
+	if c.Mode != "" {
+		v.oneOf("server.mode", c.Mode, "debug", "release", "test")
+	}
+}
+
+func (c *DatabaseConfig) validate(v *validator) {
+	v.oneOf("database.type", c.Type, "mysql", "sqlite3")
+	v.required("database.database", c.Database)
+	if c.Type == "mysql" {
+		v.required("database.host", c.Host)
+		if c.Port <= 0 || c.Port > 65535 {
+			v.add("database.port", "%d 不是合法的端口", c.Port)
+		}
+		v.required("database.username", c.Username)
+	}
+	v.nonNegative("database.max_open_conns", c.MaxOpenConns)
+	v.nonNegative("database.max_idle_conns", c.MaxIdleConns)
+	v.nonNegative("database.conn_max_lifetime", c.ConnMaxLifetime)
+}
+
+func (c *LoggerConfig) validate(v *validator) {
+	if c.Level != "" {
+		v.oneOf("logger.level", c.Level, "debug", "info", "warn", "error")
+	}
+	if c.Encoding != "" {
+		v.oneOf("logger.encoding", c.Encoding, "json", "console")
+	}
+	v.nonNegative("logger.max_size", c.MaxSize)
+	v.nonNegative("logger.max_age", c.MaxAge)
+	v.nonNegative("logger.max_backups", c.MaxBackups)
+	if c.ErrorPath != "" && c.ErrorPath == c.Path {
+		v.add("logger.error_path", "不能与 logger.path 相同")
+	}
+	if s := c.Sampling; s != nil {
+		v.nonNegative("logger.sampling.initial", s.Initial)
+		// thereafter 为0时超过 initial 条后的相同日志全部丢弃
+		if s.Initial > 0 && s.Thereafter < 1 {
+			v.add("logger.sampling.thereafter", "启用采样时必须大于0")
+		} else {
+			v.nonNegative("logger.sampling.thereafter", s.Thereafter)
+		}
+	}
+}
+
+func (c *CacheConfig) validate(v *validator) {
+	// bigcache 要求分片数为2的幂
+	if c.Shards <= 0 || c.Shards&(c.Shards-1) != 0 {
+		v.add("cache.shards", "%d 必须是2的幂", c.Shards)
+	}
+	if c.LifeWindow <= 0 {
+		v.add("cache.life_window", "必须大于0")
+	}
+	v.nonNegative("cache.max_entries_window", c.MaxEntriesWindow)
+	v.nonNegative("cache.max_entry_size", c.MaxEntrySize)
+	v.nonNegative("cache.hard_max_cache_size", c.HardMaxCacheSize)
+}
+
+func (c *JwtConfig) validate(v *validator) {
+	switch c.Algorithm {
+	case "", "HS256":
+		v.required("jwt.private_key", c.PrivateKey)
+	case "RS256", "ES256", "EdDSA":
+		v.required("jwt.key_dir", c.KeyDir)
+	default:
+		v.oneOf("jwt.algorithm", c.Algorithm, "RS256", "ES256", "EdDSA", "HS256")
+	}
+	v.nonNegative("jwt.rotate_interval", c.RotateInterval)
+	if c.ExpiresAt <= 0 {
+		v.add("jwt.expires_at", "必须大于0")
+	}
+	if c.RefreshExpiresAt < c.ExpiresAt {
+		v.add("jwt.refresh_expires_at", "不能小于 jwt.expires_at")
+	}
+}
+
+func (c *PasswordConfig) validate(v *validator) {
+	if c.Algorithm != "" {
+		v.oneOf("password.algorithm", c.Algorithm, "argon2id", "bcrypt")
+	}
+	if c.Algorithm == "bcrypt" && (c.BcryptCost < 4 || c.BcryptCost > 31) {
+		v.add("password.bcrypt_cost", "%d 必须在4到31之间", c.BcryptCost)
+	}
+	v.nonNegative("password.min_length", c.MinLength)
+	v.nonNegative("password.history_size", c.HistorySize)
+	v.nonNegative("password.reset_expires_at", c.ResetExpiresAt)
+}
+
+func (c *LoginConfig) validate(v *validator) {
+	v.nonNegative("login.failure_window", c.FailureWindow)
+	v.nonNegative("login.max_user_failures", c.MaxUserFailures)
+	v.nonNegative("login.max_ip_failures", c.MaxIpFailures)
+	v.nonNegative("login.lockout_duration", c.LockoutDuration)
+	v.nonNegative("login.delay_after", c.DelayAfter)
+	v.nonNegative("login.delay_base", c.DelayBase)
+	v.nonNegative("login.delay_max", c.DelayMax)
+}
+
+func (c *NotifyConfig) validate(v *validator) {
+	switch c.Driver {
+	case "", "log":
+	case "smtp":
+		if c.Smtp == nil {
+			v.add("notify.smtp", "通知方式为 smtp 时不能为空")
+			break
+		}
+		v.required("notify.smtp.host", c.Smtp.Host)
+		if c.Smtp.Port <= 0 || c.Smtp.Port > 65535 {
+			v.add("notify.smtp.port", "%d 不是合法的端口", c.Smtp.Port)
+		}
+		v.required("notify.smtp.from", c.Smtp.From)
+	case "sms":
+		if c.Sms == nil {
+			v.add("notify.sms", "通知方式为 sms 时不能为空")
+			break
+		}
+		v.required("notify.sms.url", c.Sms.Url)
+	default:
+		v.oneOf("notify.driver", c.Driver, "log", "smtp", "sms")
+	}
+}
+
+func (c *OidcConfig) validate(v *validator) {
+	if !c.Enable {
+		return
+	}
+	v.required("oidc.issuer", c.Issuer)
+	v.required("oidc.client_id", c.ClientId)
+	v.required("oidc.redirect_url", c.RedirectUrl)
+	if c.AuthMethod != "" {
+		v.oneOf("oidc.auth_method", c.AuthMethod, "client_secret_basic", "client_secret_post")
+	}
+}
+
+func (c *CasbinConfig) validate(v *validator) {
+	if c.Watcher != "" {
+		v.oneOf("casbin.watcher", c.Watcher, "database")
+	}
+	v.nonNegative("casbin.interval", c.Interval)
+}
+
+func (c *CorsConfig) validate(v *validator) {
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			continue
+		}
+		// 来源只包含协议、域名和端口
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			v.add("cors.allow_origins", "%q 不是合法的来源，例如 https://admin.example.com", origin)
+		}
+	}
+}
@@ -2,40 +2,138 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
-// 声明一个全局的配置对象
-var Config = new(ConfigStruct)
+// 默认的配置文件路径
+const DefaultFile = "./config/config.yaml"
+
+// 环境变量前缀，例如 FLYCLOUD_DATABASE_HOST 覆盖 database.host，FLYCLOUD_CONFIG 指定配置文件
+const EnvPrefix = "FLYCLOUD"
+
+// 当前生效的配置，重新加载时整体替换，通过 GetConfig 获取
+var current atomic.Value
+
+// 配置加载前 GetConfig 返回的空配置
+var empty = new(ConfigStruct)
+
+// 通过命令行参数指定的配置文件
+var file string
 
 // 声明一个全局的配置对象
 type ConfigStruct struct {
+	// HTTP服务配置
+	*ServerConfig `mapstructure:"server"`
 	// MySQL数据库配置
 	*DatabaseConfig `mapstructure:"database"`
 	*LoggerConfig   `mapstructure:"logger"`
 	*CacheConfig    `mapstructure:"cache"`
 	*JwtConfig      `mapstructure:"jwt"`
+	*PasswordConfig `mapstructure:"password"`
+	*LoginConfig    `mapstructure:"login"`
+	*NotifyConfig   `mapstructure:"notify"`
+	*OidcConfig     `mapstructure:"oidc"`
+	*CasbinConfig   `mapstructure:"casbin"`
+	*CorsConfig     `mapstructure:"cors"`
 }
 
-// 初始化配置
-func InitConfig() {
+// SetFile 设置配置文件路径，优先于 FLYCLOUD_CONFIG 环境变量
+func SetFile(path string) {
+	file = path
+}
+
+// File 获取配置文件路径：命令行参数、FLYCLOUD_CONFIG 环境变量、默认路径依次生效
+func File() string {
+	if file != "" {
+		return file
+	}
+	if env := os.Getenv(EnvPrefix + "_CONFIG"); env != "" {
+		return env
+	}
+	return DefaultFile
+}
+
+// 初始化配置，读取配置文件并以环境变量覆盖，校验不通过时返回所有错误
+func InitConfig() error {
 	fmt.Println("------------init configuration----------")
-	// viper 配置文件
-	viper.SetConfigFile("./config/config.yaml")
+	cfg, err := Load(File())
+	if err != nil {
+		return err
+	}
+	current.Store(cfg)
+	setReloadedAt(time.Now())
+	fmt.Println("------------init configuration success----------")
+	return nil
+}
+
+// Load 读取并校验配置，不修改全局配置
+func Load(path string) (*ConfigStruct, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
 	// 读取配置
-	if err := viper.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("Fatal error config file: %s \n", err))
-		return
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取配置文件 %s 失败：%s", path, err)
 	}
+	// 环境变量覆盖配置，配置文件中没有的配置项也可以通过环境变量设置
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	bindEnvs(v, reflect.TypeOf(ConfigStruct{}), "")
 	// 解析配置文件
-	if err := viper.Unmarshal(Config); err != nil {
-		fmt.Printf("viper.Unmarshal failed, err:%v\n", err)
-		return
+	cfg := new(ConfigStruct)
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败：%s", path, err)
 	}
-	fmt.Println("------------init configuration success----------")
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Set 直接替换当前配置，不校验也不通知订阅者，用于测试
+func Set(cfg *ConfigStruct) {
+	current.Store(cfg)
 }
 
-// 获取配置
+// 获取当前生效的配置，配置文件修改后返回新的配置，调用方不能修改返回的配置
 func GetConfig() *ConfigStruct {
-	return Config
+	if cfg, ok := current.Load().(*ConfigStruct); ok {
+		return cfg
+	}
+	return empty
+}
+
+// 按配置结构的 mapstructure 标签为每个配置项绑定环境变量
+func bindEnvs(v *viper.Viper, t reflect.Type, prefix string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			bindEnvs(v, ft, key)
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.String:
+			// 结构体列表无法通过环境变量设置
+		default:
+			_ = v.BindEnv(key)
+		}
+	}
 }
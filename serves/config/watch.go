@@ -0,0 +1,84 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Subscriber 配置重新加载后的回调，old 和 new 分别是修改前后的配置，不能修改
+type Subscriber func(old, new *ConfigStruct)
+
+// 订阅配置变化的回调
+var subscribers struct {
+	sync.Mutex
+	list []Subscriber
+}
+
+// 最后一次加载配置的时间
+var reloaded struct {
+	sync.RWMutex
+	at time.Time
+}
+
+// 重新加载配置时加锁，避免同时触发的多次加载交替生效
+var reloadMu sync.Mutex
+
+// Subscribe 订阅配置变化，配置文件修改并校验通过后依次调用
+func Subscribe(fn Subscriber) {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+	subscribers.list = append(subscribers.list, fn)
+}
+
+// Watch 监听配置文件，修改后重新加载，加载失败时继续使用原来的配置并调用 onError
+func Watch(onError func(error)) {
+	v := viper.New()
+	v.SetConfigFile(File())
+	v.OnConfigChange(func(e fsnotify.Event) {
+		if err := Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	v.WatchConfig()
+}
+
+// Reload 重新读取并校验配置，校验通过后替换全局配置并通知订阅者
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	cfg, err := Load(File())
+	if err != nil {
+		return err
+	}
+	old := GetConfig()
+	current.Store(cfg)
+	setReloadedAt(time.Now())
+	// 保存文件时可能连续触发多次，配置没有变化时不通知订阅者
+	if reflect.DeepEqual(old, cfg) {
+		return nil
+	}
+	subscribers.Lock()
+	list := append([]Subscriber(nil), subscribers.list...)
+	subscribers.Unlock()
+	for _, fn := range list {
+		fn(old, cfg)
+	}
+	return nil
+}
+
+// ReloadedAt 获取最后一次加载配置的时间
+func ReloadedAt() time.Time {
+	reloaded.RLock()
+	defer reloaded.RUnlock()
+	return reloaded.at
+}
+
+func setReloadedAt(t time.Time) {
+	reloaded.Lock()
+	reloaded.at = t
+	reloaded.Unlock()
+}
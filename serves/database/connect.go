@@ -35,6 +35,9 @@ func InitDB(config *config.DatabaseConfig) *gorm.DB {
 
 	if err != nil {
 		fmt.Println(err)
+	} else {
+		// 多租户的数据隔离
+		registerTenantCallbacks(DB)
 	}
 	//defer DB.Close()
 	//DB.DB().SetConnMaxLifetime()
@@ -42,6 +45,14 @@ func InitDB(config *config.DatabaseConfig) *gorm.DB {
 	return DB
 }
 
+// Close 关闭数据库连接池，退出前调用
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Close()
+}
+
 // 获取函数
 func GetDB() *gorm.DB {
 	return DB
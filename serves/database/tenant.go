@@ -0,0 +1,63 @@
+package database
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 保存当前租户id的键
+const tenantKey = "tenant:id"
+
+// WithTenant 返回限定租户的数据库连接。
+// 查询、更新和删除带有 tenant_id 字段的模型时自动加上租户条件，新增时自动填充租户id
+func WithTenant(db *gorm.DB, tenantId uint) *gorm.DB {
+	return db.Set(tenantKey, tenantId)
+}
+
+// TenantOf 获取数据库连接限定的租户id
+func TenantOf(db *gorm.DB) (uint, bool) {
+	value, ok := db.Get(tenantKey)
+	if !ok {
+		return 0, false
+	}
+	tenantId, ok := value.(uint)
+	return tenantId, ok
+}
+
+// 注册租户相关的回调
+func registerTenantCallbacks(db *gorm.DB) {
+	db.Callback().Query().Before("gorm:query").Register("tenant:query", tenantCondition)
+	db.Callback().RowQuery().Before("gorm:row_query").Register("tenant:row_query", tenantCondition)
+	db.Callback().Update().Before("gorm:update").Register("tenant:update", tenantUpdate)
+	db.Callback().Delete().Before("gorm:delete").Register("tenant:delete", tenantCondition)
+	db.Callback().Create().Before("gorm:create").Register("tenant:create", tenantAssign)
+}
+
+// 获取当前操作限定的租户，模型没有租户字段时不处理
+func scopeTenant(scope *gorm.Scope) (uint, bool) {
+	value, ok := scope.Get(tenantKey)
+	if !ok || !scope.HasColumn("tenant_id") {
+		return 0, false
+	}
+	tenantId, ok := value.(uint)
+	return tenantId, ok
+}
+
+// 加上租户条件
+func tenantCondition(scope *gorm.Scope) {
+	if tenantId, ok := scopeTenant(scope); ok {
+		scope.Search.Where(scope.QuotedTableName()+"."+scope.Quote("tenant_id")+" = ?", tenantId)
+	}
+}
+
+// 更新时加上租户条件，并防止修改所属租户
+func tenantUpdate(scope *gorm.Scope) {
+	tenantCondition(scope)
+	tenantAssign(scope)
+}
+
+// 填充租户id，不允许写入其他租户
+func tenantAssign(scope *gorm.Scope) {
+	if tenantId, ok := scopeTenant(scope); ok {
+		_ = scope.SetColumn("tenant_id", tenantId)
+	}
+}
@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 请求日志对象和请求id在 gin.Context 中的键
+const (
+	loggerKey    = "logger"
+	requestIdKey = "request_id"
+)
+
+// SetRequest 为请求创建带请求id的日志对象，该请求中通过 Ctx 记录的日志都会带上请求id
+func SetRequest(ctx *gin.Context, requestId string) {
+	ctx.Set(requestIdKey, requestId)
+	if SugarLogger != nil {
+		ctx.Set(loggerKey, SugarLogger.With(requestIdKey, requestId))
+	}
+}
+
+// RequestId 获取请求id
+func RequestId(ctx *gin.Context) string {
+	return ctx.GetString(requestIdKey)
+}
+
+// Ctx 获取请求的日志对象，请求没有日志对象时返回全局日志对象
+func Ctx(ctx *gin.Context) *zap.SugaredLogger {
+	if ctx != nil {
+		if v, ok := ctx.Get(loggerKey); ok {
+			if logger, ok := v.(*zap.SugaredLogger); ok {
+				return logger
+			}
+		}
+	}
+	return SugarLogger
+}
@@ -1,24 +1,135 @@
 package logging
 
 import (
-	"FlyCloud/pkg/system"
 	"FlyCloud/serves/config"
+	"errors"
 	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"log"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // 声明一个日志对象
 var Logger *zap.Logger
 var SugarLogger *zap.SugaredLogger
 
+// 供 Error、Info 等函数使用的日志对象，记录的调用位置跳过一层
+var callerLogger *zap.SugaredLogger
+
+// 当前日志级别，运行中修改后立即生效
+var atomicLevel = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+// 当前写入的日志文件，切割和重新初始化时使用
+var files = struct {
+	sync.Mutex
+	list []*lumberjack.Logger
+	stop chan struct{}
+}{}
+
 // 初始化日志对象
 func InitLogger(cfg *config.LoggerConfig) {
 	fmt.Println("------------init logger----------")
-	var (
-		err error
-	)
+	if cfg == nil {
+		cfg = &config.LoggerConfig{Console: true}
+	}
+	// 设置日志级别，配置错误时使用 info
+	if cfg.Level != "" {
+		if err := SetLevel(cfg.Level); err != nil {
+			fmt.Println("日志级别配置错误，使用 info 级别：", err)
+			atomicLevel.SetLevel(zapcore.InfoLevel)
+		}
+	}
+	encoder := newEncoder(cfg.Encoding)
+	var list []*lumberjack.Logger
+	// 日志文件和控制台
+	var sinks []zapcore.WriteSyncer
+	if cfg.Path != "" {
+		file := newFile(cfg, cfg.Path)
+		list = append(list, file)
+		sinks = append(sinks, zapcore.AddSync(file))
+	}
+	if cfg.Console || len(sinks) == 0 {
+		sinks = append(sinks, zapcore.Lock(os.Stdout))
+	}
+	cores := []zapcore.Core{zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(sinks...), atomicLevel)}
+	// 错误日志额外写入单独的文件
+	if cfg.ErrorPath != "" {
+		file := newFile(cfg, cfg.ErrorPath)
+		list = append(list, file)
+		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(file), zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+			return level >= zapcore.ErrorLevel && atomicLevel.Enabled(level)
+		})))
+	}
+	core := zapcore.NewTee(cores...)
+	// 每秒内相同内容的日志超过 Initial 条后按 Thereafter 采样
+	if s := cfg.Sampling; s != nil && s.Initial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, s.Initial, s.Thereafter)
+	}
+	// 构造Logger
+	Logger = zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+	// 构造SugarLogger
+	SugarLogger = Logger.Sugar()
+	callerLogger = Logger.WithOptions(zap.AddCallerSkip(1)).Sugar()
+	// 替换原来的日志文件
+	replaceFiles(list, cfg.Daily)
+
+	fmt.Println("------------init logger success----------")
+}
+
+// OnConfigChange 日志级别修改后立即生效，日志文件等其他配置需要重启后生效
+func OnConfigChange(old, new *config.ConfigStruct) {
+	if new.LoggerConfig == nil || old.LoggerConfig == nil {
+		return
+	}
+	if new.LoggerConfig.Level != old.LoggerConfig.Level && new.LoggerConfig.Level != "" {
+		previous := Level()
+		if err := SetLevel(new.LoggerConfig.Level); err != nil {
+			Error("修改日志级别失败：", err)
+		} else {
+			SugarLogger.Warnw("日志级别已修改", "old", previous, "level", Level())
+		}
+	}
+	// 比较除日志级别以外的配置
+	oldCfg, newCfg := *old.LoggerConfig, *new.LoggerConfig
+	oldCfg.Level, newCfg.Level = "", ""
+	if !reflect.DeepEqual(oldCfg, newCfg) {
+		Warn("日志文件等配置已修改，重启后生效")
+	}
+}
+
+// Sync 将缓冲的日志写入文件，退出前调用
+func Sync() error {
+	if Logger == nil {
+		return nil
+	}
+	return Logger.Sync()
+}
+
+// Level 获取当前的日志级别
+func Level() string {
+	return atomicLevel.String()
+}
+
+// SetLevel 修改日志级别，可选 debug、info、warn、error
+func SetLevel(level string) error {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	if l < zapcore.DebugLevel || l > zapcore.ErrorLevel {
+		return errors.New("日志级别只能是 debug、info、warn 或 error")
+	}
+	atomicLevel.SetLevel(l)
+	return nil
+}
+
+// 根据配置创建编码器
+func newEncoder(encoding string) zapcore.Encoder {
 	// 构造日志配置
 	EncoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "timestamp",
@@ -33,85 +144,108 @@ func InitLogger(cfg *config.LoggerConfig) {
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 		EncodeCaller:   zapcore.FullCallerEncoder,
 	}
-	// 判断日志路径是否存在
-	if _, err = system.IsExist(cfg.Path); err != nil {
-		// 如果不存在，则创建日志目录
-		if err = system.MkDir(cfg.Path); err != nil {
-			fmt.Println("创建日志目录失败！", err)
-			return
-		}
+	if encoding == "console" {
+		return zapcore.NewConsoleEncoder(EncoderConfig)
 	}
-	// 构造Config
-	config := zap.Config{
-		Level:             zap.NewAtomicLevelAt(zap.InfoLevel),
-		Development:       true,
-		DisableCaller:     false,
-		DisableStacktrace: false,
-		Sampling:          nil,
-		Encoding:          "json",
-		EncoderConfig:     EncoderConfig,
-		InitialFields:     map[string]interface{}{"MyName": "yueqing"},
-		OutputPaths:       []string{"stdout", cfg.Path},
-		ErrorOutputPaths:  []string{"stderr"},
+	return zapcore.NewJSONEncoder(EncoderConfig)
+}
+
+// 创建按大小切割的日志文件，目录不存在时自动创建
+func newFile(cfg *config.LoggerConfig, path string) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    cfg.MaxSize,
+		MaxAge:     cfg.MaxAge,
+		MaxBackups: cfg.MaxBackups,
+		LocalTime:  true,
+		Compress:   cfg.Compress,
 	}
+}
 
-	// 构造Logger
-	Logger, err = config.Build()
-	if err != nil {
-		log.Println("初始化日志失败！", err)
+// 关闭原来的日志文件，并按需启动每日切割
+func replaceFiles(list []*lumberjack.Logger, daily bool) {
+	files.Lock()
+	defer files.Unlock()
+	if files.stop != nil {
+		close(files.stop)
+		files.stop = nil
 	}
-	// 构造SugarLogger
-	SugarLogger = Logger.Sugar()
+	for _, file := range files.list {
+		_ = file.Close()
+	}
+	files.list = list
+	if daily && len(list) > 0 {
+		files.stop = make(chan struct{})
+		go rotateDaily(list, files.stop)
+	}
+}
 
-	fmt.Println("------------init logger success----------")
+// 每天零点切割日志文件
+func rotateDaily(list []*lumberjack.Logger, stop chan struct{}) {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-timer.C:
+			for _, file := range list {
+				if err := file.Rotate(); err != nil {
+					Error("切割日志文件失败：", err)
+				}
+			}
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
 }
 
 // 失败日志
 func Error(args ...interface{}) {
-	SugarLogger.Error(args...)
+	callerLogger.Error(args...)
 }
 
 // Debug日志
 func Debug(args ...interface{}) {
-	SugarLogger.Debug(args...)
+	callerLogger.Debug(args...)
 }
 
 // Info日志
 func Info(args ...interface{}) {
-	SugarLogger.Info(args...)
+	callerLogger.Info(args...)
 }
 
 // Warn日志
 func Warn(args ...interface{}) {
-	SugarLogger.Warn(args...)
+	callerLogger.Warn(args...)
 }
 
 // Fatal日志
 func Fatal(args ...interface{}) {
-	SugarLogger.Fatal(args...)
+	callerLogger.Fatal(args...)
 }
 
 // Panic日志
 func Panic(args ...interface{}) {
-	SugarLogger.Panic(args...)
+	callerLogger.Panic(args...)
 }
 
 // 记录日志
 func Log(level string, args ...interface{}) {
 	switch level {
 	case "debug":
-		SugarLogger.Debug(args...)
+		callerLogger.Debug(args...)
 	case "info":
-		SugarLogger.Info(args...)
+		callerLogger.Info(args...)
 	case "warn":
-		SugarLogger.Warn(args...)
+		callerLogger.Warn(args...)
 	case "error":
-		SugarLogger.Error(args...)
+		callerLogger.Error(args...)
 	case "fatal":
-		SugarLogger.Fatal(args...)
+		callerLogger.Fatal(args...)
 	case "panic":
-		SugarLogger.Panic(args...)
+		callerLogger.Panic(args...)
 	default:
-		SugarLogger.Info(args...)
+		callerLogger.Info(args...)
 	}
 }
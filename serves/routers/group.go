@@ -0,0 +1,98 @@
+package routers
+
+import (
+	"net/http"
+	"path"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Group 带名称的路由分组，通过它注册的路由会自动生成权限规则
+type Group struct {
+	*gin.RouterGroup
+}
+
+// 分组的名称和上级分组
+type groupMeta struct {
+	Name   string
+	Parent string
+}
+
+// 注册路由时声明的名称
+var registry = struct {
+	sync.Mutex
+	groups map[string]groupMeta
+	routes map[string]string
+}{
+	groups: map[string]groupMeta{},
+	routes: map[string]string{},
+}
+
+// NewGroup 为路由分组设置名称，生成权限树时作为分组节点
+func NewGroup(group *gin.RouterGroup, name string) *Group {
+	return newGroup(group, name, "")
+}
+
+// Group 创建带名称的下级分组
+func (g *Group) Group(relativePath string, name string, handlers ...gin.HandlerFunc) *Group {
+	return newGroup(g.RouterGroup.Group(relativePath, handlers...), name, g.BasePath())
+}
+
+// GET 注册GET路由并设置名称
+func (g *Group) GET(relativePath string, name string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.handle(http.MethodGet, relativePath, name, handlers)
+}
+
+// POST 注册POST路由并设置名称
+func (g *Group) POST(relativePath string, name string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.handle(http.MethodPost, relativePath, name, handlers)
+}
+
+// PUT 注册PUT路由并设置名称
+func (g *Group) PUT(relativePath string, name string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.handle(http.MethodPut, relativePath, name, handlers)
+}
+
+// PATCH 注册PATCH路由并设置名称
+func (g *Group) PATCH(relativePath string, name string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.handle(http.MethodPatch, relativePath, name, handlers)
+}
+
+// DELETE 注册DELETE路由并设置名称
+func (g *Group) DELETE(relativePath string, name string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.handle(http.MethodDelete, relativePath, name, handlers)
+}
+
+// 注册路由并记录名称
+func (g *Group) handle(method string, relativePath string, name string, handlers []gin.HandlerFunc) gin.IRoutes {
+	registry.Lock()
+	registry.routes[routeKey(method, joinPaths(g.BasePath(), relativePath))] = name
+	registry.Unlock()
+	return g.RouterGroup.Handle(method, relativePath, handlers...)
+}
+
+// 记录分组名称
+func newGroup(group *gin.RouterGroup, name string, parent string) *Group {
+	registry.Lock()
+	registry.groups[group.BasePath()] = groupMeta{Name: name, Parent: parent}
+	registry.Unlock()
+	return &Group{RouterGroup: group}
+}
+
+// 路由名称的key
+func routeKey(method string, fullPath string) string {
+	return method + " " + fullPath
+}
+
+// 拼接路径，与gin计算完整路径的方式一致
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+	finalPath := path.Join(absolutePath, relativePath)
+	if relativePath[len(relativePath)-1] == '/' && finalPath[len(finalPath)-1] != '/' {
+		return finalPath + "/"
+	}
+	return finalPath
+}
@@ -1,6 +1,7 @@
 package routers
 
 import (
+	"FlyCloud/middleware"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +20,8 @@ func Include(opts ...Option) {
 func Init() *gin.Engine {
 	fmt.Println("------------init router----------")
 	r := gin.New()
+	// 请求id、访问日志和异常恢复需要在其他中间件之前注册
+	r.Use(middleware.RequestId(), middleware.AccessLog(), middleware.Recovery())
 	for _, opt := range options {
 		opt(r)
 	}
@@ -0,0 +1,186 @@
+package routers
+
+import (
+	"FlyCloud/models"
+	acs "FlyCloud/serves/casbin"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// SyncResult 权限规则同步结果
+type SyncResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Stale   int `json:"stale"`
+	// 权限被改写的角色
+	Roles []string `json:"roles"`
+}
+
+// SyncRules 根据已注册的路由更新权限规则，使权限树与实际路由保持一致。
+// 规则的路径和请求方法以路由为准，名称和上级规则只在新增时设置，之后由管理员维护；
+// 旧规则的路径不含路由参数时按去掉参数后的路径匹配，并同步改写角色已有的权限；
+// 没有对应路由的规则标记为失效，由管理员确认后删除，管理员新增的分组节点不受影响
+func SyncRules(db *gorm.DB, r *gin.Engine) (*SyncResult, error) {
+	models.InitRulesModel(db)
+	result := &SyncResult{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var rules []*models.Rules
+		if err := tx.Order("id").Find(&rules).Error; err != nil {
+			return err
+		}
+		s := &syncer{tx: tx, rules: rules, claimed: map[int]bool{}, result: result}
+		registry.Lock()
+		defer registry.Unlock()
+		// 先同步分组节点，上级分组在前
+		groups := make([]string, 0, len(registry.groups))
+		for base := range registry.groups {
+			groups = append(groups, base)
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			if di, dj := strings.Count(groups[i], "/"), strings.Count(groups[j], "/"); di != dj {
+				return di < dj
+			}
+			return groups[i] < groups[j]
+		})
+		nodes := map[string]int{}
+		for _, base := range groups {
+			meta := registry.groups[base]
+			rule, err := s.upsertGroup(base, meta.Name, nodes[meta.Parent])
+			if err != nil {
+				return err
+			}
+			nodes[base] = rule.ID
+		}
+		// 再同步实际注册的路由
+		for _, route := range r.Routes() {
+			name, ok := registry.routes[routeKey(route.Method, route.Path)]
+			if !ok {
+				continue
+			}
+			if err := s.upsertRoute(route.Path, route.Method, name, nodes[groupOf(route.Path)]); err != nil {
+				return err
+			}
+		}
+		// 标记没有对应路由的规则
+		for _, rule := range s.rules {
+			if s.claimed[rule.ID] || rule.Stale == 1 || rule.Manual() {
+				continue
+			}
+			if err := tx.Model(&models.Rules{}).Where("id = ?", rule.ID).Update("stale", 1).Error; err != nil {
+				return err
+			}
+			result.Stale++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Roles) > 0 {
+		if err := acs.Reload(); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// 同步过程中的状态
+type syncer struct {
+	tx      *gorm.DB
+	rules   []*models.Rules
+	claimed map[int]bool
+	result  *SyncResult
+}
+
+// 同步分组节点，按路径匹配，旧的分组节点没有路径时按名称匹配
+func (s *syncer) upsertGroup(base string, name string, pid int) (*models.Rules, error) {
+	rule := s.find(func(r *models.Rules) bool { return r.Method == "" && r.Path == base })
+	if rule == nil {
+		rule = s.find(func(r *models.Rules) bool { return r.Method == "" && r.Path == "" && r.Name == name })
+	}
+	return s.save(rule, &models.Rules{Name: name, Path: base, Pid: pid})
+}
+
+// 同步路由规则，旧规则的路径不含路由参数且请求方法大小写可能不一致
+func (s *syncer) upsertRoute(fullPath string, method string, name string, pid int) error {
+	rule := s.find(func(r *models.Rules) bool { return r.Method == method && r.Path == fullPath })
+	if rule == nil {
+		legacy := stripParams(fullPath)
+		rule = s.find(func(r *models.Rules) bool { return strings.EqualFold(r.Method, method) && r.Path == legacy })
+		// 改写角色已有的权限
+		if rule != nil {
+			roles, err := acs.RenamePermission(s.tx, rule.Path, rule.Method, fullPath, method, false)
+			if err != nil {
+				return err
+			}
+			s.result.Roles = append(s.result.Roles, roles...)
+		}
+	}
+	_, err := s.save(rule, &models.Rules{Name: name, Path: fullPath, Method: method, Pid: pid})
+	return err
+}
+
+// 新增或更新规则，并标记为已匹配，已有规则保留管理员修改的名称和上级规则
+func (s *syncer) save(rule *models.Rules, want *models.Rules) (*models.Rules, error) {
+	if rule == nil {
+		if err := s.tx.Create(want).Error; err != nil {
+			return nil, err
+		}
+		s.rules = append(s.rules, want)
+		s.claimed[want.ID] = true
+		s.result.Created++
+		return want, nil
+	}
+	s.claimed[rule.ID] = true
+	if rule.Path == want.Path && rule.Method == want.Method && rule.Stale == 0 {
+		return rule, nil
+	}
+	if err := s.tx.Model(&models.Rules{}).Where("id = ?", rule.ID).Updates(map[string]interface{}{
+		"path":   want.Path,
+		"method": want.Method,
+		"stale":  0,
+	}).Error; err != nil {
+		return nil, err
+	}
+	rule.Path, rule.Method, rule.Stale = want.Path, want.Method, 0
+	s.result.Updated++
+	return rule, nil
+}
+
+// 查找第一个未匹配的规则
+func (s *syncer) find(match func(r *models.Rules) bool) *models.Rules {
+	for _, r := range s.rules {
+		if !s.claimed[r.ID] && match(r) {
+			return r
+		}
+	}
+	return nil
+}
+
+// 获取路由所属的最近的分组
+func groupOf(fullPath string) string {
+	for p := fullPath; p != "/" && p != "."; {
+		if i := strings.LastIndex(p, "/"); i > 0 {
+			p = p[:i]
+		} else {
+			break
+		}
+		if _, ok := registry.groups[p]; ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// 去掉路径中的路由参数，如 /admin/admin/edit/:id 变为 /admin/admin/edit
+func stripParams(fullPath string) string {
+	for _, sep := range []string{"/:", "/*"} {
+		if i := strings.Index(fullPath, sep); i >= 0 {
+			fullPath = fullPath[:i]
+		}
+	}
+	return fullPath
+}